@@ -0,0 +1 @@
+ALTER TABLE quiz_sessions DROP COLUMN room_id;
\ No newline at end of file
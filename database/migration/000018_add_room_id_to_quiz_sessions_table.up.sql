@@ -0,0 +1 @@
+ALTER TABLE quiz_sessions ADD COLUMN room_id VARCHAR(36) NULL AFTER quiz_uuid;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE quizzes DROP COLUMN scoring_strategy;
\ No newline at end of file
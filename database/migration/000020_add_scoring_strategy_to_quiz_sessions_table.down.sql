@@ -0,0 +1 @@
+ALTER TABLE quiz_sessions DROP COLUMN scoring_strategy;
\ No newline at end of file
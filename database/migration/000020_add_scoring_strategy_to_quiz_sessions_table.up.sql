@@ -0,0 +1 @@
+ALTER TABLE quiz_sessions ADD COLUMN scoring_strategy VARCHAR(30) NULL;
\ No newline at end of file
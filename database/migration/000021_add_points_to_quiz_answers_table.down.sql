@@ -0,0 +1 @@
+ALTER TABLE quiz_answers DROP COLUMN points;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE quiz_answers ADD COLUMN points INT NOT NULL DEFAULT 0;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE quiz_sessions DROP COLUMN time_limit;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE quiz_sessions ADD COLUMN time_limit INT NOT NULL DEFAULT 0;
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS quiz_assignments;
\ No newline at end of file
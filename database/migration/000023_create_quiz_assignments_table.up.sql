@@ -0,0 +1,13 @@
+CREATE TABLE quiz_assignments (
+    id INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    uuid VARCHAR(36) NOT NULL UNIQUE,
+    quiz_uuid VARCHAR(36) NOT NULL,
+    assigned_by INT UNSIGNED NOT NULL,
+    scoring_strategy VARCHAR(30) NULL,
+    opens_at DATETIME NOT NULL,
+    due_at DATETIME NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (quiz_uuid) REFERENCES quizzes(uuid) ON DELETE CASCADE,
+    FOREIGN KEY (assigned_by) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,6 @@
+ALTER TABLE quiz_sessions
+    DROP FOREIGN KEY fk_quiz_sessions_assignment,
+    DROP FOREIGN KEY fk_quiz_sessions_user,
+    DROP INDEX uq_quiz_sessions_assignment_user,
+    DROP COLUMN assignment_id,
+    DROP COLUMN user_id;
@@ -0,0 +1,6 @@
+ALTER TABLE quiz_sessions
+    ADD COLUMN assignment_id INT UNSIGNED NULL,
+    ADD COLUMN user_id INT UNSIGNED NULL,
+    ADD CONSTRAINT fk_quiz_sessions_assignment FOREIGN KEY (assignment_id) REFERENCES quiz_assignments(id) ON DELETE CASCADE,
+    ADD CONSTRAINT fk_quiz_sessions_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    ADD UNIQUE KEY uq_quiz_sessions_assignment_user (assignment_id, user_id);
@@ -0,0 +1 @@
+ALTER TABLE users DROP COLUMN avatar_uuid;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN avatar_uuid CHAR(36) NULL AFTER phone;
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS quiz_guests;
\ No newline at end of file
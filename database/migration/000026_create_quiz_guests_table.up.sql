@@ -0,0 +1,9 @@
+CREATE TABLE quiz_guests (
+    id INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    uuid VARCHAR(36) NOT NULL UNIQUE,
+    room_id VARCHAR(36) NOT NULL,
+    quiz_uuid VARCHAR(36) NOT NULL,
+    nickname VARCHAR(30) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (quiz_uuid) REFERENCES quizzes(uuid) ON DELETE CASCADE
+);
@@ -0,0 +1,5 @@
+DELETE FROM quiz_answers WHERE user_id IS NULL;
+ALTER TABLE quiz_answers
+    DROP FOREIGN KEY fk_quiz_answers_guest,
+    DROP COLUMN guest_id,
+    MODIFY COLUMN user_id INT UNSIGNED NOT NULL;
@@ -0,0 +1,4 @@
+ALTER TABLE quiz_answers
+    MODIFY COLUMN user_id INT UNSIGNED NULL,
+    ADD COLUMN guest_id INT UNSIGNED NULL AFTER user_id,
+    ADD CONSTRAINT fk_quiz_answers_guest FOREIGN KEY (guest_id) REFERENCES quiz_guests(id) ON DELETE CASCADE;
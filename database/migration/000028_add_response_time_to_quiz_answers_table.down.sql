@@ -0,0 +1 @@
+ALTER TABLE quiz_answers DROP COLUMN response_time_ms;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE quiz_answers ADD COLUMN response_time_ms BIGINT NOT NULL DEFAULT 0 AFTER points;
\ No newline at end of file
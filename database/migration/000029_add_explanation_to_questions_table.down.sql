@@ -0,0 +1 @@
+ALTER TABLE questions DROP COLUMN explanation;
\ No newline at end of file
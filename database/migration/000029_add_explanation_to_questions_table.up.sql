@@ -0,0 +1 @@
+ALTER TABLE questions ADD COLUMN explanation TEXT NULL AFTER correct_answer;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE quiz_sessions DROP COLUMN reveal_seconds;
\ No newline at end of file
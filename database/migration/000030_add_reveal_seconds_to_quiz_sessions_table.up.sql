@@ -0,0 +1 @@
+ALTER TABLE quiz_sessions ADD COLUMN reveal_seconds INT NOT NULL DEFAULT 0 AFTER time_limit;
\ No newline at end of file
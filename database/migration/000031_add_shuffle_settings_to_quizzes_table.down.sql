@@ -0,0 +1,3 @@
+ALTER TABLE quizzes
+    DROP COLUMN shuffle_questions,
+    DROP COLUMN shuffle_options;
\ No newline at end of file
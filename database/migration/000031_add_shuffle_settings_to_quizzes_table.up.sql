@@ -0,0 +1,3 @@
+ALTER TABLE quizzes
+    ADD COLUMN shuffle_questions BOOLEAN NOT NULL DEFAULT FALSE AFTER scoring_strategy,
+    ADD COLUMN shuffle_options BOOLEAN NOT NULL DEFAULT FALSE AFTER shuffle_questions;
\ No newline at end of file
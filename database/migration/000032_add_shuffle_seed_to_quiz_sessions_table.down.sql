@@ -0,0 +1 @@
+ALTER TABLE quiz_sessions DROP COLUMN shuffle_seed;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE quiz_sessions ADD COLUMN shuffle_seed BIGINT NOT NULL DEFAULT 0 AFTER reveal_seconds;
\ No newline at end of file
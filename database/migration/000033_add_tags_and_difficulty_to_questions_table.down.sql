@@ -0,0 +1,3 @@
+ALTER TABLE questions
+    DROP COLUMN tags,
+    DROP COLUMN difficulty;
\ No newline at end of file
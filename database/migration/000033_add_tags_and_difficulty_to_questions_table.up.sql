@@ -0,0 +1,3 @@
+ALTER TABLE questions
+    ADD COLUMN tags JSON NULL AFTER explanation,
+    ADD COLUMN difficulty VARCHAR(20) NULL AFTER tags;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS quiz_pools;
\ No newline at end of file
@@ -0,0 +1,13 @@
+CREATE TABLE quiz_pools (
+    id INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    uuid VARCHAR(36) NOT NULL UNIQUE,
+    quiz_id INT UNSIGNED NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    tags JSON NULL,
+    difficulty VARCHAR(20) NULL,
+    question_uuids JSON NULL,
+    draw_count INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (quiz_id) REFERENCES quizzes(id) ON DELETE CASCADE
+);
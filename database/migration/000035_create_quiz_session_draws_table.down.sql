@@ -0,0 +1 @@
+DROP TABLE IF EXISTS quiz_session_draws;
\ No newline at end of file
@@ -0,0 +1,9 @@
+CREATE TABLE quiz_session_draws (
+    id INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    quiz_session_id INT UNSIGNED NOT NULL,
+    user_id INT UNSIGNED NULL,
+    guest_id INT UNSIGNED NULL,
+    question_ids JSON NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (quiz_session_id) REFERENCES quiz_sessions(id) ON DELETE CASCADE
+);
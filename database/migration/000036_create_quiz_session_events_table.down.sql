@@ -0,0 +1 @@
+DROP TABLE IF EXISTS quiz_session_events;
\ No newline at end of file
@@ -0,0 +1,13 @@
+CREATE TABLE quiz_session_events (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    quiz_session_id INT UNSIGNED NOT NULL,
+    seq INT UNSIGNED NOT NULL,
+    direction VARCHAR(3) NOT NULL,
+    user_id INT UNSIGNED NULL,
+    guest_id INT UNSIGNED NULL,
+    type VARCHAR(50) NOT NULL,
+    payload JSON NULL,
+    occurred_at DATETIME(3) NOT NULL,
+    UNIQUE KEY uq_quiz_session_events_seq (quiz_session_id, seq),
+    FOREIGN KEY (quiz_session_id) REFERENCES quiz_sessions(id) ON DELETE CASCADE
+);
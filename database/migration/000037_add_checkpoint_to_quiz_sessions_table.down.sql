@@ -0,0 +1,4 @@
+ALTER TABLE quiz_sessions
+    DROP COLUMN aborted,
+    DROP COLUMN checkpointed_at,
+    DROP COLUMN checkpoint;
@@ -0,0 +1,4 @@
+ALTER TABLE quiz_sessions
+    ADD COLUMN checkpoint JSON NULL AFTER final_scores,
+    ADD COLUMN checkpointed_at DATETIME NULL AFTER checkpoint,
+    ADD COLUMN aborted BOOLEAN NOT NULL DEFAULT FALSE AFTER checkpointed_at;
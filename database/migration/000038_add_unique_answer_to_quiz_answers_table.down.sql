@@ -0,0 +1,4 @@
+ALTER TABLE quiz_answers
+    ADD INDEX idx_quiz_answers_session (quiz_session_id),
+    DROP INDEX uq_quiz_answers_session_question_player,
+    DROP COLUMN answered_by;
@@ -0,0 +1,11 @@
+-- answered_by names the user or guest behind an answer, so the unique key
+-- covers guests too, whose user_id is NULL.
+--
+-- Adding the key fails if a player already answered a question twice in a
+-- session. Nothing is deleted here: find those answers with
+--   SELECT quiz_session_id, question_id, user_id, guest_id, COUNT(*) FROM quiz_answers
+--   GROUP BY quiz_session_id, question_id, user_id, guest_id HAVING COUNT(*) > 1;
+-- and move the extra ones aside before migrating again.
+ALTER TABLE quiz_answers
+    ADD COLUMN answered_by VARCHAR(24) AS (IF(user_id IS NULL, CONCAT('guest:', guest_id), CONCAT('user:', user_id))) VIRTUAL AFTER guest_id,
+    ADD UNIQUE KEY uq_quiz_answers_session_question_player (quiz_session_id, question_id, answered_by);
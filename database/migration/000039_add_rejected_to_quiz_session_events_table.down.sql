@@ -0,0 +1 @@
+ALTER TABLE quiz_session_events DROP COLUMN rejected;
@@ -0,0 +1 @@
+ALTER TABLE quiz_session_events ADD COLUMN rejected BOOLEAN NOT NULL DEFAULT FALSE AFTER payload;
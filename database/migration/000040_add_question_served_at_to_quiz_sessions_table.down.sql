@@ -0,0 +1 @@
+ALTER TABLE quiz_sessions DROP COLUMN question_served_at;
@@ -0,0 +1 @@
+ALTER TABLE quiz_sessions ADD COLUMN question_served_at DATETIME(3) NULL AFTER ended_at;
@@ -1,6 +1,9 @@
 package dtos
 
-import "exam/internal/model"
+import (
+	"exam/internal/model"
+	"time"
+)
 
 // CreateQuizRequest defines the structure for creating a new quiz.
 type QuestionContentPart struct {
@@ -16,8 +19,11 @@ type QuestionOption struct {
 
 // CreateQuizRequest defines the structure for creating a new quiz.
 type CreateQuizRequest struct {
-	Title       string `json:"title" validate:"required,min=5"`
-	Description string `json:"description"`
+	Title            string `json:"title" validate:"required,min=5"`
+	Description      string `json:"description"`
+	ScoringStrategy  string `json:"scoring_strategy" validate:"omitempty,oneof=first_correct all_correct speed streak negative"`
+	ShuffleQuestions bool   `json:"shuffle_questions"` // Per-player question order in parallel and async games
+	ShuffleOptions   bool   `json:"shuffle_options"`   // Per-player option order in every mode
 }
 
 // AddQuestionRequest defines the structure for adding a new question to a quiz.
@@ -26,12 +32,18 @@ type AddQuestionRequest struct {
 	Options       []QuestionOption `json:"options" validate:"required"`
 	CorrectAnswer string           `json:"correct_answer" validate:"required"`
 	Timer         int              `json:"timer" validate:"required,min=0"`
+	Explanation   string           `json:"explanation" validate:"omitempty,max=2000"`
+	Tags          []string         `json:"tags" validate:"omitempty,max=20,dive,required,max=50"`
+	Difficulty    string           `json:"difficulty" validate:"omitempty,oneof=easy medium hard"`
 }
 
 // UpdateQuizRequest defines the structure for updating an existing quiz.
 type UpdateQuizRequest struct {
-	Title       *string `json:"title" validate:"omitempty,min=5"`
-	Description *string `json:"description"`
+	Title            *string `json:"title" validate:"omitempty,min=5"`
+	Description      *string `json:"description"`
+	ScoringStrategy  *string `json:"scoring_strategy" validate:"omitempty,oneof=first_correct all_correct speed streak negative"`
+	ShuffleQuestions *bool   `json:"shuffle_questions"`
+	ShuffleOptions   *bool   `json:"shuffle_options"`
 }
 
 // UpdateQuestionRequest defines the structure for updating an existing question.
@@ -40,11 +52,107 @@ type UpdateQuestionRequest struct {
 	Options       []QuestionOption `json:"options" validate:"omitempty"`
 	CorrectAnswer *string           `json:"correct_answer" validate:"omitempty"`
 	Timer         *int             `json:"timer,omitempty" validate:"omitempty,min=0"`
+	Explanation   *string          `json:"explanation,omitempty" validate:"omitempty,max=2000"`
+	Tags          []string         `json:"tags,omitempty" validate:"omitempty,max=20,dive,required,max=50"`
+	Difficulty    *string          `json:"difficulty,omitempty" validate:"omitempty,oneof=easy medium hard"`
+}
+
+// CreatePoolRequest defines a question pool: DrawCount questions are drawn at
+// random out of the quiz's questions that match it. QuestionUUIDs picks the
+// questions explicitly; otherwise they are matched by any of Tags and by
+// Difficulty, and a pool without either matches every question.
+type CreatePoolRequest struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	Tags          []string `json:"tags" validate:"omitempty,max=20,dive,required,max=50"`
+	Difficulty    string   `json:"difficulty" validate:"omitempty,oneof=easy medium hard"`
+	QuestionUUIDs []string `json:"question_uuids" validate:"omitempty,dive,uuid"`
+	DrawCount     int      `json:"draw_count" validate:"required,min=1"`
 }
 
 // StartQuizRequest defines the structure for starting a quiz.
 type StartQuizRequest struct {
 	Mode string `json:"mode" validate:"required,oneof=sync parallel"`
+	// Scoring overrides the quiz's default scoring strategy for this session.
+	Scoring string `json:"scoring" validate:"omitempty,oneof=first_correct all_correct speed streak negative"`
+	// TimeLimit optionally force-ends the session after this many seconds.
+	TimeLimit int `json:"time_limit" validate:"omitempty,min=1"`
+	// RevealSeconds is how long the results of each sync question are shown
+	// before the next one. Zero uses the server default.
+	RevealSeconds int `json:"reveal_seconds" validate:"omitempty,min=1,max=60"`
+}
+
+// LobbyResponse is returned when a teacher opens a new room for a quiz.
+type LobbyResponse struct {
+	RoomID       string     `json:"room_id"`
+	QuizUUID     string     `json:"quiz_uuid"`
+	PIN          string     `json:"pin,omitempty"`
+	PINExpiresAt *time.Time `json:"pin_expires_at,omitempty"`
+}
+
+// CreateAssignmentRequest defines the structure for assigning a quiz as homework.
+type CreateAssignmentRequest struct {
+	OpensAt time.Time `json:"opens_at" validate:"required"`
+	DueAt   time.Time `json:"due_at" validate:"required,gtfield=OpensAt"`
+	Scoring string    `json:"scoring" validate:"omitempty,oneof=first_correct all_correct speed streak negative"`
+}
+
+// SubmitAssignmentAnswerRequest defines the structure for answering a homework question.
+type SubmitAssignmentAnswerRequest struct {
+	QuestionID uint   `json:"question_id" validate:"required"`
+	Answer     string `json:"answer" validate:"required"`
+}
+
+// AssignmentQuestionResponse holds the next question of a homework attempt.
+type AssignmentQuestionResponse struct {
+	Question       *QuizQuestionDTO `json:"question,omitempty"`
+	QuestionIndex  int              `json:"question_index"`
+	TotalQuestions int              `json:"total_questions"`
+	Finished       bool             `json:"finished"`            // No questions are left
+	ServedAt       *time.Time       `json:"served_at,omitempty"` // When the question was first served; its answer time runs from here
+}
+
+// AssignmentAnswerResponse reports the grading of a homework answer.
+type AssignmentAnswerResponse struct {
+	QuestionID uint `json:"question_id"`
+	IsCorrect  bool `json:"is_correct"`
+	Points     int  `json:"points"`
+	Score      int  `json:"score"` // Total so far
+}
+
+// AssignmentAttemptResponse summarizes a student's homework attempt.
+type AssignmentAttemptResponse struct {
+	SessionID      uint       `json:"session_id"`
+	AssignmentUUID string     `json:"assignment_uuid"`
+	StartedAt      time.Time  `json:"started_at"`
+	EndedAt        *time.Time `json:"ended_at,omitempty"`
+	Answered       int        `json:"answered"`
+	TotalQuestions int        `json:"total_questions"`
+	Score          int        `json:"score"`
+}
+
+// GuestJoinRequest defines the structure for joining a room without an account.
+type GuestJoinRequest struct {
+	PIN      string `json:"pin" validate:"required_without=RoomID"`
+	RoomID   string `json:"room_id" validate:"required_without=PIN"`
+	Nickname string `json:"nickname" validate:"required,min=2,max=30"`
+}
+
+// WebsocketTicketResponse holds a one-time ticket for opening a room's
+// websocket from a browser.
+type WebsocketTicketResponse struct {
+	Ticket      string    `json:"ticket"`
+	Subprotocol string    `json:"subprotocol"` // Offer [subprotocol, ticket] as the websocket's protocols, or pass ?ticket=
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// GuestJoinResponse holds the token a guest connects to the room's websocket with.
+type GuestJoinResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	RoomID    string    `json:"room_id"`
+	QuizUUID  string    `json:"quiz_uuid"`
+	PlayerID  uint      `json:"player_id"` // The guest's user_id in room messages
+	Nickname  string    `json:"nickname"`
 }
 
 type QuizListResponse struct {
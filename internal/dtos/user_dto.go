@@ -3,8 +3,9 @@ package dtos
 import "exam/internal/model"
 
 type UpdateAccountRequest struct {
-	Name  string  `json:"name" validate:"required"`
-	Phone *string `json:"phone"`
+	Name       string  `json:"name" validate:"required"`
+	Phone      *string `json:"phone"`
+	AvatarUUID *string `json:"avatar_uuid" validate:"omitempty,uuid"`
 }
 
 type UpdatePasswordRequest struct {
@@ -21,4 +22,4 @@ type UserListResponse struct {
 	Total    int64        `json:"total"`
 	Page     int          `json:"page"`
 	PageSize int          `json:"pageSize"`
-}
\ No newline at end of file
+}
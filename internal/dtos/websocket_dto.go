@@ -1,6 +1,11 @@
 package dtos
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"exam/internal/model"
+	"fmt"
+	"time"
+)
 
 // WebsocketMessage is the generic structure for all websocket messages.
 type WebsocketMessage struct {
@@ -12,16 +17,61 @@ type WebsocketMessage struct {
 
 // SubmitAnswerPayload is the payload for a 'submit_answer' message.
 type SubmitAnswerPayload struct {
-	QuestionID uint   `json:"question_id"`
-	Answer     string `json:"answer"`
+	QuestionID uint   `json:"question_id" validate:"required"`
+	Answer     string `json:"answer" validate:"required,max=255"`
+}
+
+// KickPlayerPayload is the payload for a host's 'kick_player' message.
+type KickPlayerPayload struct {
+	UserID uint `json:"user_id" validate:"required"`
+}
+
+// ExtendTimerPayload is the payload for a host's 'extend_timer' message.
+type ExtendTimerPayload struct {
+	Seconds int `json:"seconds" validate:"required,min=1,max=600"`
+}
+
+// CreateTeamsPayload is the payload for a host's 'create_teams' message. Teams
+// are named after Names, or numbered when only Count is given.
+type CreateTeamsPayload struct {
+	Names   []string `json:"names" validate:"omitempty,dive,required,max=50"`
+	Count   int      `json:"count" validate:"min=0"`
+	Scoring string   `json:"scoring" validate:"omitempty,oneof=sum average best"` // How member scores add up: "sum" (default), "average" or "best"
+}
+
+// AssignTeamPayload is the payload for a host's 'assign_team' message.
+type AssignTeamPayload struct {
+	UserID uint   `json:"user_id" validate:"required"`
+	TeamID string `json:"team_id" validate:"required"`
 }
 
 // --- Server-to-Client Payloads ---
 
+// PlayerProfile is how a player is shown in a room.
+type PlayerProfile struct {
+	UserID    uint   `json:"user_id"`
+	Name      string `json:"name"`
+	Nickname  string `json:"nickname,omitempty"` // Chosen when joining, shown instead of the name
+	AvatarURL string `json:"avatar_url,omitempty"`
+	IsGuest   bool   `json:"is_guest,omitempty"`
+}
+
+// DisplayName is the nickname if the player picked one, else their name.
+func (p PlayerProfile) DisplayName() string {
+	if p.Nickname != "" {
+		return p.Nickname
+	}
+	if p.Name != "" {
+		return p.Name
+	}
+	return fmt.Sprintf("Player %d", p.UserID)
+}
+
 // PlayerInfoPayload is used for player join/leave notifications.
 type PlayerInfoPayload struct {
-	UserID   uint   `json:"user_id"`
-	UserName string `json:"user_name"`
+	UserID    uint   `json:"user_id"`
+	UserName  string `json:"user_name"`
+	AvatarURL string `json:"avatar_url,omitempty"`
 }
 
 // QuizQuestionDTO is a safe version of model.Question to be sent to clients (without the correct answer).
@@ -32,40 +82,246 @@ type QuizQuestionDTO struct {
 	Timer   int             `json:"timer"`
 }
 
+// NewQuizQuestionDTO strips the correct answer from a question before it is sent out.
+func NewQuizQuestionDTO(question model.Question) QuizQuestionDTO {
+	return QuizQuestionDTO{
+		ID:      question.ID,
+		Content: json.RawMessage(question.Content),
+		Options: json.RawMessage(question.Options),
+		Timer:   question.Timer,
+	}
+}
+
+// NextQuestionPayload is the payload of a 'next_question' message. The server's
+// clock is authoritative: answers are accepted up to Deadline plus a short grace
+// for network latency.
+type NextQuestionPayload struct {
+	QuizQuestionDTO
+	IssuedAt time.Time  `json:"issued_at"`
+	Deadline *time.Time `json:"deadline,omitempty"` // Nil if the question has no timer
+}
+
+// Reasons an answer is rejected.
+const (
+	AnswerRejectedWrongQuestion   = "wrong_question"
+	AnswerRejectedDeadlinePassed  = "deadline_passed"
+	AnswerRejectedAlreadyAnswered = "already_answered"
+)
+
+// AnswerRejectedPayload tells a player why their answer was not accepted.
+type AnswerRejectedPayload struct {
+	QuestionID        uint   `json:"question_id"`                   // The question the answer was sent for
+	CurrentQuestionID uint   `json:"current_question_id,omitempty"` // The question being asked now
+	Reason            string `json:"reason"`
+	Message           string `json:"message"`
+}
+
+// OptionCount is how many players picked an option.
+type OptionCount struct {
+	OptionID string `json:"option_id"`
+	Count    int    `json:"count"`
+}
+
+// QuestionResultsPayload reveals the answer to a sync question and how the
+// room answered it, between the question ending and the next one.
+type QuestionResultsPayload struct {
+	QuestionID       uint          `json:"question_id"`
+	CorrectOptionIDs []string      `json:"correct_option_ids"`
+	OptionCounts     []OptionCount `json:"option_counts"` // In the order of the question's options
+	TotalAnswers     int           `json:"total_answers"`
+	CorrectAnswers   int           `json:"correct_answers"`
+	PercentCorrect   float64       `json:"percent_correct"` // Of the answers given
+	AverageTimeMs    int64         `json:"average_time_ms"`
+	Explanation      string        `json:"explanation,omitempty"`
+	RevealSeconds    int           `json:"reveal_seconds"` // How long until the next question
+}
+
 // AnswerResultPayload announces the result of an answer submission.
 type AnswerResultPayload struct {
-	QuestionID    uint   `json:"question_id"`
-	IsCorrect     bool   `json:"is_correct"`
-	PlayerID      uint   `json:"player_id"` // The player who answered
-	PlayerName    string `json:"player_name"`
-	IsFirstAnswer bool   `json:"is_first_answer"`
+	QuestionID     uint   `json:"question_id"`
+	IsCorrect      bool   `json:"is_correct"`
+	PlayerID       uint   `json:"player_id"` // The player who answered
+	PlayerName     string `json:"player_name"`
+	IsFirstAnswer  bool   `json:"is_first_answer"`
+	Points         int    `json:"points"` // Points awarded for this answer
+	ResponseTimeMs int64  `json:"response_time_ms"`
 }
 
 // PlayerScore holds the score for a single player.
 type PlayerScore struct {
-	UserID   uint   `json:"user_id"`
-	UserName string `json:"user_name"`
+	UserID    uint   `json:"user_id"`
+	UserName  string `json:"user_name"`
+	Score     int    `json:"score"`
+	AvatarURL string `json:"avatar_url,omitempty"`
+	TeamID    string `json:"team_id,omitempty"`
+	IsGuest   bool   `json:"is_guest,omitempty"`
+}
+
+// TeamScore holds the aggregated score of a team.
+type TeamScore struct {
+	TeamID   string `json:"team_id"`
+	TeamName string `json:"team_name"`
 	Score    int    `json:"score"`
+	Members  []uint `json:"members"`
+}
+
+// TeamsPayload lists the teams of a room, e.g. for 'teams_updated'.
+type TeamsPayload struct {
+	Scoring string      `json:"scoring"`
+	Teams   []TeamScore `json:"teams"`
 }
 
 // ScoreUpdatePayload sends the current leaderboard.
 type ScoreUpdatePayload struct {
 	Scores []PlayerScore `json:"scores"`
+	Teams  []TeamScore   `json:"teams,omitempty"` // Team standings, best first
+}
+
+// ServerRestartPayload is sent right before the server closes every
+// connection to restart. A resumable game waits in the same room, paused,
+// for its players to reconnect once the server is back.
+type ServerRestartPayload struct {
+	Resumable bool `json:"resumable"`
 }
 
 // GameOverPayload announces the end of the game.
 type GameOverPayload struct {
-	Winner  PlayerScore   `json:"winner"`
-	Scores  []PlayerScore `json:"scores"`
+	Winner      PlayerScore   `json:"winner"`
+	Scores      []PlayerScore `json:"scores"`
+	WinningTeam *TeamScore    `json:"winning_team,omitempty"`
+	Teams       []TeamScore   `json:"teams,omitempty"`
 }
 
+// SessionResult is what a finished quiz session stores in FinalScores.
+type SessionResult struct {
+	Scores      []PlayerScore `json:"scores"`
+	TeamScoring string        `json:"team_scoring,omitempty"`
+	Teams       []TeamScore   `json:"teams,omitempty"`
+}
+
+// Codes of 'error' messages.
+const (
+	ErrorCodeInvalidMessage = "invalid_message" // The message is not a JSON {type, payload} object
+	ErrorCodeUnknownType    = "unknown_type"
+	ErrorCodeInvalidPayload = "invalid_payload"
+	ErrorCodeRateLimited    = "rate_limited"
+	ErrorCodeAbuse          = "abuse" // Sent right before the connection is closed for repeated violations
+	ErrorCodeForbidden      = "forbidden"
+	ErrorCodeInvalidState   = "invalid_state" // The message does not fit the current state of the room
+	ErrorCodeNotFound       = "not_found"
+	ErrorCodeInternal       = "internal_error"
+)
+
 // ErrorPayload sends an error message to a client.
 type ErrorPayload struct {
-	Message string `json:"message"`
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Type    string            `json:"type,omitempty"`   // Type of the message that was rejected, if known
+	Fields  map[string]string `json:"fields,omitempty"` // Payload fields that failed validation
+}
+
+// StateSnapshotPayload lets a reconnecting player pick up where they left off.
+type StateSnapshotPayload struct {
+	RoomID           string                  `json:"room_id"`
+	State            string                  `json:"state"`
+	Mode             string                  `json:"mode"`
+	Question         *QuizQuestionDTO        `json:"question,omitempty"`
+	QuestionIndex    int                     `json:"question_index"`
+	TotalQuestions   int                     `json:"total_questions"`
+	RemainingSeconds int                     `json:"remaining_seconds"`
+	Deadline         *time.Time              `json:"deadline,omitempty"`
+	Score            int                     `json:"score"`
+	HasAnswered      bool                    `json:"has_answered"`
+	Finished         bool                    `json:"finished"`
+	Scores           []PlayerScore           `json:"scores"`
+	Teams            []TeamScore             `json:"teams,omitempty"`
+	Results          *QuestionResultsPayload `json:"results,omitempty"` // Set while a sync question's results are shown
+}
+
+// TimerPayload reports the time left on the current question, e.g. for
+// 'game_paused', 'game_resumed' and 'timer_extended'.
+type TimerPayload struct {
+	QuestionID       uint       `json:"question_id"`
+	RemainingSeconds int        `json:"remaining_seconds"`
+	Deadline         *time.Time `json:"deadline,omitempty"` // Nil while paused or without a timer
+}
+
+// RoomStatsDTO is a snapshot of a live room.
+type RoomStatsDTO struct {
+	RoomID         string     `json:"room_id"`
+	QuizUUID       string     `json:"quiz_uuid"`
+	State          string     `json:"state"`
+	Mode           string     `json:"mode"`
+	PlayerCount    int        `json:"player_count"`
+	SpectatorCount int        `json:"spectator_count"`
+	HostConnected  bool       `json:"host_connected"`
+	QuestionIndex  int        `json:"question_index"`
+	TotalQuestions int        `json:"total_questions"`
+	CreatedAt      time.Time  `json:"created_at"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+	// Outbound is what clients too slow to keep up with the room cost it.
+	Outbound OutboundStatsDTO `json:"outbound"`
+}
+
+// OutboundStatsDTO counts the messages a room could not deliver as sent.
+type OutboundStatsDTO struct {
+	Dropped       int            `json:"dropped"` // Messages never sent
+	DroppedByType map[string]int `json:"dropped_by_type"`
+	Coalesced     int            `json:"coalesced"` // State messages replaced by a newer one before they were sent
+	Evicted       int            `json:"evicted"`   // Clients disconnected for falling behind
 }
 
 // ConnectedStudentDTO represents a connected student in a quiz room.
 type ConnectedStudentDTO struct {
+	UserID    uint   `json:"user_id"`
+	UserName  string `json:"user_name"` // The student's real name
+	Nickname  string `json:"nickname,omitempty"`
+	AvatarURL string `json:"avatar_url,omitempty"`
+	TeamID    string `json:"team_id,omitempty"`
+	IsGuest   bool   `json:"is_guest,omitempty"`
+	Presence  string `json:"presence,omitempty"` // "connected", "idle" or "unstable"
+}
+
+// StudentProgressDTO is one student's progress through a game.
+type StudentProgressDTO struct {
+	UserID        uint   `json:"user_id"`
+	UserName      string `json:"user_name"`
+	TeamID        string `json:"team_id,omitempty"`
+	IsGuest       bool   `json:"is_guest,omitempty"`
+	QuestionIndex int    `json:"question_index"` // The question the student is on
+	Correct       int    `json:"correct"`
+	Incorrect     int    `json:"incorrect"` // Includes questions the student ran out of time on
+	Score         int    `json:"score"`
+	TimeSpentMs   int64  `json:"time_spent_ms"` // Time spent on questions so far, pauses excluded
+	Finished      bool   `json:"finished"`
+	Status        string `json:"status"` // A presence, or "finished" or "left"
+}
+
+// MonitorPayload is the host's live view of every student, sent as
+// 'monitor_update' and served to teachers who poll.
+type MonitorPayload struct {
+	RoomID         string               `json:"room_id"`
+	State          string               `json:"state"`
+	Mode           string               `json:"mode"`
+	TotalQuestions int                  `json:"total_questions"`
+	Students       []StudentProgressDTO `json:"students"`
+	UpdatedAt      time.Time            `json:"updated_at"`
+}
+
+// PresencePayload announces a change in a player's connection, e.g. for 'presence_update'.
+type PresencePayload struct {
 	UserID   uint   `json:"user_id"`
-	UserName string `json:"user_name"`
+	Presence string `json:"presence"` // "connected", "idle", "unstable" or "disconnected"
+}
+
+// SessionEventDTO is one entry of a session's event log, as served by the
+// events API and sent by the replay stream in 'replay_event' messages.
+type SessionEventDTO struct {
+	Seq        int              `json:"seq"`
+	OccurredAt time.Time        `json:"occurred_at"`
+	Direction  string           `json:"direction"`          // "in" from a client, "out" from the room
+	Rejected   bool             `json:"rejected,omitempty"` // An "in" message the room turned down
+	UserID     *uint            `json:"user_id,omitempty"`
+	GuestID    *uint            `json:"guest_id,omitempty"`
+	Message    WebsocketMessage `json:"message"`
 }
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"errors"
+	"exam/internal/dtos"
+	"exam/internal/service"
+	"exam/internal/utils"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type AssignmentHandler struct {
+	assignmentService *service.AssignmentService
+}
+
+func NewAssignmentHandler(assignmentService *service.AssignmentService) *AssignmentHandler {
+	return &AssignmentHandler{assignmentService: assignmentService}
+}
+
+// assignmentErrorStatus maps assignment errors to the HTTP status they are reported with.
+func assignmentErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrQuizNotFound), errors.Is(err, service.ErrAssignmentNotFound), errors.Is(err, service.ErrAttemptNotStarted):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrAssignmentNotOpen), errors.Is(err, service.ErrAssignmentClosed):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrAttemptFinished), errors.Is(err, service.ErrUnexpectedQuestion):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrNoQuestionsInQuiz):
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+func (h *AssignmentHandler) CreateAssignment(c echo.Context) error {
+	quizUUID := c.Param("quizUUID")
+
+	req := new(dtos.CreateAssignmentRequest)
+	if err := c.Bind(req); err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+	}
+
+	lang := c.Request().Header.Get("Accept-Language")
+	if msg, ok := utils.ValidateStruct(req, lang); !ok {
+		return utils.ErrorResponse(c, http.StatusBadRequest, msg)
+	}
+
+	teacherID := c.Get("userID").(uint)
+
+	assignment, err := h.assignmentService.CreateAssignment(quizUUID, *req, teacherID)
+	if err != nil {
+		return utils.ErrorResponse(c, assignmentErrorStatus(err), err.Error())
+	}
+
+	return utils.SuccessResponse(c, "Assignment created successfully", assignment)
+}
+
+func (h *AssignmentHandler) StartAttempt(c echo.Context) error {
+	assignmentUUID := c.Param("assignmentUUID")
+	userID := c.Get("userID").(uint)
+
+	attempt, err := h.assignmentService.StartAttempt(assignmentUUID, userID)
+	if err != nil {
+		return utils.ErrorResponse(c, assignmentErrorStatus(err), err.Error())
+	}
+
+	return utils.SuccessResponse(c, "Assignment attempt started", attempt)
+}
+
+func (h *AssignmentHandler) NextQuestion(c echo.Context) error {
+	assignmentUUID := c.Param("assignmentUUID")
+	userID := c.Get("userID").(uint)
+
+	question, err := h.assignmentService.NextQuestion(assignmentUUID, userID)
+	if err != nil {
+		return utils.ErrorResponse(c, assignmentErrorStatus(err), err.Error())
+	}
+
+	return utils.SuccessResponse(c, "Next question retrieved successfully", question)
+}
+
+func (h *AssignmentHandler) SubmitAnswer(c echo.Context) error {
+	assignmentUUID := c.Param("assignmentUUID")
+
+	req := new(dtos.SubmitAssignmentAnswerRequest)
+	if err := c.Bind(req); err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+	}
+
+	lang := c.Request().Header.Get("Accept-Language")
+	if msg, ok := utils.ValidateStruct(req, lang); !ok {
+		return utils.ErrorResponse(c, http.StatusBadRequest, msg)
+	}
+
+	userID := c.Get("userID").(uint)
+
+	result, err := h.assignmentService.SubmitAnswer(assignmentUUID, userID, *req)
+	if err != nil {
+		return utils.ErrorResponse(c, assignmentErrorStatus(err), err.Error())
+	}
+
+	return utils.SuccessResponse(c, "Answer submitted successfully", result)
+}
+
+func (h *AssignmentHandler) FinishAttempt(c echo.Context) error {
+	assignmentUUID := c.Param("assignmentUUID")
+	userID := c.Get("userID").(uint)
+
+	attempt, err := h.assignmentService.FinishAttempt(assignmentUUID, userID)
+	if err != nil {
+		return utils.ErrorResponse(c, assignmentErrorStatus(err), err.Error())
+	}
+
+	return utils.SuccessResponse(c, "Assignment attempt finished", attempt)
+}
@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"errors"
+	"exam/internal/service"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestAssignmentErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{service.ErrQuizNotFound, http.StatusNotFound},
+		{service.ErrAssignmentNotFound, http.StatusNotFound},
+		{service.ErrAttemptNotStarted, http.StatusNotFound},
+		{service.ErrAssignmentNotOpen, http.StatusForbidden},
+		{service.ErrAssignmentClosed, http.StatusForbidden},
+		{service.ErrAttemptFinished, http.StatusConflict},
+		{service.ErrUnexpectedQuestion, http.StatusConflict},
+		{service.ErrNoQuestionsInQuiz, http.StatusUnprocessableEntity},
+		{fmt.Errorf("failed to get assignment: %w", service.ErrAssignmentNotFound), http.StatusNotFound},
+		{errors.New("failed to create assignment: connection refused"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := assignmentErrorStatus(tt.err); got != tt.want {
+			t.Errorf("assignmentErrorStatus(%q) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
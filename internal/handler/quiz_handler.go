@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"exam/internal/dtos"
 	"exam/internal/service"
 	"exam/internal/utils"
@@ -157,13 +158,112 @@ func (h *QuizHandler) UpdateQuestion(c echo.Context) error {
 	return utils.SuccessResponse(c, "Question updated successfully", updatedQuestion)
 }
 
+func (h *QuizHandler) OpenLobby(c echo.Context) error {
+	quizUUID := c.Param("quizUUID")
+	if quizUUID == "" {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Quiz UUID is required")
+	}
+
+	userID := c.Get("userID").(uint)
+	lobby, err := h.quizService.OpenLobby(quizUUID, userID)
+	if err != nil {
+		if errors.Is(err, service.ErrNotQuizCreator) {
+			return utils.ErrorResponse(c, http.StatusForbidden, err.Error())
+		}
+		return utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+	}
+
+	return utils.SuccessResponse(c, "Lobby opened successfully", lobby)
+}
+
+func (h *QuizHandler) ResolvePIN(c echo.Context) error {
+	pin := c.Param("pin")
+	if pin == "" {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "PIN is required")
+	}
+
+	lobby, err := h.quizService.ResolvePIN(pin)
+	if err != nil {
+		if errors.Is(err, service.ErrRoomNotFound) {
+			return utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		}
+		return utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+	}
+
+	return utils.SuccessResponse(c, "PIN resolved successfully", lobby)
+}
+
+// GetRoom returns a snapshot of a live room: its state, players and progress.
+func (h *QuizHandler) GetRoom(c echo.Context) error {
+	quizUUID := c.Param("quizUUID")
+	if quizUUID == "" {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Quiz UUID is required")
+	}
+
+	roomID := c.Param("roomID")
+	if roomID == "" {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Room ID is required")
+	}
+
+	userID := c.Get("userID").(uint)
+	stats, err := h.quizService.GetRoomStats(quizUUID, roomID, userID)
+	if err != nil {
+		if errors.Is(err, service.ErrRoomNotFound) {
+			return utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		}
+		if errors.Is(err, service.ErrNotQuizCreator) {
+			return utils.ErrorResponse(c, http.StatusForbidden, err.Error())
+		}
+		return utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+	}
+	return utils.SuccessResponse(c, "Room retrieved successfully", stats)
+}
+
+// GetRoomMonitor returns every student's progress for teachers who poll
+// instead of following the host's monitor_update stream.
+func (h *QuizHandler) GetRoomMonitor(c echo.Context) error {
+	quizUUID := c.Param("quizUUID")
+	if quizUUID == "" {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Quiz UUID is required")
+	}
+
+	roomID := c.Param("roomID")
+	if roomID == "" {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Room ID is required")
+	}
+
+	userID := c.Get("userID").(uint)
+	monitor, err := h.quizService.GetRoomMonitor(quizUUID, roomID, userID)
+	if err != nil {
+		if errors.Is(err, service.ErrRoomNotFound) {
+			return utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		}
+		if errors.Is(err, service.ErrNotQuizCreator) {
+			return utils.ErrorResponse(c, http.StatusForbidden, err.Error())
+		}
+		return utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+	}
+	return utils.SuccessResponse(c, "Room monitor retrieved successfully", monitor)
+}
+
 func (h *QuizHandler) GetStudentCount(c echo.Context) error {
 	quizUUID := c.Param("quizUUID")
 	if quizUUID == "" {
 		return utils.ErrorResponse(c, http.StatusBadRequest, "Quiz UUID is required")
 	}
 
-	count := h.quizService.GetStudentCount(quizUUID)
+	roomID := c.Param("roomID")
+	if roomID == "" {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Room ID is required")
+	}
+
+	count, err := h.quizService.GetStudentCount(quizUUID, roomID)
+	if err != nil {
+		if errors.Is(err, service.ErrRoomNotFound) {
+			return utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		}
+		return utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+	}
 	return utils.SuccessResponse(c, "Student count retrieved successfully", map[string]int{"count": count})
 }
 
@@ -173,7 +273,18 @@ func (h *QuizHandler) ListStudents(c echo.Context) error {
 		return utils.ErrorResponse(c, http.StatusBadRequest, "Quiz UUID is required")
 	}
 
-	students := h.quizService.ListConnectedStudents(quizUUID)
+	roomID := c.Param("roomID")
+	if roomID == "" {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Room ID is required")
+	}
+
+	students, err := h.quizService.ListConnectedStudents(quizUUID, roomID)
+	if err != nil {
+		if errors.Is(err, service.ErrRoomNotFound) {
+			return utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		}
+		return utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+	}
 	return utils.SuccessResponse(c, "Connected students retrieved successfully", students)
 }
 
@@ -183,6 +294,11 @@ func (h *QuizHandler) StartQuiz(c echo.Context) error {
 		return utils.ErrorResponse(c, http.StatusBadRequest, "Quiz UUID is required")
 	}
 
+	roomID := c.Param("roomID")
+	if roomID == "" {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Room ID is required")
+	}
+
 	req := new(dtos.StartQuizRequest)
 	if err := c.Bind(req); err != nil {
 		return utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
@@ -193,10 +309,128 @@ func (h *QuizHandler) StartQuiz(c echo.Context) error {
 		return utils.ErrorResponse(c, http.StatusBadRequest, msg)
 	}
 
-	err := h.quizService.StartQuiz(quizUUID, *req)
+	userID := c.Get("userID").(uint)
+	err := h.quizService.StartQuiz(quizUUID, roomID, userID, *req)
 	if err != nil {
+		if errors.Is(err, service.ErrRoomNotFound) {
+			return utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		}
+		if errors.Is(err, service.ErrNotQuizCreator) {
+			return utils.ErrorResponse(c, http.StatusForbidden, err.Error())
+		}
+		if errors.Is(err, service.ErrGameInProgress) {
+			return utils.ErrorResponse(c, http.StatusConflict, err.Error())
+		}
 		return utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
 	}
 
 	return utils.SuccessResponse(c, "Quiz started successfully", nil)
 }
+
+// JoinAsGuest lets a player without an account join a room with a nickname.
+func (h *QuizHandler) JoinAsGuest(c echo.Context) error {
+	req := new(dtos.GuestJoinRequest)
+	if err := c.Bind(req); err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+	}
+
+	lang := c.Request().Header.Get("Accept-Language")
+	if msg, ok := utils.ValidateStruct(req, lang); !ok {
+		return utils.ErrorResponse(c, http.StatusBadRequest, msg)
+	}
+
+	guest, err := h.quizService.JoinAsGuest(*req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrRoomNotFound):
+			return utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		case errors.Is(err, service.ErrNicknameTaken):
+			return utils.ErrorResponse(c, http.StatusConflict, err.Error())
+		case errors.Is(err, service.ErrNicknameNotAllowed):
+			return utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		}
+		return utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+	}
+
+	return utils.SuccessResponse(c, "Joined as guest successfully", guest)
+}
+
+func (h *QuizHandler) CreatePool(c echo.Context) error {
+	quizUUID := c.Param("quizUUID")
+
+	req := new(dtos.CreatePoolRequest)
+	if err := c.Bind(req); err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+	}
+
+	lang := c.Request().Header.Get("Accept-Language")
+	if msg, ok := utils.ValidateStruct(req, lang); !ok {
+		return utils.ErrorResponse(c, http.StatusBadRequest, msg)
+	}
+
+	pool, err := h.quizService.CreatePool(quizUUID, *req)
+	if err != nil {
+		if errors.Is(err, service.ErrPoolQuestionNotFound) {
+			return utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		}
+		return utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+	}
+
+	return utils.SuccessResponse(c, "Question pool created successfully", pool)
+}
+
+func (h *QuizHandler) ListPools(c echo.Context) error {
+	quizUUID := c.Param("quizUUID")
+
+	pools, err := h.quizService.ListPools(quizUUID)
+	if err != nil {
+		return utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+	}
+
+	return utils.SuccessResponse(c, "Question pools retrieved successfully", pools)
+}
+
+func (h *QuizHandler) DeletePool(c echo.Context) error {
+	quizUUID := c.Param("quizUUID")
+	poolUUID := c.Param("poolUUID")
+
+	if err := h.quizService.DeletePool(quizUUID, poolUUID); err != nil {
+		if errors.Is(err, service.ErrPoolNotFound) {
+			return utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		}
+		return utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+	}
+
+	return utils.SuccessResponse(c, "Question pool deleted successfully", nil)
+}
+
+// ListSessionEvents pages through a session's event log. Pass the last seq you
+// got as ?after_seq= to get the next page; ?limit=1 steps one event at a time.
+func (h *QuizHandler) ListSessionEvents(c echo.Context) error {
+	quizUUID := c.Param("quizUUID")
+	sessionID, err := strconv.ParseUint(c.Param("sessionID"), 10, 32)
+	if err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Invalid session ID")
+	}
+
+	afterSeq := 0
+	if value := c.QueryParam("after_seq"); value != "" {
+		if afterSeq, err = strconv.Atoi(value); err != nil || afterSeq < 0 {
+			return utils.ErrorResponse(c, http.StatusBadRequest, "after_seq must be a sequence number")
+		}
+	}
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+
+	userID := c.Get("userID").(uint)
+	events, err := h.quizService.ListSessionEvents(quizUUID, uint(sessionID), userID, afterSeq, limit)
+	if err != nil {
+		if errors.Is(err, service.ErrSessionNotFound) {
+			return utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		}
+		if errors.Is(err, service.ErrNotQuizCreator) {
+			return utils.ErrorResponse(c, http.StatusForbidden, err.Error())
+		}
+		return utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+	}
+	return utils.SuccessResponse(c, "Session events retrieved successfully", events)
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"exam/internal/i18n"
+	"exam/internal/model"
+	"exam/internal/repository"
+	"exam/internal/service"
+	appWebsocket "exam/internal/websocket"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// stubQuizRepository holds a single quiz, created by user 1, and a session
+// of it without events.
+type stubQuizRepository struct {
+	repository.QuizRepository
+}
+
+func (stubQuizRepository) GetQuizByUUID(string) (*model.Quiz, error) {
+	return &model.Quiz{ID: 1, UUID: "quiz", CreatedBy: 1}, nil
+}
+
+func (stubQuizRepository) GetQuizSessionByID(sessionID uint) (*model.QuizSession, error) {
+	return &model.QuizSession{ID: sessionID, QuizUUID: "quiz"}, nil
+}
+
+func (stubQuizRepository) GetSessionEvents(uint, int, int) ([]model.QuizSessionEvent, error) {
+	return nil, nil
+}
+
+// newTestContext returns a context for a request by userID, with the path
+// parameters given as name and value pairs.
+func newTestContext(method string, target string, body string, userID uint, params ...string) (echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	var names, values []string
+	for i := 0; i+1 < len(params); i += 2 {
+		names, values = append(names, params[i]), append(values, params[i+1])
+	}
+	c.SetParamNames(names...)
+	c.SetParamValues(values...)
+	c.Set("userID", userID)
+	return c, rec
+}
+
+func TestStartQuizByAnotherTeacher(t *testing.T) {
+	i18n.Init()
+	handler := NewQuizHandler(service.NewQuizService(stubQuizRepository{}, nil, nil, nil))
+
+	c, rec := newTestContext(http.MethodPost, "/", `{"mode":"sync"}`, 2, "quizUUID", "quiz", "roomID", "room")
+	if err := handler.StartQuiz(c); err != nil {
+		t.Fatalf("StartQuiz returned %v", err)
+	}
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("StartQuiz by another teacher = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestSessionEventAccess(t *testing.T) {
+	quizService := service.NewQuizService(stubQuizRepository{}, nil, nil, nil)
+	quizHandler := NewQuizHandler(quizService)
+	websocketHandler := NewWebsocketHandler(appWebsocket.NewHubWithBackplane(appWebsocket.DefaultConfig(), appWebsocket.NewMemoryBackplane()), quizService)
+
+	tests := []struct {
+		name   string
+		target string
+		userID uint
+		want   int
+	}{
+		{"quiz creator", "/", 1, http.StatusOK},
+		{"another teacher", "/", 2, http.StatusForbidden},
+		{"bad after_seq", "/?after_seq=ten", 1, http.StatusBadRequest},
+		{"negative after_seq", "/?after_seq=-1", 1, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, rec := newTestContext(http.MethodGet, tt.target, "", tt.userID, "quizUUID", "quiz", "sessionID", "7")
+			if err := quizHandler.ListSessionEvents(c); err != nil {
+				t.Fatalf("ListSessionEvents returned %v", err)
+			}
+			if rec.Code != tt.want {
+				t.Errorf("ListSessionEvents = %d, want %d", rec.Code, tt.want)
+			}
+
+			// The replay is refused the same way before the upgrade; a
+			// plain request it may open is refused by the upgrader instead.
+			c, rec = newTestContext(http.MethodGet, tt.target, "", tt.userID, "quizUUID", "quiz", "sessionID", "7")
+			websocketHandler.ServeReplay(c)
+			want := tt.want
+			if want == http.StatusOK {
+				want = http.StatusBadRequest
+			}
+			if rec.Code != want {
+				t.Errorf("ServeReplay = %d, want %d", rec.Code, want)
+			}
+		})
+	}
+}
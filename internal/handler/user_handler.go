@@ -32,7 +32,10 @@ func (h *UserHandler) UpdateAccount(c echo.Context) error {
 		return utils.ErrorResponse(c, http.StatusBadRequest, msg)
 	}
 
-	if err := h.authService.UpdateUserAccount(userID, req.Name, req.Phone); err != nil {
+	if err := h.authService.UpdateUserAccount(userID, req.Name, req.Phone, req.AvatarUUID); err != nil {
+		if errors.Is(err, service.ErrInvalidAvatar) {
+			return utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		}
 		if errors.Is(err, service.ErrDatabase) {
 			return utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
 		}
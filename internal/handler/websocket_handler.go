@@ -1,67 +1,236 @@
 package handler
 
 import (
+	"errors"
+	"exam/internal/dtos"
 	"exam/internal/service"
+	"exam/internal/utils"
 	appWebsocket "exam/internal/websocket"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	ws "github.com/gorilla/websocket"
 	"github.com/labstack/echo/v4"
 )
 
-var upgrader = ws.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all connections for now
-	},
-}
+const maxNicknameLength = 30
+
+// spectatorRole is the ?role= of connections that watch a room without playing.
+const spectatorRole = "spectator"
+
+const maxReplaySpeed = 20
 
 // WebsocketHandler handles the websocket connection requests.
 type WebsocketHandler struct {
 	hub         *appWebsocket.Hub
 	quizService *service.QuizService
+	upgrader    ws.Upgrader
 }
 
 func NewWebsocketHandler(hub *appWebsocket.Hub, quizService *service.QuizService) *WebsocketHandler {
-	return &WebsocketHandler{hub: hub, quizService: quizService}
+	return &WebsocketHandler{
+		hub:         hub,
+		quizService: quizService,
+		upgrader: ws.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin:     hub.CheckOrigin,
+			// Accepted so browsers can pass their ticket as a subprotocol.
+			Subprotocols: []string{appWebsocket.TicketSubprotocol},
+		},
+	}
+}
+
+// IssueTicket trades the caller's JWT for a one-time ticket to open a
+// websocket with, as browsers cannot send the Authorization header there.
+func (h *WebsocketHandler) IssueTicket(c echo.Context) error {
+	claims, ok := c.Get("claims").(map[string]interface{})
+	if !ok {
+		return utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid token claims")
+	}
+
+	ticket, expiresAt, err := h.hub.IssueTicket(claims)
+	if err != nil {
+		log.Printf("Error issuing websocket ticket: %v", err)
+		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to issue websocket ticket")
+	}
+
+	return utils.SuccessResponse(c, "Websocket ticket issued successfully", dtos.WebsocketTicketResponse{
+		Ticket:      ticket,
+		Subprotocol: appWebsocket.TicketSubprotocol,
+		ExpiresAt:   expiresAt,
+	})
 }
 
 // ServeWs handles websocket requests from the peer.
 func (h *WebsocketHandler) ServeWs(c echo.Context) error {
-	quizUUID := c.Param("quizUUID")
-	if quizUUID == "" {
-		return c.String(http.StatusBadRequest, "Quiz UUID is required")
+	roomID := c.Param("roomID")
+	if roomID == "" {
+		return c.String(http.StatusBadRequest, "Room ID is required")
 	}
 
-	conn, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
-	if err != nil {
-		log.Println(err)
-		return err
+	return h.serveRoom(c, roomID)
+}
+
+// ServeWsByPIN joins the room behind a lobby game PIN.
+func (h *WebsocketHandler) ServeWsByPIN(c echo.Context) error {
+	pin := c.Param("pin")
+	if pin == "" {
+		return c.String(http.StatusBadRequest, "PIN is required")
+	}
+
+	roomID, ok := h.hub.ResolvePIN(pin)
+	if !ok {
+		return c.String(http.StatusNotFound, "Invalid or expired PIN")
 	}
 
-	room, exists := h.hub.Rooms[quizUUID]
-	if !exists {
-		room = appWebsocket.NewRoom(quizUUID, h.quizService)
-		h.hub.Register <- room
-		go room.Run()
+	return h.serveRoom(c, roomID)
+}
+
+func (h *WebsocketHandler) serveRoom(c echo.Context, roomID string) error {
+	// Rooms are opened by the teacher through the lobby endpoint, on any node.
+	if !h.hub.HasRoom(roomID) {
+		return c.String(http.StatusNotFound, "Quiz room not found")
 	}
 
 	userID := c.Get("userID").(uint)
 
+	// A classroom projector joins with ?role=spectator to show the game without
+	// playing it; teachers and the quiz creator may, students may not.
+	switch c.QueryParam("role") {
+	case "", "player":
+	case spectatorRole:
+		role, _ := c.Get("userRole").(string)
+		allowed, err := h.quizService.CanSpectate(roomID, userID, role)
+		if errors.Is(err, service.ErrRoomNotFound) {
+			return c.String(http.StatusNotFound, "Quiz room not found")
+		}
+		if err != nil {
+			log.Printf("Error checking whether user %d may spectate room %s: %v", userID, roomID, err)
+			return c.String(http.StatusInternalServerError, "Failed to check spectator access")
+		}
+		if !allowed {
+			return c.String(http.StatusForbidden, "Only teachers and the quiz creator can spectate")
+		}
+		return h.connect(c, roomID, dtos.PlayerProfile{UserID: userID}, true)
+	default:
+		return c.String(http.StatusBadRequest, "Role must be player or spectator")
+	}
+
+	// Players may pick a nickname for the game with ?nickname=.
+	nickname := strings.TrimSpace(c.QueryParam("nickname"))
+	if utf8.RuneCountInString(nickname) > maxNicknameLength {
+		return c.String(http.StatusBadRequest, "Nickname is too long")
+	}
+
+	profile, err := h.quizService.ResolvePlayerProfile(userID, nickname)
+	if err != nil {
+		log.Printf("Error resolving profile for user %d: %v", userID, err)
+		return c.String(http.StatusInternalServerError, "Failed to load player profile")
+	}
+
+	return h.connect(c, roomID, profile, false)
+}
+
+// ServeGuestWs joins a guest to the room their guest token was issued for. It
+// sits outside the JWT middleware and is the only route guest tokens open.
+func (h *WebsocketHandler) ServeGuestWs(c echo.Context) error {
+	// Browsers cannot set headers on websocket requests, so the token comes in the query.
+	tokenString := c.QueryParam("token")
+	if tokenString == "" {
+		return c.String(http.StatusUnauthorized, "Guest token is required")
+	}
+
+	claims, err := service.ParseGuestToken(tokenString)
+	if err != nil {
+		return c.String(http.StatusUnauthorized, err.Error())
+	}
+
+	if !h.hub.HasRoom(claims.RoomID) {
+		return c.String(http.StatusNotFound, "Quiz room not found")
+	}
+
+	profile := dtos.PlayerProfile{UserID: claims.PlayerID, Nickname: claims.Nickname, IsGuest: true}
+	return h.connect(c, claims.RoomID, profile, false)
+}
+
+// connect upgrades the request and hands the new client to the room, which
+// may live on another node.
+func (h *WebsocketHandler) connect(c echo.Context, roomID string, profile dtos.PlayerProfile, spectator bool) error {
+	conn, err := h.upgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
 	client := &appWebsocket.Client{
-		Room:   room,
-		Conn:   conn,
-		Send:   make(chan []byte, 256),
-		UserID: userID,
+		Conn:      conn,
+		Send:      make(chan []byte, 256),
+		UserID:    profile.UserID,
+		Profile:   profile,
+		Spectator: spectator,
 	}
 
-	client.Room.Register <- client
+	if !h.hub.Join(roomID, client) {
+		conn.Close()
+		return nil
+	}
 
 	go client.WritePump()
 	go client.ReadPump()
 
-	log.Printf("Client %d connected to quiz %s", userID, quizUUID)
+	log.Printf("Client %d connected to room %s", client.UserID, roomID)
+	return nil
+}
+
+// ServeReplay streams a finished or running session's event log back with its
+// original pacing. ?speed= speeds it up, ?after_seq= skips ahead and
+// ?paused=true starts paused, to step through it, see appWebsocket.Replay.
+func (h *WebsocketHandler) ServeReplay(c echo.Context) error {
+	quizUUID := c.Param("quizUUID")
+	sessionID, err := strconv.ParseUint(c.Param("sessionID"), 10, 32)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "Invalid session ID")
+	}
+
+	speed := 1.0
+	if value := c.QueryParam("speed"); value != "" {
+		if speed, err = strconv.ParseFloat(value, 64); err != nil || speed <= 0 || speed > maxReplaySpeed {
+			return c.String(http.StatusBadRequest, fmt.Sprintf("Speed must be above 0 and at most %d", maxReplaySpeed))
+		}
+	}
+	afterSeq := 0
+	if value := c.QueryParam("after_seq"); value != "" {
+		if afterSeq, err = strconv.Atoi(value); err != nil || afterSeq < 0 {
+			return c.String(http.StatusBadRequest, "after_seq must be a sequence number")
+		}
+	}
+	paused := c.QueryParam("paused") == "true"
+
+	userID := c.Get("userID").(uint)
+	if _, err := h.quizService.ListSessionEvents(quizUUID, uint(sessionID), userID, afterSeq, 1); err != nil {
+		if errors.Is(err, service.ErrSessionNotFound) {
+			return c.String(http.StatusNotFound, err.Error())
+		}
+		if errors.Is(err, service.ErrNotQuizCreator) {
+			return c.String(http.StatusForbidden, err.Error())
+		}
+		log.Printf("Error reading event log of session %d: %v", sessionID, err)
+		return c.String(http.StatusInternalServerError, "Failed to read the event log")
+	}
+
+	conn, err := h.upgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	events := func(afterSeq int, limit int) ([]dtos.SessionEventDTO, error) {
+		return h.quizService.ListSessionEvents(quizUUID, uint(sessionID), userID, afterSeq, limit)
+	}
+	go appWebsocket.Replay(conn, events, afterSeq, speed, paused)
 	return nil
 }
@@ -5,7 +5,6 @@ import (
 	"exam/internal/utils"
 	"fmt"
 	"net/http"
-	"os"
 	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -28,17 +27,12 @@ func JWTAuthMiddleware(deviceRepo repository.DeviceRepository) echo.MiddlewareFu
 
 			tokenString := headerParts[1]
 
-			jwtSecret := os.Getenv("JWT_SECRET")
-			if jwtSecret == "" {
-				jwtSecret = "supersecretjwtkey" // Should match the one in auth_service.go
-			}
-
 			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 				// Don't forget to validate the alg is what you expect: `jwt.SigningMethodHS256`
 				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 					return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 				}
-				return []byte(jwtSecret), nil
+				return utils.JWTSecret(), nil
 			})
 
 			if err != nil {
@@ -46,40 +40,47 @@ func JWTAuthMiddleware(deviceRepo repository.DeviceRepository) echo.MiddlewareFu
 			}
 
 			if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-				// Check if JTI is valid in the devices table
-				jti, jtiOk := claims["jti"].(string)
-				if !jtiOk || jti == "" {
-					return utils.ErrorResponse(c, http.StatusUnauthorized, "JTI claim missing or invalid")
-				}
+				return authenticate(c, next, deviceRepo, claims)
+			}
 
-				device, err := deviceRepo.GetDeviceByJTI(jti) // Use GetDeviceByJTI
-				if err != nil {
-					return utils.ErrorResponse(c, http.StatusInternalServerError, "Database error during JTI validation")
-				}
-				if device == nil {
-					return utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid JTI: Device not found")
-				}
+			return utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid token claims")
+		}
+	}
+}
 
-				if device.LogoutAt != nil {
-					return utils.ErrorResponse(c, http.StatusUnauthorized, "Token has been logged out")
-				}
+// authenticate checks that the device a token was issued to is still logged
+// in and sets the user information in the context.
+func authenticate(c echo.Context, next echo.HandlerFunc, deviceRepo repository.DeviceRepository, claims jwt.MapClaims) error {
+	// Check if JTI is valid in the devices table
+	jti, jtiOk := claims["jti"].(string)
+	if !jtiOk || jti == "" {
+		return utils.ErrorResponse(c, http.StatusUnauthorized, "JTI claim missing or invalid")
+	}
 
-				// Set user information in context
-				userID, ok := claims["id"].(float64)
-				if !ok {
-					return utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid user ID in token")
-				}
-				c.Set("userID", uint(userID))
-				c.Set("uuid", claims["uuid"])
-				c.Set("userEmail", claims["email"])
-				c.Set("userRole", claims["role"])
-				c.Set("jti", jti)
-				c.Set("exp", claims["exp"])
+	device, err := deviceRepo.GetDeviceByJTI(jti) // Use GetDeviceByJTI
+	if err != nil {
+		return utils.ErrorResponse(c, http.StatusInternalServerError, "Database error during JTI validation")
+	}
+	if device == nil {
+		return utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid JTI: Device not found")
+	}
 
-				return next(c)
-			}
+	if device.LogoutAt != nil {
+		return utils.ErrorResponse(c, http.StatusUnauthorized, "Token has been logged out")
+	}
 
-			return utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid token claims")
-		}
+	// Set user information in context
+	userID, ok := claims["id"].(float64)
+	if !ok {
+		return utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid user ID in token")
 	}
+	c.Set("userID", uint(userID))
+	c.Set("uuid", claims["uuid"])
+	c.Set("userEmail", claims["email"])
+	c.Set("userRole", claims["role"])
+	c.Set("jti", jti)
+	c.Set("exp", claims["exp"])
+	c.Set("claims", map[string]interface{}(claims))
+
+	return next(c)
 }
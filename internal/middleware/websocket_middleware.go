@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"exam/internal/repository"
+	"exam/internal/utils"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// WebsocketTickets redeems the one-time tickets browsers open websockets
+// with, as they cannot set the Authorization header on the handshake.
+type WebsocketTickets interface {
+	// TicketFromRequest returns the ticket a websocket handshake carries, or "".
+	TicketFromRequest(r *http.Request) string
+	// RedeemTicket returns the claims of the token a ticket was issued for,
+	// and uses the ticket up.
+	RedeemTicket(ticket string) (map[string]interface{}, bool)
+}
+
+// WebsocketAuthMiddleware authenticates the websocket routes. A handshake
+// without an Authorization header may carry a ticket from tickets instead,
+// whose token's device is checked all the same; anything else goes through
+// JWTAuthMiddleware.
+func WebsocketAuthMiddleware(deviceRepo repository.DeviceRepository, tickets WebsocketTickets) echo.MiddlewareFunc {
+	jwtAuth := JWTAuthMiddleware(deviceRepo)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		withJWT := jwtAuth(next)
+		return func(c echo.Context) error {
+			ticket := tickets.TicketFromRequest(c.Request())
+			if ticket == "" || c.Request().Header.Get("Authorization") != "" {
+				return withJWT(c)
+			}
+
+			claims, ok := tickets.RedeemTicket(ticket)
+			if !ok {
+				return utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid or expired websocket ticket")
+			}
+			return authenticate(c, next, deviceRepo, claims)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"exam/internal/model"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type fakeDeviceRepository struct {
+	devices map[string]*model.Device
+}
+
+func (r fakeDeviceRepository) CreateDevice(*model.Device) error { return nil }
+func (r fakeDeviceRepository) UpdateDevice(*model.Device) error { return nil }
+func (r fakeDeviceRepository) GetDeviceByRefreshToken(string) (*model.Device, error) {
+	return nil, nil
+}
+func (r fakeDeviceRepository) GetDeviceByJTI(jti string) (*model.Device, error) {
+	return r.devices[jti], nil
+}
+func (r fakeDeviceRepository) ListUserDevices(uint) ([]model.Device, error) { return nil, nil }
+func (r fakeDeviceRepository) ForceDisconnectDevice(uint, string) (int64, error) {
+	return 0, nil
+}
+
+// fakeTickets takes the ticket from ?ticket= and hands out each one once.
+type fakeTickets map[string]map[string]interface{}
+
+func (t fakeTickets) TicketFromRequest(r *http.Request) string {
+	return r.URL.Query().Get("ticket")
+}
+
+func (t fakeTickets) RedeemTicket(ticket string) (map[string]interface{}, bool) {
+	claims, ok := t[ticket]
+	delete(t, ticket)
+	return claims, ok
+}
+
+func TestWebsocketAuthMiddleware(t *testing.T) {
+	loggedOut := time.Now()
+	deviceRepo := fakeDeviceRepository{devices: map[string]*model.Device{
+		"live": {JTI: "live"},
+		"gone": {JTI: "gone", LogoutAt: &loggedOut},
+	}}
+	tickets := fakeTickets{
+		"good":       {"id": float64(3), "jti": "live", "role": "student"},
+		"logged-out": {"id": float64(4), "jti": "gone", "role": "student"},
+		"unused":     {"id": float64(5), "jti": "live", "role": "student"},
+	}
+
+	e := echo.New()
+	handler := WebsocketAuthMiddleware(deviceRepo, tickets)(func(c echo.Context) error {
+		return c.String(http.StatusOK, "ok")
+	})
+
+	tests := []struct {
+		name          string
+		ticket        string
+		authorization string
+		wantStatus    int
+		wantUserID    interface{}
+	}{
+		{name: "valid ticket", ticket: "good", wantStatus: http.StatusOK, wantUserID: uint(3)},
+		{name: "ticket used twice", ticket: "good", wantStatus: http.StatusUnauthorized},
+		{name: "unknown ticket", ticket: "forged", wantStatus: http.StatusUnauthorized},
+		{name: "ticket of a logged out device", ticket: "logged-out", wantStatus: http.StatusUnauthorized},
+		{name: "no ticket falls back to the JWT", wantStatus: http.StatusUnauthorized},
+		{name: "authorization header wins over a ticket", ticket: "unused", authorization: "Bearer not-a-jwt", wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/quiz/join/room?ticket="+tt.ticket, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if err := handler(c); err != nil {
+				t.Fatalf("handler returned %v", err)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if got := c.Get("userID"); got != tt.wantUserID {
+				t.Errorf("userID = %v, want %v", got, tt.wantUserID)
+			}
+		})
+	}
+
+	if _, ok := tickets["unused"]; !ok {
+		t.Error("a request with an Authorization header used up its ticket")
+	}
+}
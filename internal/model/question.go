@@ -14,6 +14,9 @@ type Question struct {
 	Content        datatypes.JSON `gorm:"type:json" json:"content"` // Changed from QuestionText
 	Options        datatypes.JSON `gorm:"type:json" json:"options"`
 	CorrectAnswer  string         `gorm:"type:varchar(255)" json:"correct_answer"`
+	Explanation    *string        `gorm:"type:text" json:"explanation,omitempty"` // Shown to players once the answer is revealed
+	Tags           datatypes.JSON `gorm:"type:json" json:"tags,omitempty"`           // Free-form labels that question pools draw by
+	Difficulty     string         `gorm:"type:varchar(20)" json:"difficulty,omitempty"` // "easy", "medium" or "hard"
 	Timer          int            `gorm:"not null;default:30" json:"timer"`
 	CreatedAt      time.Time      `json:"created_at"`
 	UpdatedAt      time.Time      `json:"updated_at"`
@@ -4,13 +4,17 @@ import "time"
 
 // Quiz represents a collection of questions created by a teacher
 type Quiz struct {
-	ID          uint       `gorm:"primaryKey" json:"id"`
-	UUID        string     `gorm:"type:varchar(36);uniqueIndex" json:"uuid"`
-	Title       string     `gorm:"type:varchar(255)" json:"title"`
-	Description string     `gorm:"type:text" json:"description"`
-	CreatedBy   uint       `json:"created_by"` // Foreign key to User ID
-	Creator     User       `gorm:"foreignKey:CreatedBy" json:"creator"`
-	Questions   []Question `gorm:"foreignKey:QuizID" json:"questions,omitempty"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	UUID             string     `gorm:"type:varchar(36);uniqueIndex" json:"uuid"`
+	Title            string     `gorm:"type:varchar(255)" json:"title"`
+	Description      string     `gorm:"type:text" json:"description"`
+	CreatedBy        uint       `json:"created_by"`                                         // Foreign key to User ID
+	ScoringStrategy  string     `gorm:"type:varchar(30)" json:"scoring_strategy,omitempty"` // Default scoring for new sessions
+	ShuffleQuestions bool       `gorm:"not null;default:false" json:"shuffle_questions"`    // Each player gets their own question order in parallel and async games
+	ShuffleOptions   bool       `gorm:"not null;default:false" json:"shuffle_options"`      // Each player gets their own option order
+	Creator          User       `gorm:"foreignKey:CreatedBy" json:"creator"`
+	Questions        []Question `gorm:"foreignKey:QuizID" json:"questions,omitempty"`
+	Pools            []QuizPool `gorm:"foreignKey:QuizID" json:"pools,omitempty"` // If set, sessions are played from questions drawn out of these pools
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
 }
@@ -6,11 +6,14 @@ import (
 
 // QuizAnswer represents a single answer submitted by a participant for a question in a quiz session.
 type QuizAnswer struct {
-	ID            uint      `gorm:"primaryKey" json:"id"`
-	QuizSessionID uint      `gorm:"not null" json:"quiz_session_id"`
-	QuestionID    uint      `gorm:"not null" json:"question_id"`
-	UserID        uint      `gorm:"not null" json:"user_id"`
-	Answer        string    `gorm:"type:varchar(255)" json:"answer"` // The ID of the option chosen by the user
-	IsCorrect     bool      `gorm:"not null" json:"is_correct"`
-	SubmittedAt   time.Time `gorm:"not null" json:"submitted_at"`
-}
\ No newline at end of file
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	QuizSessionID  uint      `gorm:"not null" json:"quiz_session_id"`
+	QuestionID     uint      `gorm:"not null" json:"question_id"`
+	UserID         *uint     `json:"user_id,omitempty"`               // Nil for guests
+	GuestID        *uint     `json:"guest_id,omitempty"`              // Set instead of UserID for guests
+	Answer         string    `gorm:"type:varchar(255)" json:"answer"` // The ID of the option chosen by the user
+	IsCorrect      bool      `gorm:"not null" json:"is_correct"`
+	Points         int       `gorm:"not null;default:0" json:"points"`           // Points awarded by the session's scoring strategy
+	ResponseTimeMs int64     `gorm:"not null;default:0" json:"response_time_ms"` // Time from the question being asked to the answer, pauses excluded
+	SubmittedAt    time.Time `gorm:"not null" json:"submitted_at"`
+}
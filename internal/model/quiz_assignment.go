@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// QuizAssignment is a quiz handed out as homework. Students take it at their own
+// pace over REST between OpensAt and DueAt, each in their own QuizSession.
+type QuizAssignment struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	UUID            string    `gorm:"type:varchar(36);uniqueIndex" json:"uuid"`
+	QuizUUID        string    `gorm:"type:varchar(36);not null" json:"quiz_uuid"`
+	AssignedBy      uint      `gorm:"not null" json:"assigned_by"` // Foreign key to User ID
+	ScoringStrategy string    `gorm:"type:varchar(30)" json:"scoring_strategy"`
+	OpensAt         time.Time `gorm:"not null" json:"opens_at"`
+	DueAt           time.Time `gorm:"not null" json:"due_at"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
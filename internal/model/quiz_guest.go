@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// QuizGuest is a player who joined a room with a nickname instead of an account.
+type QuizGuest struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UUID      string    `gorm:"type:varchar(36);uniqueIndex" json:"uuid"`
+	RoomID    string    `gorm:"type:varchar(36);not null" json:"room_id"`
+	QuizUUID  string    `gorm:"type:varchar(36);not null" json:"quiz_uuid"`
+	Nickname  string    `gorm:"type:varchar(30);not null" json:"nickname"`
+	CreatedAt time.Time `json:"created_at"`
+}
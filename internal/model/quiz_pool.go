@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// QuizPool is a rule for drawing questions at random: every session, or every
+// player in parallel and async games, gets DrawCount of the quiz's questions
+// that match the pool. A quiz with pools is played from the drawn questions only.
+type QuizPool struct {
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	UUID          string         `gorm:"type:varchar(36);uniqueIndex" json:"uuid"`
+	QuizID        uint           `gorm:"not null" json:"quiz_id"`
+	Name          string         `gorm:"type:varchar(100);not null" json:"name"`
+	Tags          datatypes.JSON `gorm:"type:json" json:"tags,omitempty"`              // Questions with any of these tags match
+	Difficulty    string         `gorm:"type:varchar(20)" json:"difficulty,omitempty"` // Questions of this difficulty match
+	QuestionUUIDs datatypes.JSON `gorm:"type:json" json:"question_uuids,omitempty"`    // An explicit subset; takes precedence over tags and difficulty
+	DrawCount     int            `gorm:"not null" json:"draw_count"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+// QuizSessionDraw records the questions drawn from a quiz's pools for a
+// session, so its answers can be audited against what was actually asked.
+// Both UserID and GuestID are nil when the draw is shared by everyone in the
+// session, as in sync games.
+type QuizSessionDraw struct {
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	QuizSessionID uint           `gorm:"not null" json:"quiz_session_id"`
+	UserID        *uint          `json:"user_id,omitempty"`
+	GuestID       *uint          `json:"guest_id,omitempty"`
+	QuestionIDs   datatypes.JSON `gorm:"type:json;not null" json:"question_ids"` // In the order they were drawn
+	CreatedAt     time.Time      `json:"created_at"`
+}
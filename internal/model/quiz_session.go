@@ -8,11 +8,22 @@ import (
 
 // QuizSession represents a single instance of a quiz being played.
 type QuizSession struct {
-	ID          uint           `gorm:"primaryKey" json:"id"`
-	QuizUUID    string         `gorm:"type:varchar(36);not null" json:"quiz_uuid"`
-	Mode        string         `gorm:"type:varchar(20);not null;default:'sync'" json:"mode"`
-	StartedAt   time.Time      `json:"started_at"`
-	EndedAt     *time.Time     `json:"ended_at,omitempty"`
-	Participants datatypes.JSON `gorm:"type:json" json:"participants"` // Stores JSON array of ConnectedStudentDTO
-	FinalScores datatypes.JSON `gorm:"type:json" json:"final_scores"`   // Stores JSON array of PlayerScore
-}
\ No newline at end of file
+	ID               uint           `gorm:"primaryKey" json:"id"`
+	QuizUUID         string         `gorm:"type:varchar(36);not null" json:"quiz_uuid"`
+	RoomID           string         `gorm:"type:varchar(36)" json:"room_id"`
+	Mode             string         `gorm:"type:varchar(20);not null;default:'sync'" json:"mode"` // "sync", "parallel" or "async"
+	AssignmentID     *uint          `json:"assignment_id,omitempty"`                              // Set for async homework attempts
+	UserID           *uint          `json:"user_id,omitempty"`                                    // The student taking an async attempt
+	ScoringStrategy  string         `gorm:"type:varchar(30)" json:"scoring_strategy"`             // Strategy the final scores were computed with
+	TimeLimit        int            `json:"time_limit,omitempty"`                                 // Overall limit in seconds; 0 means none
+	RevealSeconds    int            `json:"reveal_seconds,omitempty"`                             // How long sync question results are shown; 0 means the default
+	ShuffleSeed      int64          `json:"shuffle_seed,omitempty"`                               // Seeds every player's question and option order, see service.NewShuffle
+	StartedAt        time.Time      `json:"started_at"`
+	EndedAt          *time.Time     `json:"ended_at,omitempty"`
+	QuestionServedAt *time.Time     `json:"question_served_at,omitempty"`  // When an async attempt's current question was served; nil once it is answered
+	Participants     datatypes.JSON `gorm:"type:json" json:"participants"` // Stores JSON array of ConnectedStudentDTO
+	FinalScores      datatypes.JSON `gorm:"type:json" json:"final_scores"` // Stores a SessionResult: player scores and, in team games, team results
+	Checkpoint       datatypes.JSON `gorm:"type:json" json:"-"`            // Room state saved on shutdown, so the game can be restored
+	CheckpointedAt   *time.Time     `json:"checkpointed_at,omitempty"`
+	Aborted          bool           `gorm:"not null;default:false" json:"aborted"` // The game was cut short by a server restart; FinalScores are partial
+}
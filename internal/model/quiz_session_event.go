@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Directions of a QuizSessionEvent.
+const (
+	EventDirectionIn  = "in"  // Sent by a client to the room
+	EventDirectionOut = "out" // Sent by the room
+)
+
+// QuizSessionEvent is one websocket message of a live game, in the order the
+// room handled it. UserID and GuestID tell whose message it was, or who it
+// was sent to; both are nil for broadcasts and for actions from the REST API.
+type QuizSessionEvent struct {
+	ID            uint64         `gorm:"primaryKey" json:"id"`
+	QuizSessionID uint           `gorm:"not null" json:"quiz_session_id"`
+	Seq           int            `gorm:"not null" json:"seq"` // Position in the session's log, from 1
+	Direction     string         `gorm:"type:varchar(3);not null" json:"direction"`
+	UserID        *uint          `json:"user_id,omitempty"`
+	GuestID       *uint          `json:"guest_id,omitempty"`
+	Type          string         `gorm:"type:varchar(50);not null" json:"type"`
+	Payload       datatypes.JSON `gorm:"type:json" json:"payload"`
+	Rejected      bool           `gorm:"not null;default:false" json:"rejected"`       // An inbound message the room turned down
+	OccurredAt    time.Time      `gorm:"type:datetime(3);not null" json:"occurred_at"` // Server time
+}
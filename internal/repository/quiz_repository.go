@@ -2,6 +2,7 @@ package repository
 
 import (
 	"exam/internal/model"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -19,7 +20,21 @@ type QuizRepository interface {
 			CreateQuizSession(session *model.QuizSession) error
 			UpdateQuizSession(session *model.QuizSession) error
 			GetQuizSessionByID(sessionID uint) (*model.QuizSession, error)
+			GetOpenRoomSessions() ([]model.QuizSession, error)
 			CreateQuizAnswer(answer *model.QuizAnswer) error
+			GetQuizAnswersBySessionID(sessionID uint) ([]model.QuizAnswer, error)
+			CreateAssignment(assignment *model.QuizAssignment) error
+			GetAssignmentByUUID(uuid string) (*model.QuizAssignment, error)
+			GetAssignmentSession(assignmentID uint, userID uint) (*model.QuizSession, error)
+			SetQuestionServedAt(sessionID uint, servedAt *time.Time) error
+			CreateGuest(guest *model.QuizGuest) error
+			DeleteGuest(guestID uint) error
+			CreatePool(pool *model.QuizPool) error
+			DeletePool(quizID uint, poolUUID string) (bool, error)
+			CreateSessionDraw(draw *model.QuizSessionDraw) error
+			GetSessionDraws(sessionID uint) ([]model.QuizSessionDraw, error)
+			CreateSessionEvents(events []model.QuizSessionEvent) error
+			GetSessionEvents(sessionID uint, afterSeq int, limit int) ([]model.QuizSessionEvent, error)
 		}
 		
 		
@@ -50,7 +65,9 @@ type QuizRepository interface {
 		
 		func (r *quizRepository) GetQuizWithQuestionsByUUID(uuid string) (*model.Quiz, error) {
 			var quiz model.Quiz
-			err := r.db.Preload("Questions").Preload("Creator").Where("uuid = ?", uuid).First(&quiz).Error
+			err := r.db.Preload("Questions").Preload("Pools", func(db *gorm.DB) *gorm.DB {
+				return db.Order("id")
+			}).Preload("Creator").Where("uuid = ?", uuid).First(&quiz).Error
 			if err != nil {
 				return nil, err
 			}
@@ -116,11 +133,101 @@ type QuizRepository interface {
 			var session model.QuizSession
 			err := r.db.First(&session, sessionID).Error
 			if err != nil {
+				if err == gorm.ErrRecordNotFound {
+					return nil, nil
+				}
 				return nil, err
 			}
 			return &session, nil
 		}
 		
+		// GetOpenRoomSessions returns the live room sessions that have not ended.
+		// Async homework attempts are left out, they do not live in a room.
+		func (r *quizRepository) GetOpenRoomSessions() ([]model.QuizSession, error) {
+			var sessions []model.QuizSession
+			err := r.db.Where("ended_at IS NULL AND mode <> ?", "async").Order("id").Find(&sessions).Error
+			return sessions, err
+		}
+		
 		func (r *quizRepository) CreateQuizAnswer(answer *model.QuizAnswer) error {
 			return r.db.Create(answer).Error
 		}
+		
+		func (r *quizRepository) GetQuizAnswersBySessionID(sessionID uint) ([]model.QuizAnswer, error) {
+			var answers []model.QuizAnswer
+			err := r.db.Where("quiz_session_id = ?", sessionID).Order("id").Find(&answers).Error
+			return answers, err
+		}
+		
+		func (r *quizRepository) CreateAssignment(assignment *model.QuizAssignment) error {
+			return r.db.Create(assignment).Error
+		}
+		
+		func (r *quizRepository) GetAssignmentByUUID(uuid string) (*model.QuizAssignment, error) {
+			var assignment model.QuizAssignment
+			err := r.db.Where("uuid = ?", uuid).First(&assignment).Error
+			if err != nil {
+				if err == gorm.ErrRecordNotFound {
+					return nil, nil
+				}
+				return nil, err
+			}
+			return &assignment, nil
+		}
+		
+		func (r *quizRepository) GetAssignmentSession(assignmentID uint, userID uint) (*model.QuizSession, error) {
+			var session model.QuizSession
+			err := r.db.Where("assignment_id = ? AND user_id = ?", assignmentID, userID).First(&session).Error
+			if err != nil {
+				if err == gorm.ErrRecordNotFound {
+					return nil, nil
+				}
+				return nil, err
+			}
+			return &session, nil
+		}
+		
+		// SetQuestionServedAt only touches that column, as the student's other
+		// requests may be updating the session at the same time.
+		func (r *quizRepository) SetQuestionServedAt(sessionID uint, servedAt *time.Time) error {
+			return r.db.Model(&model.QuizSession{}).Where("id = ?", sessionID).Update("question_served_at", servedAt).Error
+		}
+		
+		func (r *quizRepository) CreateGuest(guest *model.QuizGuest) error {
+			return r.db.Create(guest).Error
+		}
+		
+		func (r *quizRepository) DeleteGuest(guestID uint) error {
+			return r.db.Delete(&model.QuizGuest{}, guestID).Error
+		}
+		
+		func (r *quizRepository) CreatePool(pool *model.QuizPool) error {
+			return r.db.Create(pool).Error
+		}
+		
+		// DeletePool reports false if the quiz has no pool with that UUID.
+		func (r *quizRepository) DeletePool(quizID uint, poolUUID string) (bool, error) {
+			result := r.db.Where("quiz_id = ? AND uuid = ?", quizID, poolUUID).Delete(&model.QuizPool{})
+			return result.RowsAffected > 0, result.Error
+		}
+		
+		func (r *quizRepository) CreateSessionDraw(draw *model.QuizSessionDraw) error {
+			return r.db.Create(draw).Error
+		}
+		
+		func (r *quizRepository) GetSessionDraws(sessionID uint) ([]model.QuizSessionDraw, error) {
+			var draws []model.QuizSessionDraw
+			err := r.db.Where("quiz_session_id = ?", sessionID).Order("id").Find(&draws).Error
+			return draws, err
+		}
+		
+		func (r *quizRepository) CreateSessionEvents(events []model.QuizSessionEvent) error {
+			return r.db.Create(&events).Error
+		}
+		
+		// GetSessionEvents returns up to limit events of a session that come after afterSeq, in order.
+		func (r *quizRepository) GetSessionEvents(sessionID uint, afterSeq int, limit int) ([]model.QuizSessionEvent, error) {
+			var events []model.QuizSessionEvent
+			err := r.db.Where("quiz_session_id = ? AND seq > ?", sessionID, afterSeq).Order("seq").Limit(limit).Find(&events).Error
+			return events, err
+		}
@@ -6,7 +6,7 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
-func APIRoutes(g *echo.Group, authHandler *handler.AuthHandler, accountHandler *handler.AccountHandler, userHandler *handler.UserHandler, quizHandler *handler.QuizHandler, websocketHandler *handler.WebsocketHandler, fileHandler *handler.FileHandler) {
+func APIRoutes(g *echo.Group, authHandler *handler.AuthHandler, accountHandler *handler.AccountHandler, userHandler *handler.UserHandler, quizHandler *handler.QuizHandler, websocketHandler *handler.WebsocketHandler, fileHandler *handler.FileHandler, assignmentHandler *handler.AssignmentHandler) {
 	g.GET("/account", accountHandler.GetAccountInfo)
 	g.PUT("/account", userHandler.UpdateAccount)
 	g.PUT("/password", userHandler.UpdatePassword)
@@ -26,12 +26,28 @@ func APIRoutes(g *echo.Group, authHandler *handler.AuthHandler, accountHandler *
 	g.PUT("/quizzes/:quizUUID", quizHandler.UpdateQuiz)
 	g.POST("/quizzes/:quizUUID/questions", quizHandler.AddQuestion)
 	g.PUT("/quizzes/:quizUUID/questions/:questionUUID", quizHandler.UpdateQuestion)
-	g.GET("/quizzes/:quizUUID/students/count", quizHandler.GetStudentCount)
-	g.GET("/quizzes/:quizUUID/students", quizHandler.ListStudents)
-	g.POST("/quizzes/:quizUUID/start", quizHandler.StartQuiz)
+	g.GET("/quizzes/:quizUUID/pools", quizHandler.ListPools)
+	g.POST("/quizzes/:quizUUID/pools", quizHandler.CreatePool)
+	g.DELETE("/quizzes/:quizUUID/pools/:poolUUID", quizHandler.DeletePool)
+	g.POST("/quizzes/:quizUUID/rooms", quizHandler.OpenLobby)
+	g.GET("/quizzes/:quizUUID/rooms/:roomID", quizHandler.GetRoom)
+	g.GET("/quizzes/:quizUUID/rooms/:roomID/monitor", quizHandler.GetRoomMonitor)
+	g.GET("/quizzes/:quizUUID/rooms/:roomID/students/count", quizHandler.GetStudentCount)
+	g.GET("/quizzes/:quizUUID/rooms/:roomID/students", quizHandler.ListStudents)
+	g.POST("/quizzes/:quizUUID/rooms/:roomID/start", quizHandler.StartQuiz)
+	g.GET("/quizzes/:quizUUID/sessions/:sessionID/events", quizHandler.ListSessionEvents)
+
+	g.GET("/quiz/pin/:pin", quizHandler.ResolvePIN)
+
+	// Homework assignment routes
+	g.POST("/quizzes/:quizUUID/assignments", assignmentHandler.CreateAssignment)
+	g.POST("/assignments/:assignmentUUID/attempt", assignmentHandler.StartAttempt)
+	g.GET("/assignments/:assignmentUUID/attempt/question", assignmentHandler.NextQuestion)
+	g.POST("/assignments/:assignmentUUID/attempt/answers", assignmentHandler.SubmitAnswer)
+	g.POST("/assignments/:assignmentUUID/attempt/finish", assignmentHandler.FinishAttempt)
 
 	// Websocket route
-	g.GET("/quiz/join/:quizUUID", websocketHandler.ServeWs)
+	g.POST("/quiz/ws-ticket", websocketHandler.IssueTicket)
 
 	// File upload route
 	g.POST("/upload", fileHandler.UploadFile)
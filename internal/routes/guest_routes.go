@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"exam/internal/handler"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GuestRoutes registers the routes for players without an account. They sit
+// outside the JWT group: guests authenticate with a room-scoped guest token.
+func GuestRoutes(e *echo.Echo, quizHandler *handler.QuizHandler, websocketHandler *handler.WebsocketHandler) {
+	e.POST("/quiz/guest/join", quizHandler.JoinAsGuest)
+	e.GET("/quiz/guest/ws", websocketHandler.ServeGuestWs)
+}
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"exam/internal/handler"
+
+	"github.com/labstack/echo/v4"
+)
+
+// WebsocketRoutes registers the websocket routes of the API. They are kept
+// out of the JWT group because their auth middleware also accepts a one-time
+// ticket, which browsers open websockets with; only these routes get it.
+func WebsocketRoutes(e *echo.Echo, websocketHandler *handler.WebsocketHandler, auth ...echo.MiddlewareFunc) {
+	g := e.Group("/api/v1")
+	g.GET("/quiz/join/:roomID", websocketHandler.ServeWs, auth...)
+	g.GET("/quiz/join/pin/:pin", websocketHandler.ServeWsByPIN, auth...)
+	g.GET("/quizzes/:quizUUID/sessions/:sessionID/replay", websocketHandler.ServeReplay, auth...)
+}
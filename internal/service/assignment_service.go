@@ -0,0 +1,400 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"exam/internal/dtos"
+	"exam/internal/model"
+	"exam/internal/repository"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrQuizNotFound       = errors.New("quiz not found")
+	ErrAssignmentNotFound = errors.New("assignment not found")
+	ErrAssignmentNotOpen  = errors.New("assignment is not open yet")
+	ErrAssignmentClosed   = errors.New("assignment is past its due date")
+	ErrAttemptNotStarted  = errors.New("assignment attempt has not been started")
+	ErrAttemptFinished    = errors.New("assignment attempt is already finished")
+	ErrUnexpectedQuestion = errors.New("answer is not for the current question")
+	ErrNoQuestionsInQuiz  = errors.New("quiz has no questions")
+)
+
+// asyncMode is the QuizSession mode of homework attempts.
+const asyncMode = "async"
+
+// AssignmentService runs quizzes as homework: each student takes the quiz over
+// REST in their own QuizSession, one question at a time, in quiz order. Quizzes
+// with question pools give every student their own draw.
+type AssignmentService struct {
+	quizRepo repository.QuizRepository
+	userRepo repository.UserRepository
+}
+
+func NewAssignmentService(quizRepo repository.QuizRepository, userRepo repository.UserRepository) *AssignmentService {
+	return &AssignmentService{quizRepo: quizRepo, userRepo: userRepo}
+}
+
+// CreateAssignment assigns a quiz as homework between req.OpensAt and req.DueAt.
+func (s *AssignmentService) CreateAssignment(quizUUID string, req dtos.CreateAssignmentRequest, teacherID uint) (*model.QuizAssignment, error) {
+	quiz, err := s.quizRepo.GetQuizByUUID(quizUUID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrQuizNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quiz by UUID: %w", err)
+	}
+	if quiz == nil {
+		return nil, ErrQuizNotFound
+	}
+
+	assignment := &model.QuizAssignment{
+		UUID:            uuid.New().String(),
+		QuizUUID:        quiz.UUID,
+		AssignedBy:      teacherID,
+		ScoringStrategy: ResolveScoringStrategy(req.Scoring, quiz.ScoringStrategy, asyncMode),
+		OpensAt:         req.OpensAt,
+		DueAt:           req.DueAt,
+	}
+	if err := s.quizRepo.CreateAssignment(assignment); err != nil {
+		return nil, fmt.Errorf("failed to create assignment: %w", err)
+	}
+	return assignment, nil
+}
+
+// StartAttempt opens the student's session for the assignment. Starting again
+// returns the existing attempt so a student can continue where they left off.
+func (s *AssignmentService) StartAttempt(assignmentUUID string, userID uint) (*dtos.AssignmentAttemptResponse, error) {
+	assignment, err := s.getOpenAssignment(assignmentUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.quizRepo.GetAssignmentSession(assignment.ID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get assignment attempt: %w", err)
+	}
+	if session == nil {
+		user, err := s.userRepo.GetUserByID(userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+		participants := []dtos.ConnectedStudentDTO{{UserID: userID}}
+		if user != nil {
+			participants[0].UserName = user.Name
+		}
+		participantsJSON, _ := json.Marshal(participants)
+
+		session = &model.QuizSession{
+			QuizUUID:        assignment.QuizUUID,
+			Mode:            asyncMode,
+			AssignmentID:    &assignment.ID,
+			UserID:          &userID,
+			ScoringStrategy: assignment.ScoringStrategy,
+			ShuffleSeed:     NewShuffleSeed(),
+			StartedAt:       time.Now(),
+			Participants:    datatypes.JSON(participantsJSON),
+		}
+		if err := s.quizRepo.CreateQuizSession(session); errors.Is(err, gorm.ErrDuplicatedKey) {
+			// Started at the same time by another request; continue with its session.
+			if session, err = s.getSession(assignment, userID); err != nil {
+				return nil, err
+			}
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to create quiz session: %w", err)
+		}
+	}
+
+	return s.attemptSummary(assignment, session)
+}
+
+// NextQuestion returns the first question the student has not answered yet.
+// The question's answer time starts the first time it is served; serving it
+// again, e.g. after a reload, does not restart it.
+func (s *AssignmentService) NextQuestion(assignmentUUID string, userID uint) (*dtos.AssignmentQuestionResponse, error) {
+	assignment, session, err := s.getActiveAttempt(assignmentUUID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	quiz, answers, err := s.loadProgress(assignment, session)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &dtos.AssignmentQuestionResponse{
+		QuestionIndex:  len(answers),
+		TotalQuestions: len(quiz.Questions),
+	}
+	if len(answers) >= len(quiz.Questions) {
+		resp.Finished = true
+		return resp, nil
+	}
+	if session.QuestionServedAt == nil {
+		now := time.Now()
+		if err := s.quizRepo.SetQuestionServedAt(session.ID, &now); err != nil {
+			return nil, fmt.Errorf("failed to record question served time: %w", err)
+		}
+		session.QuestionServedAt = &now
+	}
+
+	// Students get the questions and options in their own order, if the quiz shuffles them.
+	question := dtos.NewQuizQuestionDTO(NewShuffle(quiz, session.ShuffleSeed, userID).Question(quiz.Questions, len(answers)))
+	resp.Question = &question
+	resp.ServedAt = session.QuestionServedAt
+	return resp, nil
+}
+
+// SubmitAnswer grades the answer to the current question, scores it with the
+// assignment's strategy and records it against the student's session. The
+// answer time runs from when NextQuestion served the question. The question's
+// timer is not enforced: a late answer is still graded, and only scores as
+// slow where the strategy rewards speed.
+func (s *AssignmentService) SubmitAnswer(assignmentUUID string, userID uint, req dtos.SubmitAssignmentAnswerRequest) (*dtos.AssignmentAnswerResponse, error) {
+	assignment, session, err := s.getActiveAttempt(assignmentUUID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	quiz, answers, err := s.loadProgress(assignment, session)
+	if err != nil {
+		return nil, err
+	}
+	if len(answers) >= len(quiz.Questions) {
+		return nil, ErrAttemptFinished
+	}
+	question := NewShuffle(quiz, session.ShuffleSeed, userID).Question(quiz.Questions, len(answers))
+	if req.QuestionID != question.ID {
+		return nil, ErrUnexpectedQuestion
+	}
+
+	strategy, err := NewScoringStrategy(session.ScoringStrategy)
+	if err != nil {
+		return nil, err
+	}
+
+	// The streak follows the room's rules: it counts correct answers in a row.
+	streak, score := 0, 0
+	askedAt := session.StartedAt
+	for _, answer := range answers {
+		if answer.IsCorrect {
+			streak++
+		} else {
+			streak = 0
+		}
+		score += answer.Points
+		askedAt = answer.SubmittedAt
+	}
+	// A question answered without being served is timed from the previous
+	// answer, which never makes it look faster than it was.
+	if session.QuestionServedAt != nil {
+		askedAt = *session.QuestionServedAt
+	}
+
+	now := time.Now()
+	responseTime := now.Sub(askedAt)
+	isCorrect := IsCorrectAnswer(question, req.Answer)
+	points := strategy.Points(AnswerContext{
+		IsCorrect:      isCorrect,
+		IsFirstCorrect: true, // Nobody else plays in a homework attempt
+		ResponseTime:   responseTime,
+		TimeLimit:      time.Duration(question.Timer) * time.Second,
+		Streak:         streak,
+	})
+
+	answer := &model.QuizAnswer{
+		QuizSessionID:  session.ID,
+		QuestionID:     question.ID,
+		UserID:         &userID,
+		Answer:         req.Answer,
+		IsCorrect:      isCorrect,
+		Points:         points,
+		ResponseTimeMs: responseTime.Milliseconds(),
+		SubmittedAt:    now,
+	}
+	if err := s.quizRepo.CreateQuizAnswer(answer); errors.Is(err, gorm.ErrDuplicatedKey) {
+		// Another request answered this question first.
+		return nil, ErrUnexpectedQuestion
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to record quiz answer: %w", err)
+	}
+	// The next question's time starts once it is served. The answer is in
+	// either way, so a failure here only costs the next answer some speed.
+	if err := s.quizRepo.SetQuestionServedAt(session.ID, nil); err != nil {
+		log.Printf("Error resetting question served time of session %d: %v", session.ID, err)
+	}
+
+	return &dtos.AssignmentAnswerResponse{
+		QuestionID: question.ID,
+		IsCorrect:  isCorrect,
+		Points:     points,
+		Score:      score + points,
+	}, nil
+}
+
+// FinishAttempt closes the student's session and stores their final score.
+// Unanswered questions score nothing.
+func (s *AssignmentService) FinishAttempt(assignmentUUID string, userID uint) (*dtos.AssignmentAttemptResponse, error) {
+	assignment, err := s.getAssignment(assignmentUUID)
+	if err != nil {
+		return nil, err
+	}
+	session, err := s.getSession(assignment, userID)
+	if err != nil {
+		return nil, err
+	}
+	if session.EndedAt != nil {
+		return nil, ErrAttemptFinished
+	}
+
+	answers, err := s.quizRepo.GetQuizAnswersBySessionID(session.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quiz answers: %w", err)
+	}
+	score := dtos.PlayerScore{UserID: userID}
+	for _, answer := range answers {
+		score.Score += answer.Points
+	}
+	if user, err := s.userRepo.GetUserByID(userID); err == nil && user != nil {
+		score.UserName = user.Name
+	}
+
+	now := time.Now()
+	finalScoresJSON, _ := json.Marshal(dtos.SessionResult{Scores: []dtos.PlayerScore{score}})
+	session.EndedAt = &now
+	session.FinalScores = datatypes.JSON(finalScoresJSON)
+	if err := s.quizRepo.UpdateQuizSession(session); err != nil {
+		return nil, fmt.Errorf("failed to update quiz session: %w", err)
+	}
+
+	return s.attemptSummary(assignment, session)
+}
+
+func (s *AssignmentService) getAssignment(assignmentUUID string) (*model.QuizAssignment, error) {
+	assignment, err := s.quizRepo.GetAssignmentByUUID(assignmentUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get assignment: %w", err)
+	}
+	if assignment == nil {
+		return nil, ErrAssignmentNotFound
+	}
+	return assignment, nil
+}
+
+// getOpenAssignment returns the assignment if it is currently inside its window.
+func (s *AssignmentService) getOpenAssignment(assignmentUUID string) (*model.QuizAssignment, error) {
+	assignment, err := s.getAssignment(assignmentUUID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if now.Before(assignment.OpensAt) {
+		return nil, ErrAssignmentNotOpen
+	}
+	if now.After(assignment.DueAt) {
+		return nil, ErrAssignmentClosed
+	}
+	return assignment, nil
+}
+
+func (s *AssignmentService) getSession(assignment *model.QuizAssignment, userID uint) (*model.QuizSession, error) {
+	session, err := s.quizRepo.GetAssignmentSession(assignment.ID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get assignment attempt: %w", err)
+	}
+	if session == nil {
+		return nil, ErrAttemptNotStarted
+	}
+	return session, nil
+}
+
+// getActiveAttempt returns the student's unfinished session of an open assignment.
+func (s *AssignmentService) getActiveAttempt(assignmentUUID string, userID uint) (*model.QuizAssignment, *model.QuizSession, error) {
+	assignment, err := s.getOpenAssignment(assignmentUUID)
+	if err != nil {
+		return nil, nil, err
+	}
+	session, err := s.getSession(assignment, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if session.EndedAt != nil {
+		return nil, nil, ErrAttemptFinished
+	}
+	return assignment, session, nil
+}
+
+// loadProgress returns the quiz with the questions of the attempt and the
+// answers given so far.
+func (s *AssignmentService) loadProgress(assignment *model.QuizAssignment, session *model.QuizSession) (*model.Quiz, []model.QuizAnswer, error) {
+	quiz, err := s.quizRepo.GetQuizWithQuestionsByUUID(assignment.QuizUUID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get quiz: %w", err)
+	}
+	if quiz.Questions, err = s.attemptQuestions(quiz, session); err != nil {
+		return nil, nil, err
+	}
+	if len(quiz.Questions) == 0 {
+		return nil, nil, ErrNoQuestionsInQuiz
+	}
+	answers, err := s.quizRepo.GetQuizAnswersBySessionID(session.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get quiz answers: %w", err)
+	}
+	return quiz, answers, nil
+}
+
+// attemptQuestions returns the questions of the attempt. A quiz with pools is
+// drawn from once per attempt and the draw is recorded, so the attempt keeps
+// its questions even if the pools change later on.
+func (s *AssignmentService) attemptQuestions(quiz *model.Quiz, session *model.QuizSession) ([]model.Question, error) {
+	draws, err := s.quizRepo.GetSessionDraws(session.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get question draw: %w", err)
+	}
+	if len(draws) > 0 {
+		var questionIDs []uint
+		if err := json.Unmarshal(draws[0].QuestionIDs, &questionIDs); err != nil {
+			return nil, fmt.Errorf("failed to read question draw: %w", err)
+		}
+		return QuestionsByID(quiz, questionIDs), nil
+	}
+	if len(quiz.Pools) == 0 {
+		return quiz.Questions, nil
+	}
+
+	var userID uint
+	if session.UserID != nil {
+		userID = *session.UserID
+	}
+	questions := DrawQuestions(quiz, session.ShuffleSeed, userID)
+	if err := s.quizRepo.CreateSessionDraw(NewSessionDraw(session.ID, userID, questions)); err != nil {
+		return nil, fmt.Errorf("failed to record question draw: %w", err)
+	}
+	return questions, nil
+}
+
+func (s *AssignmentService) attemptSummary(assignment *model.QuizAssignment, session *model.QuizSession) (*dtos.AssignmentAttemptResponse, error) {
+	quiz, answers, err := s.loadProgress(assignment, session)
+	if err != nil {
+		return nil, err
+	}
+	resp := &dtos.AssignmentAttemptResponse{
+		SessionID:      session.ID,
+		AssignmentUUID: assignment.UUID,
+		StartedAt:      session.StartedAt,
+		EndedAt:        session.EndedAt,
+		Answered:       len(answers),
+		TotalQuestions: len(quiz.Questions),
+	}
+	for _, answer := range answers {
+		resp.Score += answer.Points
+	}
+	return resp, nil
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"errors"
+	"exam/internal/dtos"
+	"exam/internal/model"
+	"exam/internal/repository"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// racingQuizRepository plays an assignment whose attempt, or answer, was just
+// written by a concurrent request: its inserts fail on the unique keys.
+type racingQuizRepository struct {
+	repository.QuizRepository
+	assignment *model.QuizAssignment
+	quiz       *model.Quiz
+	session    *model.QuizSession // Written by the other request once a lookup missed
+	answers    []model.QuizAnswer
+	lookups    int
+}
+
+func (r *racingQuizRepository) GetAssignmentByUUID(string) (*model.QuizAssignment, error) {
+	return r.assignment, nil
+}
+
+func (r *racingQuizRepository) GetAssignmentSession(uint, uint) (*model.QuizSession, error) {
+	r.lookups++
+	if r.lookups == 1 && r.session.ID == 0 {
+		r.session.ID = 7
+		return nil, nil
+	}
+	return r.session, nil
+}
+
+func (r *racingQuizRepository) CreateQuizSession(*model.QuizSession) error {
+	return gorm.ErrDuplicatedKey
+}
+
+func (r *racingQuizRepository) CreateQuizAnswer(*model.QuizAnswer) error {
+	return gorm.ErrDuplicatedKey
+}
+
+func (r *racingQuizRepository) GetQuizWithQuestionsByUUID(string) (*model.Quiz, error) {
+	quiz := *r.quiz
+	return &quiz, nil
+}
+
+func (r *racingQuizRepository) GetSessionDraws(uint) ([]model.QuizSessionDraw, error) {
+	return nil, nil
+}
+
+func (r *racingQuizRepository) GetQuizAnswersBySessionID(uint) ([]model.QuizAnswer, error) {
+	return r.answers, nil
+}
+
+type stubUserRepository struct {
+	repository.UserRepository
+}
+
+func (stubUserRepository) GetUserByID(uint) (*model.User, error) { return nil, nil }
+
+func newRacingAssignment(session *model.QuizSession) (*AssignmentService, *racingQuizRepository) {
+	repo := &racingQuizRepository{
+		assignment: &model.QuizAssignment{
+			ID:       1,
+			UUID:     "assignment",
+			QuizUUID: "quiz",
+			OpensAt:  time.Now().Add(-time.Hour),
+			DueAt:    time.Now().Add(time.Hour),
+		},
+		quiz: &model.Quiz{UUID: "quiz", Questions: []model.Question{
+			{ID: 10, Timer: 30},
+			{ID: 11, Timer: 30},
+		}},
+		session: session,
+	}
+	return NewAssignmentService(repo, stubUserRepository{}), repo
+}
+
+func TestStartAttemptContinuesConcurrentAttempt(t *testing.T) {
+	userID := uint(3)
+	service, repo := newRacingAssignment(&model.QuizSession{UserID: &userID, ScoringStrategy: ScoringAllCorrect})
+
+	attempt, err := service.StartAttempt("assignment", userID)
+	if err != nil {
+		t.Fatalf("StartAttempt returned %v", err)
+	}
+	if attempt.SessionID != 7 || repo.lookups != 2 {
+		t.Fatalf("got session %d after %d lookups, want the concurrent attempt's session 7 after 2", attempt.SessionID, repo.lookups)
+	}
+}
+
+func TestSubmitAnswerRejectsConcurrentAnswer(t *testing.T) {
+	userID := uint(3)
+	service, _ := newRacingAssignment(&model.QuizSession{ID: 7, UserID: &userID, ScoringStrategy: ScoringAllCorrect, StartedAt: time.Now()})
+
+	_, err := service.SubmitAnswer("assignment", userID, dtos.SubmitAssignmentAnswerRequest{QuestionID: 10, Answer: "a"})
+	if !errors.Is(err, ErrUnexpectedQuestion) {
+		t.Fatalf("SubmitAnswer returned %v, want ErrUnexpectedQuestion", err)
+	}
+}
+
+// servingQuizRepository records the answers and served times of an attempt.
+type servingQuizRepository struct {
+	*racingQuizRepository
+	recorded []model.QuizAnswer
+	served   []*time.Time
+}
+
+func (r *servingQuizRepository) CreateQuizAnswer(answer *model.QuizAnswer) error {
+	r.recorded = append(r.recorded, *answer)
+	return nil
+}
+
+func (r *servingQuizRepository) SetQuestionServedAt(sessionID uint, servedAt *time.Time) error {
+	r.served = append(r.served, servedAt)
+	return nil
+}
+
+func newServedAssignment(session *model.QuizSession) (*AssignmentService, *servingQuizRepository) {
+	_, racing := newRacingAssignment(session)
+	repo := &servingQuizRepository{racingQuizRepository: racing}
+	return NewAssignmentService(repo, stubUserRepository{}), repo
+}
+
+func TestNextQuestionStartsTheClockOnce(t *testing.T) {
+	userID := uint(3)
+	service, repo := newServedAssignment(&model.QuizSession{ID: 7, UserID: &userID, StartedAt: time.Now()})
+
+	first, err := service.NextQuestion("assignment", userID)
+	if err != nil {
+		t.Fatalf("NextQuestion returned %v", err)
+	}
+	again, err := service.NextQuestion("assignment", userID)
+	if err != nil {
+		t.Fatalf("NextQuestion returned %v", err)
+	}
+	if len(repo.served) != 1 || first.ServedAt == nil || !again.ServedAt.Equal(*first.ServedAt) {
+		t.Fatalf("served %d times, at %v then %v; want the first time kept", len(repo.served), first.ServedAt, again.ServedAt)
+	}
+}
+
+func TestSubmitAnswerTimesFromServedQuestion(t *testing.T) {
+	userID := uint(3)
+	servedAt := time.Now().Add(-3 * time.Second)
+	service, repo := newServedAssignment(&model.QuizSession{
+		ID:               7,
+		UserID:           &userID,
+		ScoringStrategy:  ScoringSpeed,
+		StartedAt:        time.Now().Add(-24 * time.Hour),
+		QuestionServedAt: &servedAt,
+	})
+	repo.quiz.Questions[1].CorrectAnswer = "a"
+	// The first question was answered the day before: that break is not answer time.
+	repo.answers = []model.QuizAnswer{{QuestionID: 10, SubmittedAt: time.Now().Add(-23 * time.Hour)}}
+
+	result, err := service.SubmitAnswer("assignment", userID, dtos.SubmitAssignmentAnswerRequest{QuestionID: 11, Answer: "a"})
+	if err != nil {
+		t.Fatalf("SubmitAnswer returned %v", err)
+	}
+	if ms := repo.recorded[0].ResponseTimeMs; ms < 3000 || ms > 4000 {
+		t.Errorf("response time %dms, want about 3s", ms)
+	}
+	if result.Points != 19 {
+		t.Errorf("scored %d points for an answer after 3 of 30 seconds, want 19", result.Points)
+	}
+	if len(repo.served) != 1 || repo.served[0] != nil {
+		t.Errorf("served times set to %v, want the clock stopped", repo.served)
+	}
+}
@@ -6,10 +6,11 @@ import (
 	"exam/internal/dtos"
 	"exam/internal/model"
 	"exam/internal/repository"
+	"exam/internal/utils"
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"os"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -28,16 +29,18 @@ var (
 	ErrDeviceNotFound        = errors.New("device not found")
 	ErrDeviceNotOwned        = errors.New("device not owned by user")
 	ErrEmailExists           = errors.New("email already exists")
+	ErrInvalidAvatar         = errors.New("avatar must be one of your uploaded images")
 )
 
 type AuthService struct {
-	userRepo          repository.UserRepository
-	deviceRepo        repository.DeviceRepository
-	googleClientID    string
+	userRepo         repository.UserRepository
+	deviceRepo       repository.DeviceRepository
+	uploadedFileRepo *repository.UploadedFileRepository
+	googleClientID   string
 }
 
-func NewAuthService(userRepo repository.UserRepository, deviceRepo repository.DeviceRepository, googleClientID string) *AuthService {
-	return &AuthService{userRepo: userRepo, deviceRepo: deviceRepo, googleClientID: googleClientID}
+func NewAuthService(userRepo repository.UserRepository, deviceRepo repository.DeviceRepository, uploadedFileRepo *repository.UploadedFileRepository, googleClientID string) *AuthService {
+	return &AuthService{userRepo: userRepo, deviceRepo: deviceRepo, uploadedFileRepo: uploadedFileRepo, googleClientID: googleClientID}
 }
 
 func (s *AuthService) Register(req dtos.RegisterRequest) (*model.User, error) {
@@ -129,7 +132,7 @@ func (s *AuthService) RefreshAccessToken(oldRefreshToken string, deviceInfo stri
 	return s.generateAndSaveTokens(user, device.FCMToken, device.Latitude, device.Longitude, deviceInfo)
 }
 
-func (s *AuthService) UpdateUserAccount(userID uint, name string, phone *string) error {
+func (s *AuthService) UpdateUserAccount(userID uint, name string, phone *string, avatarUUID *string) error {
 	user, err := s.userRepo.GetUserByID(userID)
 	if err != nil {
 		return fmt.Errorf("%w: %v", ErrDatabase, err)
@@ -138,8 +141,22 @@ func (s *AuthService) UpdateUserAccount(userID uint, name string, phone *string)
 		return ErrUserNotFound
 	}
 
+	// The avatar has to be an image the user uploaded themselves.
+	if avatarUUID != nil && *avatarUUID != "" {
+		file, err := s.uploadedFileRepo.GetUploadedFileByUUIDAndUserID(*avatarUUID, userID)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrDatabase, err)
+		}
+		if file == nil || !strings.HasPrefix(file.MimeType, "image/") {
+			return ErrInvalidAvatar
+		}
+	} else {
+		avatarUUID = nil
+	}
+
 	user.Name = name
 	user.Phone = phone
+	user.AvatarUUID = avatarUUID
 
 	return s.userRepo.UpdateUser(user)
 }
@@ -342,8 +359,7 @@ func (s *AuthService) generateAndSaveTokens(user *model.User, fcmToken *string,
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
-	jwtSecret := os.Getenv("JWT_SECRET")
-	accessToken, err := token.SignedString([]byte(jwtSecret))
+	accessToken, err := token.SignedString(utils.JWTSecret())
 	if err != nil {
 		return nil, fmt.Errorf("failed to sign new access token: %w", err)
 	}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"errors"
+	"exam/internal/dtos"
+	"exam/internal/model"
+	"exam/internal/utils"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNicknameTaken      = errors.New("nickname is already taken in this room")
+	ErrNicknameNotAllowed = errors.New("nickname is not allowed")
+	ErrInvalidGuestToken  = errors.New("invalid or expired guest token")
+)
+
+// guestPlayerIDBase offsets guest IDs so they never clash with user IDs in a
+// room, where every player is keyed by a single ID.
+const guestPlayerIDBase uint = 1 << 31
+
+const guestTokenType = "guest"
+
+// GuestPlayerID returns the in-room player ID of a guest.
+func GuestPlayerID(guestID uint) uint {
+	return guestPlayerIDBase + guestID
+}
+
+// GuestIDFromPlayerID returns the guest behind an in-room player ID, if it is one.
+func GuestIDFromPlayerID(playerID uint) (uint, bool) {
+	if playerID <= guestPlayerIDBase {
+		return 0, false
+	}
+	return playerID - guestPlayerIDBase, true
+}
+
+// GuestClaims is what a guest token grants: joining one room as one player.
+type GuestClaims struct {
+	GuestID  uint
+	PlayerID uint
+	RoomID   string
+	Nickname string
+}
+
+// JoinAsGuest admits a player without an account to a room by PIN or room ID
+// and issues the token they connect to the room's websocket with.
+func (s *QuizService) JoinAsGuest(req dtos.GuestJoinRequest) (*dtos.GuestJoinResponse, error) {
+	roomID := req.RoomID
+	if req.PIN != "" {
+		var ok bool
+		if roomID, ok = s.hub.ResolvePIN(req.PIN); !ok {
+			return nil, ErrRoomNotFound
+		}
+	}
+	quizUUID, ok := s.hub.GetRoomQuizUUID(roomID)
+	if !ok {
+		return nil, ErrRoomNotFound
+	}
+
+	nickname := strings.TrimSpace(req.Nickname)
+	guest := &model.QuizGuest{
+		UUID:     uuid.New().String(),
+		RoomID:   roomID,
+		QuizUUID: quizUUID,
+		Nickname: nickname,
+	}
+	if err := s.quizRepo.CreateGuest(guest); err != nil {
+		return nil, fmt.Errorf("failed to create guest: %w", err)
+	}
+
+	playerID := GuestPlayerID(guest.ID)
+	expiresAt, err := s.hub.AdmitGuest(roomID, playerID, nickname)
+	if err != nil {
+		if delErr := s.quizRepo.DeleteGuest(guest.ID); delErr != nil {
+			return nil, fmt.Errorf("failed to delete rejected guest: %w", delErr)
+		}
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{
+		"typ":      guestTokenType,
+		"guest_id": guest.ID,
+		"room_id":  roomID,
+		"nickname": nickname,
+		"exp":      expiresAt.Unix(),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(utils.GuestTokenSecret())
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign guest token: %w", err)
+	}
+
+	return &dtos.GuestJoinResponse{
+		Token:     token,
+		ExpiresAt: expiresAt,
+		RoomID:    roomID,
+		QuizUUID:  quizUUID,
+		PlayerID:  playerID,
+		Nickname:  nickname,
+	}, nil
+}
+
+// ParseGuestToken validates a token issued by JoinAsGuest. Account tokens are
+// rejected, as guest tokens are rejected everywhere but the guest websocket.
+func ParseGuestToken(tokenString string) (*GuestClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return utils.GuestTokenSecret(), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidGuestToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["typ"] != guestTokenType {
+		return nil, ErrInvalidGuestToken
+	}
+	guestID, ok := claims["guest_id"].(float64)
+	if !ok || guestID <= 0 {
+		return nil, ErrInvalidGuestToken
+	}
+	roomID, _ := claims["room_id"].(string)
+	nickname, _ := claims["nickname"].(string)
+	if roomID == "" || nickname == "" {
+		return nil, ErrInvalidGuestToken
+	}
+
+	return &GuestClaims{
+		GuestID:  uint(guestID),
+		PlayerID: GuestPlayerID(uint(guestID)),
+		RoomID:   roomID,
+		Nickname: nickname,
+	}, nil
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"errors"
+	"exam/internal/utils"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestParseGuestTokenChecksItsOwnKey(t *testing.T) {
+	claims := jwt.MapClaims{
+		"typ":      guestTokenType,
+		"guest_id": 1,
+		"room_id":  "room",
+		"nickname": "bee",
+		"exp":      time.Now().Add(time.Hour).Unix(),
+	}
+
+	tests := []struct {
+		name string
+		key  []byte
+		err  error
+	}{
+		{"guest key", utils.GuestTokenSecret(), nil},
+		{"account key", utils.JWTSecret(), ErrInvalidGuestToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(tt.key)
+			if err != nil {
+				t.Fatalf("failed to sign token: %v", err)
+			}
+			if _, err := ParseGuestToken(token); !errors.Is(err, tt.err) {
+				t.Fatalf("ParseGuestToken = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"exam/internal/dtos"
+	"exam/internal/model"
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+var (
+	ErrPoolNotFound         = errors.New("question pool not found")
+	ErrPoolQuestionNotFound = errors.New("pool refers to a question that is not in the quiz")
+)
+
+// drawSalt keeps the random source of question draws apart from the ones
+// Shuffle derives from the same seed.
+const drawSalt uint64 = 1 << 63
+
+// DrawQuestions returns the questions the player gets out of the quiz's
+// pools, pool by pool and in quiz order within each pool. Like Shuffle it
+// depends on nothing but the seed and the player; pass player 0 for a draw
+// shared by the whole session. Quizzes without pools get all their questions.
+func DrawQuestions(quiz *model.Quiz, seed int64, playerID uint) []model.Question {
+	if len(quiz.Pools) == 0 {
+		return quiz.Questions
+	}
+	shuffle := Shuffle{seed: seed, playerID: playerID}
+
+	var drawn []model.Question
+	for i, candidates := range poolCandidates(quiz) {
+		count := min(quiz.Pools[i].DrawCount, len(candidates))
+		rng := rand.New(rand.NewSource(shuffle.source(drawSalt | uint64(quiz.Pools[i].ID))))
+		picked := rng.Perm(len(candidates))[:count]
+		sort.Ints(picked)
+		for _, index := range picked {
+			drawn = append(drawn, quiz.Questions[candidates[index]])
+		}
+	}
+	return drawn
+}
+
+// DrawSize is how many questions every draw from the quiz holds.
+func DrawSize(quiz *model.Quiz) int {
+	if len(quiz.Pools) == 0 {
+		return len(quiz.Questions)
+	}
+	size := 0
+	for i, candidates := range poolCandidates(quiz) {
+		size += min(quiz.Pools[i].DrawCount, len(candidates))
+	}
+	return size
+}
+
+// QuestionsByID returns the quiz's questions with the given IDs, in that
+// order, e.g. to restore a draw recorded in a QuizSessionDraw.
+func QuestionsByID(quiz *model.Quiz, ids []uint) []model.Question {
+	byID := make(map[uint]model.Question, len(quiz.Questions))
+	for _, question := range quiz.Questions {
+		byID[question.ID] = question
+	}
+	questions := make([]model.Question, 0, len(ids))
+	for _, id := range ids {
+		if question, ok := byID[id]; ok {
+			questions = append(questions, question)
+		}
+	}
+	return questions
+}
+
+// NewSessionDraw records a player's draw. Player 0 is the whole session.
+func NewSessionDraw(sessionID uint, playerID uint, questions []model.Question) *model.QuizSessionDraw {
+	ids := make([]uint, len(questions))
+	for i, question := range questions {
+		ids[i] = question.ID
+	}
+	idsJSON, _ := json.Marshal(ids)
+
+	draw := &model.QuizSessionDraw{
+		QuizSessionID: sessionID,
+		QuestionIDs:   datatypes.JSON(idsJSON),
+	}
+	if guestID, ok := GuestIDFromPlayerID(playerID); ok {
+		draw.GuestID = &guestID
+	} else if playerID != 0 {
+		draw.UserID = &playerID
+	}
+	return draw
+}
+
+// poolCandidates returns, for every pool, the indexes of the questions it can
+// draw from. A question matching several pools belongs to the first of them,
+// so no question is drawn twice and every draw has the same size.
+func poolCandidates(quiz *model.Quiz) [][]int {
+	taken := make([]bool, len(quiz.Questions))
+	candidates := make([][]int, len(quiz.Pools))
+	for i, pool := range quiz.Pools {
+		for j, question := range quiz.Questions {
+			if !taken[j] && poolMatches(pool, question) {
+				taken[j] = true
+				candidates[i] = append(candidates[i], j)
+			}
+		}
+	}
+	return candidates
+}
+
+// poolMatches reports whether a question belongs in a pool. A pool listing
+// questions explicitly holds just those; otherwise it holds the questions with
+// any of its tags and its difficulty, and a pool with neither holds them all.
+func poolMatches(pool model.QuizPool, question model.Question) bool {
+	var questionUUIDs []string
+	_ = json.Unmarshal(pool.QuestionUUIDs, &questionUUIDs)
+	if len(questionUUIDs) > 0 {
+		for _, questionUUID := range questionUUIDs {
+			if questionUUID == question.UUID {
+				return true
+			}
+		}
+		return false
+	}
+
+	if pool.Difficulty != "" && pool.Difficulty != question.Difficulty {
+		return false
+	}
+	var poolTags, questionTags []string
+	_ = json.Unmarshal(pool.Tags, &poolTags)
+	if len(poolTags) == 0 {
+		return true
+	}
+	_ = json.Unmarshal(question.Tags, &questionTags)
+	for _, poolTag := range poolTags {
+		for _, questionTag := range questionTags {
+			if poolTag == questionTag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CreatePool adds a question pool to a quiz.
+func (s *QuizService) CreatePool(quizUUID string, req dtos.CreatePoolRequest) (*model.QuizPool, error) {
+	quiz, err := s.quizRepo.GetQuizWithQuestionsByUUID(quizUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quiz by UUID: %w", err)
+	}
+	if quiz == nil {
+		return nil, fmt.Errorf("quiz not found with UUID: %s", quizUUID)
+	}
+
+	quizQuestions := make(map[string]bool, len(quiz.Questions))
+	for _, question := range quiz.Questions {
+		quizQuestions[question.UUID] = true
+	}
+	for _, questionUUID := range req.QuestionUUIDs {
+		if !quizQuestions[questionUUID] {
+			return nil, ErrPoolQuestionNotFound
+		}
+	}
+
+	pool := &model.QuizPool{
+		UUID:       uuid.New().String(),
+		QuizID:     quiz.ID,
+		Name:       req.Name,
+		Difficulty: req.Difficulty,
+		DrawCount:  req.DrawCount,
+	}
+	if len(req.Tags) > 0 {
+		tagsJSON, _ := json.Marshal(req.Tags)
+		pool.Tags = datatypes.JSON(tagsJSON)
+	}
+	if len(req.QuestionUUIDs) > 0 {
+		questionUUIDsJSON, _ := json.Marshal(req.QuestionUUIDs)
+		pool.QuestionUUIDs = datatypes.JSON(questionUUIDsJSON)
+	}
+	if err := s.quizRepo.CreatePool(pool); err != nil {
+		return nil, fmt.Errorf("failed to create question pool: %w", err)
+	}
+	return pool, nil
+}
+
+// ListPools returns a quiz's question pools in draw order.
+func (s *QuizService) ListPools(quizUUID string) ([]model.QuizPool, error) {
+	quiz, err := s.quizRepo.GetQuizWithQuestionsByUUID(quizUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quiz by UUID: %w", err)
+	}
+	if quiz == nil {
+		return nil, fmt.Errorf("quiz not found with UUID: %s", quizUUID)
+	}
+	return quiz.Pools, nil
+}
+
+// DeletePool removes a question pool from a quiz. Draws already made from it
+// stay recorded.
+func (s *QuizService) DeletePool(quizUUID string, poolUUID string) error {
+	quiz, err := s.quizRepo.GetQuizByUUID(quizUUID)
+	if err != nil {
+		return fmt.Errorf("failed to get quiz by UUID: %w", err)
+	}
+	if quiz == nil {
+		return fmt.Errorf("quiz not found with UUID: %s", quizUUID)
+	}
+
+	deleted, err := s.quizRepo.DeletePool(quiz.ID, poolUUID)
+	if err != nil {
+		return fmt.Errorf("failed to delete question pool: %w", err)
+	}
+	if !deleted {
+		return ErrPoolNotFound
+	}
+	return nil
+}
+
+// RecordQuestionDraw stores the questions a session, or one of its players,
+// was given.
+func (s *QuizService) RecordQuestionDraw(draw *model.QuizSessionDraw) error {
+	if err := s.quizRepo.CreateSessionDraw(draw); err != nil {
+		return fmt.Errorf("failed to record question draw: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"exam/internal/model"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// poolQuiz returns a quiz of ten questions: 1 to 5 are easy, 6 to 10 hard,
+// and each is tagged odd or even.
+func poolQuiz(pools ...model.QuizPool) *model.Quiz {
+	quiz := &model.Quiz{Pools: pools}
+	for id := uint(1); id <= 10; id++ {
+		question := model.Question{ID: id, UUID: fmt.Sprintf("q%d", id), Difficulty: "easy", Tags: []byte(`["odd"]`)}
+		if id > 5 {
+			question.Difficulty = "hard"
+		}
+		if id%2 == 0 {
+			question.Tags = []byte(`["even"]`)
+		}
+		quiz.Questions = append(quiz.Questions, question)
+	}
+	return quiz
+}
+
+func questionIDs(questions []model.Question) []uint {
+	ids := make([]uint, len(questions))
+	for i, question := range questions {
+		ids[i] = question.ID
+	}
+	return ids
+}
+
+func TestDrawQuestions(t *testing.T) {
+	tests := []struct {
+		name  string
+		pools []model.QuizPool
+		from  [][]uint // The questions each pool may draw, in pool order
+		size  int
+	}{
+		{"no pools", nil, [][]uint{{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}}, 10},
+		{"explicit questions", []model.QuizPool{
+			{ID: 1, QuestionUUIDs: []byte(`["q3","q1"]`), DrawCount: 5},
+		}, [][]uint{{1, 3}}, 2},
+		{"tags and difficulty", []model.QuizPool{
+			{ID: 1, Difficulty: "hard", Tags: []byte(`["odd"]`), DrawCount: 1},
+			{ID: 2, Difficulty: "easy", DrawCount: 3},
+		}, [][]uint{{7, 9}, {1, 2, 3, 4, 5}}, 4},
+		// The even questions all belong to the first pool, drawn from or not.
+		{"overlapping pools", []model.QuizPool{
+			{ID: 1, Tags: []byte(`["even"]`), DrawCount: 2},
+			{ID: 2, Tags: []byte(`["even","odd"]`), DrawCount: 10},
+		}, [][]uint{{2, 4, 6, 8, 10}, {1, 3, 5, 7, 9}}, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quiz := poolQuiz(tt.pools...)
+			if size := DrawSize(quiz); size != tt.size {
+				t.Fatalf("DrawSize = %d, want %d", size, tt.size)
+			}
+
+			for playerID := uint(0); playerID < 5; playerID++ {
+				drawn := questionIDs(DrawQuestions(quiz, 42, playerID))
+				if again := questionIDs(DrawQuestions(quiz, 42, playerID)); !reflect.DeepEqual(drawn, again) {
+					t.Fatalf("player %d drew %v, then %v", playerID, drawn, again)
+				}
+				if len(drawn) != tt.size {
+					t.Fatalf("player %d drew %v, want %d questions", playerID, drawn, tt.size)
+				}
+
+				// Pool by pool, in quiz order, and never a question twice.
+				seen := make(map[uint]bool)
+				pool, last := 0, uint(0)
+				for _, id := range drawn {
+					if seen[id] {
+						t.Fatalf("player %d drew question %d twice in %v", playerID, id, drawn)
+					}
+					seen[id] = true
+					for id <= last || !contains(tt.from[pool], id) {
+						pool, last = pool+1, 0
+						if pool == len(tt.from) {
+							t.Fatalf("player %d drew %v, out of pool order or from outside the pools %v", playerID, drawn, tt.from)
+						}
+					}
+					last = id
+				}
+			}
+		})
+	}
+}
+
+func contains(ids []uint, id uint) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDrawQuestionsVariesBySeedAndPlayer(t *testing.T) {
+	quiz := poolQuiz(model.QuizPool{ID: 1, DrawCount: 5})
+	draws := make(map[string]bool)
+	for seed := int64(1); seed <= 5; seed++ {
+		for playerID := uint(0); playerID < 5; playerID++ {
+			draws[fmt.Sprint(questionIDs(DrawQuestions(quiz, seed, playerID)))] = true
+		}
+	}
+	// 25 draws of 5 out of 10 questions; a handful of repeats is fine.
+	if len(draws) < 15 {
+		t.Fatalf("only %d different draws out of 25", len(draws))
+	}
+}
+
+func TestQuestionsByID(t *testing.T) {
+	quiz := poolQuiz()
+	tests := []struct {
+		ids  []uint
+		want []uint
+	}{
+		{[]uint{3, 1, 10}, []uint{3, 1, 10}},
+		{[]uint{42, 2}, []uint{2}},
+		{nil, []uint{}},
+	}
+	for _, tt := range tests {
+		if got := questionIDs(QuestionsByID(quiz, tt.ids)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("QuestionsByID(%v) = %v, want %v", tt.ids, got, tt.want)
+		}
+	}
+}
@@ -2,57 +2,178 @@ package service
 
 import (
 	"encoding/json"
+	"errors"
 	"exam/internal/dtos"
 	"exam/internal/model"
 	"exam/internal/repository"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/datatypes"
 )
 
+var (
+	ErrRoomNotFound   = errors.New("quiz room not found")
+	ErrGameInProgress = errors.New("a game is already in progress in this room")
+	ErrNotQuizCreator = errors.New("only the quiz creator may host its rooms")
+)
+
 // QuizRoomManager defines the interface for managing quiz rooms (e.g., getting student count).
 type QuizRoomManager interface {
-	GetRoomClientCount(quizUUID string) int
-	GetRoomClients(quizUUID string) []dtos.ConnectedStudentDTO
-	StartQuizInRoom(quizUUID string, sessionID uint, mode string) error
+	OpenRoom(quizUUID string, hostID uint) (*dtos.LobbyResponse, error)
+	ResolvePIN(pin string) (string, bool)
+	GetRoomQuizUUID(roomID string) (string, bool)
+	GetRoomClientCount(roomID string) int
+	GetRoomClients(roomID string) []dtos.ConnectedStudentDTO
+	GetRoomStats(roomID string) (*dtos.RoomStatsDTO, bool)
+	GetRoomMonitor(roomID string) (*dtos.MonitorPayload, bool)
+	StartQuizInRoom(roomID string, req dtos.StartQuizRequest) error
+	AdmitGuest(roomID string, playerID uint, nickname string) (time.Time, error)
 }
 
 // ... (rest of QuizService struct and NewQuizService function)
 
-func (s *QuizService) ListConnectedStudents(quizUUID string) []dtos.ConnectedStudentDTO {
-	return s.hub.GetRoomClients(quizUUID)
+// OpenLobby opens a new room for the quiz that students can join before the
+// game starts. Only the quiz creator may open one.
+func (s *QuizService) OpenLobby(quizUUID string, userID uint) (*dtos.LobbyResponse, error) {
+	quiz, err := s.getCreatedQuiz(quizUUID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The quiz creator hosts the room and is the only one allowed to control the game.
+	lobby, err := s.hub.OpenRoom(quizUUID, quiz.CreatedBy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open quiz room: %w", err)
+	}
+
+	return lobby, nil
+}
+
+// ResolvePIN looks up the room a game PIN points to.
+func (s *QuizService) ResolvePIN(pin string) (*dtos.LobbyResponse, error) {
+	roomID, ok := s.hub.ResolvePIN(pin)
+	if !ok {
+		return nil, ErrRoomNotFound
+	}
+	quizUUID, ok := s.hub.GetRoomQuizUUID(roomID)
+	if !ok {
+		return nil, ErrRoomNotFound
+	}
+	return &dtos.LobbyResponse{RoomID: roomID, QuizUUID: quizUUID, PIN: pin}, nil
 }
 
-func (s *QuizService) StartQuiz(quizUUID string, req dtos.StartQuizRequest) error {
-	// First, check if the quiz exists and is valid
+// getCreatedQuiz returns the quiz if the user created it, and
+// ErrNotQuizCreator if someone else did.
+func (s *QuizService) getCreatedQuiz(quizUUID string, userID uint) (*model.Quiz, error) {
 	quiz, err := s.quizRepo.GetQuizByUUID(quizUUID)
 	if err != nil {
-		return fmt.Errorf("failed to get quiz by UUID: %w", err)
+		return nil, fmt.Errorf("failed to get quiz by UUID: %w", err)
 	}
 	if quiz == nil {
-		return fmt.Errorf("quiz not found with UUID: %s", quizUUID)
+		return nil, fmt.Errorf("quiz not found with UUID: %s", quizUUID)
+	}
+	if quiz.CreatedBy != userID {
+		return nil, ErrNotQuizCreator
 	}
+	return quiz, nil
+}
 
-	// Create a new quiz session record
-	now := time.Now()
-	participantsJSON, _ := json.Marshal(s.hub.GetRoomClients(quizUUID))
+// checkRoom makes sure the room exists and is playing the given quiz.
+func (s *QuizService) checkRoom(quizUUID string, roomID string) error {
+	roomQuizUUID, ok := s.hub.GetRoomQuizUUID(roomID)
+	if !ok || roomQuizUUID != quizUUID {
+		return ErrRoomNotFound
+	}
+	return nil
+}
+
+func (s *QuizService) ListConnectedStudents(quizUUID string, roomID string) ([]dtos.ConnectedStudentDTO, error) {
+	if err := s.checkRoom(quizUUID, roomID); err != nil {
+		return nil, err
+	}
+	return s.hub.GetRoomClients(roomID), nil
+}
+
+// checkHost makes sure the room is playing the quiz and the user hosts it,
+// which only the quiz creator does.
+func (s *QuizService) checkHost(quizUUID string, roomID string, userID uint) error {
+	if err := s.checkRoom(quizUUID, roomID); err != nil {
+		return err
+	}
+	_, err := s.getCreatedQuiz(quizUUID, userID)
+	return err
+}
+
+// GetRoomStats returns a snapshot of a live room of the quiz to its host.
+func (s *QuizService) GetRoomStats(quizUUID string, roomID string, userID uint) (*dtos.RoomStatsDTO, error) {
+	if err := s.checkHost(quizUUID, roomID, userID); err != nil {
+		return nil, err
+	}
+	stats, ok := s.hub.GetRoomStats(roomID)
+	if !ok {
+		return nil, ErrRoomNotFound
+	}
+	return stats, nil
+}
+
+// GetRoomMonitor returns every student's progress in a live room of the quiz
+// to its host, like the monitor_update stream of the room.
+func (s *QuizService) GetRoomMonitor(quizUUID string, roomID string, userID uint) (*dtos.MonitorPayload, error) {
+	if err := s.checkHost(quizUUID, roomID, userID); err != nil {
+		return nil, err
+	}
+	monitor, ok := s.hub.GetRoomMonitor(roomID)
+	if !ok {
+		return nil, ErrRoomNotFound
+	}
+	return monitor, nil
+}
+
+// StartQuiz starts a game in a room of the quiz. Like the host over the
+// websocket, only the quiz creator may start one.
+func (s *QuizService) StartQuiz(quizUUID string, roomID string, userID uint, req dtos.StartQuizRequest) error {
+	if _, err := s.getCreatedQuiz(quizUUID, userID); err != nil {
+		return err
+	}
+
+	if err := s.checkRoom(quizUUID, roomID); err != nil {
+		return err
+	}
+
+	// The room records the session itself, once it has made sure no game is
+	// in progress, see QuizRoomManager.StartQuizInRoom.
+	return s.hub.StartQuizInRoom(roomID, req)
+}
+
+// CreateQuizSession records the start of a game in a room.
+func (s *QuizService) CreateQuizSession(quizUUID string, roomID string, req dtos.StartQuizRequest, participants []dtos.ConnectedStudentDTO) (*model.QuizSession, error) {
+	quiz, err := s.quizRepo.GetQuizByUUID(quizUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quiz by UUID: %w", err)
+	}
+
+	participantsJSON, _ := json.Marshal(participants)
 	session := &model.QuizSession{
-		QuizUUID:     quizUUID,
-		Mode:         req.Mode,
-		StartedAt:    now,
-		Participants: datatypes.JSON(participantsJSON),
+		QuizUUID:        quizUUID,
+		RoomID:          roomID,
+		Mode:            req.Mode,
+		ScoringStrategy: ResolveScoringStrategy(req.Scoring, quiz.ScoringStrategy, req.Mode),
+		TimeLimit:       req.TimeLimit,
+		RevealSeconds:   req.RevealSeconds,
+		ShuffleSeed:     NewShuffleSeed(),
+		StartedAt:       time.Now(),
+		Participants:    datatypes.JSON(participantsJSON),
 	}
 	if err := s.quizRepo.CreateQuizSession(session); err != nil {
-		return fmt.Errorf("failed to create quiz session: %w", err)
+		return nil, fmt.Errorf("failed to create quiz session: %w", err)
 	}
-
-	// Then, tell the hub to start the quiz in the room, passing the session ID and mode
-	return s.hub.StartQuizInRoom(quizUUID, session.ID, req.Mode)
+	return session, nil
 }
 
-func (s *QuizService) EndQuizSession(sessionID uint, finalScores []dtos.PlayerScore) error {
+func (s *QuizService) EndQuizSession(sessionID uint, result dtos.SessionResult) error {
 	// Retrieve the session
 	session, err := s.quizRepo.GetQuizSessionByID(sessionID)
 	if err != nil {
@@ -64,9 +185,11 @@ func (s *QuizService) EndQuizSession(sessionID uint, finalScores []dtos.PlayerSc
 
 	// Update session with end time and final scores
 	now := time.Now()
-	finalScoresJSON, _ := json.Marshal(finalScores)
+	finalScoresJSON, _ := json.Marshal(result)
 	session.EndedAt = &now
 	session.FinalScores = datatypes.JSON(finalScoresJSON)
+	session.Checkpoint = nil
+	session.CheckpointedAt = nil
 
 	if err := s.quizRepo.UpdateQuizSession(session); err != nil {
 		return fmt.Errorf("failed to update quiz session: %w", err)
@@ -76,26 +199,79 @@ func (s *QuizService) EndQuizSession(sessionID uint, finalScores []dtos.PlayerSc
 }
 
 type QuizService struct {
-	quizRepo repository.QuizRepository
-	hub      QuizRoomManager
+	quizRepo         repository.QuizRepository
+	userRepo         repository.UserRepository
+	uploadedFileRepo *repository.UploadedFileRepository
+	hub              QuizRoomManager
+}
+
+func NewQuizService(quizRepo repository.QuizRepository, userRepo repository.UserRepository, uploadedFileRepo *repository.UploadedFileRepository, hub QuizRoomManager) *QuizService {
+	return &QuizService{quizRepo: quizRepo, userRepo: userRepo, uploadedFileRepo: uploadedFileRepo, hub: hub}
 }
 
-func NewQuizService(quizRepo repository.QuizRepository, hub QuizRoomManager) *QuizService {
-	return &QuizService{quizRepo: quizRepo, hub: hub}
+// ResolvePlayerProfile looks up how a player joining a room is shown to others:
+// their name, their avatar and the nickname they picked for this game.
+func (s *QuizService) ResolvePlayerProfile(userID uint, nickname string) (dtos.PlayerProfile, error) {
+	profile := dtos.PlayerProfile{UserID: userID, Nickname: nickname}
+
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return profile, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return profile, ErrUserNotFound
+	}
+	profile.Name = user.Name
+
+	if user.AvatarUUID != nil && s.uploadedFileRepo != nil {
+		file, err := s.uploadedFileRepo.GetUploadedFileByUUIDAndUserID(*user.AvatarUUID, userID)
+		if err != nil {
+			return profile, fmt.Errorf("failed to get avatar: %w", err)
+		}
+		if file != nil {
+			// Stored paths are relative to the file server, as in the file listing.
+			profile.AvatarURL = fmt.Sprintf("%s%s", os.Getenv("FTP_DOMAIN"), file.FilePath)
+		}
+	}
+
+	return profile, nil
+}
+
+// CanSpectate reports whether a user may watch a room without playing in it:
+// teachers may, and so may the creator of the room's quiz.
+func (s *QuizService) CanSpectate(roomID string, userID uint, role string) (bool, error) {
+	if role == "teacher" {
+		return true, nil
+	}
+	quizUUID, ok := s.hub.GetRoomQuizUUID(roomID)
+	if !ok {
+		return false, ErrRoomNotFound
+	}
+	quiz, err := s.quizRepo.GetQuizByUUID(quizUUID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get quiz by UUID: %w", err)
+	}
+	return quiz != nil && quiz.CreatedBy == userID, nil
 }
 
 // ... (rest of the file)
 
-func (s *QuizService) GetStudentCount(quizUUID string) int {
-	return s.hub.GetRoomClientCount(quizUUID)
+func (s *QuizService) GetStudentCount(quizUUID string, roomID string) (int, error) {
+	if err := s.checkRoom(quizUUID, roomID); err != nil {
+		return 0, err
+	}
+	return s.hub.GetRoomClientCount(roomID), nil
 }
 
 func (s *QuizService) CreateQuiz(req dtos.CreateQuizRequest, teacherID uint) (*model.Quiz, error) {
 	quiz := &model.Quiz{
-		UUID:        uuid.New().String(),
-		Title:       req.Title,
-		Description: req.Description,
-		CreatedBy:   teacherID,
+		UUID:            uuid.New().String(),
+		Title:           req.Title,
+		Description:     req.Description,
+		CreatedBy:        teacherID,
+		ScoringStrategy:  req.ScoringStrategy,
+		ShuffleQuestions: req.ShuffleQuestions,
+		ShuffleOptions:   req.ShuffleOptions,
 	}
 
 	if err := s.quizRepo.CreateQuiz(quiz); err != nil {
@@ -129,8 +305,16 @@ func (s *QuizService) AddQuestion(req dtos.AddQuestionRequest, quizUUID string)
 		Content:       datatypes.JSON(contentJSON),
 		Options:       datatypes.JSON(optionsJSON),
 		CorrectAnswer: req.CorrectAnswer,
+		Difficulty:    req.Difficulty,
 		Timer:         req.Timer,
 	}
+	if req.Explanation != "" {
+		question.Explanation = &req.Explanation
+	}
+	if len(req.Tags) > 0 {
+		tagsJSON, _ := json.Marshal(req.Tags)
+		question.Tags = datatypes.JSON(tagsJSON)
+	}
 
 	if err := s.quizRepo.AddQuestion(question); err != nil {
 		return nil, fmt.Errorf("failed to add question: %w", err)
@@ -181,6 +365,15 @@ func (s *QuizService) UpdateQuiz(quizUUID string, req dtos.UpdateQuizRequest) (*
 	if req.Description != nil {
 		quiz.Description = *req.Description
 	}
+	if req.ScoringStrategy != nil {
+		quiz.ScoringStrategy = *req.ScoringStrategy
+	}
+	if req.ShuffleQuestions != nil {
+		quiz.ShuffleQuestions = *req.ShuffleQuestions
+	}
+	if req.ShuffleOptions != nil {
+		quiz.ShuffleOptions = *req.ShuffleOptions
+	}
 
 	if err := s.quizRepo.UpdateQuiz(quiz); err != nil {
 		return nil, fmt.Errorf("failed to update quiz: %w", err)
@@ -232,6 +425,24 @@ func (s *QuizService) UpdateQuestion(quizUUID string, questionUUID string, req d
 	if req.Timer != nil {
 		questionToUpdate.Timer = *req.Timer
 	}
+	if req.Explanation != nil {
+		// An empty explanation removes it.
+		questionToUpdate.Explanation = nil
+		if *req.Explanation != "" {
+			questionToUpdate.Explanation = req.Explanation
+		}
+	}
+	if req.Tags != nil {
+		// An empty list removes the tags.
+		questionToUpdate.Tags = nil
+		if len(req.Tags) > 0 {
+			tagsJSON, _ := json.Marshal(req.Tags)
+			questionToUpdate.Tags = datatypes.JSON(tagsJSON)
+		}
+	}
+	if req.Difficulty != nil {
+		questionToUpdate.Difficulty = *req.Difficulty
+	}
 
 	if err := s.quizRepo.UpdateQuestion(questionToUpdate); err != nil {
 		return nil, fmt.Errorf("failed to update question: %w", err)
@@ -245,4 +456,4 @@ func (s *QuizService) RecordQuizAnswer(answer *model.QuizAnswer) error {
 		return fmt.Errorf("failed to record quiz answer: %w", err)
 	}
 	return nil
-}
\ No newline at end of file
+}
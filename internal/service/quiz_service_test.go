@@ -0,0 +1,96 @@
+package service
+
+import (
+	"errors"
+	"exam/internal/dtos"
+	"exam/internal/model"
+	"exam/internal/repository"
+	"testing"
+)
+
+// stubRoomManager knows the quiz each of its rooms plays, and nothing else.
+type stubRoomManager struct {
+	QuizRoomManager
+	rooms map[string]string
+}
+
+func (m stubRoomManager) GetRoomQuizUUID(roomID string) (string, bool) {
+	quizUUID, ok := m.rooms[roomID]
+	return quizUUID, ok
+}
+
+func (m stubRoomManager) GetRoomStats(roomID string) (*dtos.RoomStatsDTO, bool) {
+	return &dtos.RoomStatsDTO{RoomID: roomID}, true
+}
+
+func (m stubRoomManager) GetRoomMonitor(roomID string) (*dtos.MonitorPayload, bool) {
+	return &dtos.MonitorPayload{}, true
+}
+
+type stubQuizRepository struct {
+	repository.QuizRepository
+	quiz *model.Quiz
+}
+
+func (r stubQuizRepository) GetQuizByUUID(string) (*model.Quiz, error) {
+	return r.quiz, nil
+}
+
+func TestCanSpectate(t *testing.T) {
+	service := NewQuizService(
+		stubQuizRepository{quiz: &model.Quiz{UUID: "quiz", CreatedBy: 1}},
+		nil, nil,
+		stubRoomManager{rooms: map[string]string{"room": "quiz"}},
+	)
+
+	tests := []struct {
+		name    string
+		roomID  string
+		userID  uint
+		role    string
+		allowed bool
+		err     error
+	}{
+		{"teacher", "room", 2, "teacher", true, nil},
+		{"quiz creator", "room", 1, "student", true, nil},
+		{"student", "room", 2, "student", false, nil},
+		{"missing room", "gone", 1, "student", false, ErrRoomNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, err := service.CanSpectate(tt.roomID, tt.userID, tt.role)
+			if allowed != tt.allowed || !errors.Is(err, tt.err) {
+				t.Fatalf("CanSpectate = %v, %v; want %v, %v", allowed, err, tt.allowed, tt.err)
+			}
+		})
+	}
+}
+
+func TestRoomViewsForTheHostOnly(t *testing.T) {
+	service := NewQuizService(
+		stubQuizRepository{quiz: &model.Quiz{UUID: "quiz", CreatedBy: 1}},
+		nil, nil,
+		stubRoomManager{rooms: map[string]string{"room": "quiz"}},
+	)
+
+	tests := []struct {
+		name   string
+		roomID string
+		userID uint
+		err    error
+	}{
+		{"quiz creator", "room", 1, nil},
+		{"another teacher", "room", 2, ErrNotQuizCreator},
+		{"missing room", "gone", 1, ErrRoomNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := service.GetRoomStats("quiz", tt.roomID, tt.userID); !errors.Is(err, tt.err) {
+				t.Errorf("GetRoomStats = %v, want %v", err, tt.err)
+			}
+			if _, err := service.GetRoomMonitor("quiz", tt.roomID, tt.userID); !errors.Is(err, tt.err) {
+				t.Errorf("GetRoomMonitor = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"exam/internal/model"
+	"fmt"
+	"time"
+)
+
+// Built-in scoring strategies that can be picked per quiz or per session.
+const (
+	ScoringFirstCorrect = "first_correct" // Only the first correct answer to a question scores
+	ScoringAllCorrect   = "all_correct"   // Every correct answer scores the same
+	ScoringSpeed        = "speed"         // Faster correct answers score more
+	ScoringStreak       = "streak"        // Consecutive correct answers build a multiplier
+	ScoringNegative     = "negative"      // Wrong answers cost points
+)
+
+const (
+	basePoints      = 10
+	negativePenalty = 5
+	maxStreakBonus  = 4 // Streak multiplier caps at 1 + 4*0.5 = 3x
+)
+
+// IsCorrectAnswer reports whether an answer picks the question's correct option.
+// Live rooms and homework attempts both grade answers with it.
+func IsCorrectAnswer(question model.Question, answer string) bool {
+	return answer == question.CorrectAnswer
+}
+
+// CorrectOptionIDs lists the options IsCorrectAnswer accepts.
+func CorrectOptionIDs(question model.Question) []string {
+	return []string{question.CorrectAnswer}
+}
+
+// AnswerContext describes a submitted answer for a ScoringStrategy.
+type AnswerContext struct {
+	IsCorrect      bool
+	IsFirstCorrect bool          // Nobody answered this question correctly before
+	ResponseTime   time.Duration // Time between the question being sent and the answer
+	TimeLimit      time.Duration // Zero when the question has no timer
+	Streak         int           // Correct answers in a row before this one
+}
+
+// ScoringStrategy turns an answer into the points awarded for it.
+type ScoringStrategy interface {
+	Name() string
+	Points(answer AnswerContext) int
+}
+
+// NewScoringStrategy returns the built-in strategy with the given name.
+func NewScoringStrategy(name string) (ScoringStrategy, error) {
+	switch name {
+	case ScoringFirstCorrect:
+		return firstCorrectScoring{}, nil
+	case ScoringAllCorrect:
+		return allCorrectScoring{}, nil
+	case ScoringSpeed:
+		return speedScoring{}, nil
+	case ScoringStreak:
+		return streakScoring{}, nil
+	case ScoringNegative:
+		return negativeScoring{}, nil
+	}
+	return nil, fmt.Errorf("unknown scoring strategy: %s", name)
+}
+
+// ResolveScoringStrategy picks the strategy for a new session: the one requested
+// for the session, else the quiz default, else the historical default of the mode.
+func ResolveScoringStrategy(requested string, quizDefault string, mode string) string {
+	if requested != "" {
+		return requested
+	}
+	if quizDefault != "" {
+		return quizDefault
+	}
+	if mode == "sync" {
+		return ScoringFirstCorrect
+	}
+	return ScoringAllCorrect
+}
+
+type firstCorrectScoring struct{}
+
+func (firstCorrectScoring) Name() string { return ScoringFirstCorrect }
+
+func (firstCorrectScoring) Points(answer AnswerContext) int {
+	if answer.IsCorrect && answer.IsFirstCorrect {
+		return basePoints
+	}
+	return 0
+}
+
+type allCorrectScoring struct{}
+
+func (allCorrectScoring) Name() string { return ScoringAllCorrect }
+
+func (allCorrectScoring) Points(answer AnswerContext) int {
+	if answer.IsCorrect {
+		return basePoints
+	}
+	return 0
+}
+
+// speedScoring awards between basePoints and twice that, depending on how
+// much of the question timer was left.
+type speedScoring struct{}
+
+func (speedScoring) Name() string { return ScoringSpeed }
+
+func (speedScoring) Points(answer AnswerContext) int {
+	if !answer.IsCorrect {
+		return 0
+	}
+	if answer.TimeLimit <= 0 {
+		return basePoints * 2
+	}
+	remaining := 1 - float64(answer.ResponseTime)/float64(answer.TimeLimit)
+	if remaining < 0 {
+		remaining = 0
+	}
+	if remaining > 1 {
+		remaining = 1
+	}
+	return basePoints + int(float64(basePoints)*remaining+0.5)
+}
+
+// streakScoring adds half the base points for every correct answer in a row.
+type streakScoring struct{}
+
+func (streakScoring) Name() string { return ScoringStreak }
+
+func (streakScoring) Points(answer AnswerContext) int {
+	if !answer.IsCorrect {
+		return 0
+	}
+	streak := answer.Streak
+	if streak > maxStreakBonus {
+		streak = maxStreakBonus
+	}
+	return basePoints + streak*basePoints/2
+}
+
+type negativeScoring struct{}
+
+func (negativeScoring) Name() string { return ScoringNegative }
+
+func (negativeScoring) Points(answer AnswerContext) int {
+	if answer.IsCorrect {
+		return basePoints
+	}
+	return -negativePenalty
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScoringStrategies(t *testing.T) {
+	tests := []struct {
+		strategy string
+		answer   AnswerContext
+		points   int
+	}{
+		{ScoringFirstCorrect, AnswerContext{IsCorrect: true, IsFirstCorrect: true}, 10},
+		{ScoringFirstCorrect, AnswerContext{IsCorrect: true}, 0},
+		{ScoringFirstCorrect, AnswerContext{}, 0},
+		{ScoringAllCorrect, AnswerContext{IsCorrect: true}, 10},
+		{ScoringAllCorrect, AnswerContext{}, 0},
+		{ScoringSpeed, AnswerContext{IsCorrect: true, TimeLimit: 10 * time.Second}, 20},
+		{ScoringSpeed, AnswerContext{IsCorrect: true, ResponseTime: 5 * time.Second, TimeLimit: 10 * time.Second}, 15},
+		{ScoringSpeed, AnswerContext{IsCorrect: true, ResponseTime: 12 * time.Second, TimeLimit: 10 * time.Second}, 10},
+		{ScoringSpeed, AnswerContext{IsCorrect: true, ResponseTime: time.Minute}, 20},
+		{ScoringSpeed, AnswerContext{ResponseTime: time.Second, TimeLimit: 10 * time.Second}, 0},
+		{ScoringStreak, AnswerContext{IsCorrect: true}, 10},
+		{ScoringStreak, AnswerContext{IsCorrect: true, Streak: 2}, 20},
+		{ScoringStreak, AnswerContext{IsCorrect: true, Streak: 9}, 30},
+		{ScoringStreak, AnswerContext{Streak: 3}, 0},
+		{ScoringNegative, AnswerContext{IsCorrect: true}, 10},
+		{ScoringNegative, AnswerContext{}, -5},
+	}
+	for _, tt := range tests {
+		strategy, err := NewScoringStrategy(tt.strategy)
+		if err != nil {
+			t.Fatalf("NewScoringStrategy(%q) returned %v", tt.strategy, err)
+		}
+		if strategy.Name() != tt.strategy {
+			t.Errorf("NewScoringStrategy(%q).Name() = %q", tt.strategy, strategy.Name())
+		}
+		if points := strategy.Points(tt.answer); points != tt.points {
+			t.Errorf("%s.Points(%+v) = %d, want %d", tt.strategy, tt.answer, points, tt.points)
+		}
+	}
+
+	if _, err := NewScoringStrategy("golf"); err == nil {
+		t.Error("NewScoringStrategy accepted an unknown strategy")
+	}
+}
+
+func TestResolveScoringStrategy(t *testing.T) {
+	tests := []struct {
+		requested, quizDefault, mode string
+		want                         string
+	}{
+		{ScoringSpeed, ScoringStreak, "sync", ScoringSpeed},
+		{"", ScoringStreak, "sync", ScoringStreak},
+		{"", "", "sync", ScoringFirstCorrect},
+		{"", "", "parallel", ScoringAllCorrect},
+		{"", "", "async", ScoringAllCorrect},
+	}
+	for _, tt := range tests {
+		if got := ResolveScoringStrategy(tt.requested, tt.quizDefault, tt.mode); got != tt.want {
+			t.Errorf("ResolveScoringStrategy(%q, %q, %q) = %q, want %q", tt.requested, tt.quizDefault, tt.mode, got, tt.want)
+		}
+	}
+}
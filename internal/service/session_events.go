@@ -0,0 +1,59 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"exam/internal/dtos"
+	"exam/internal/model"
+	"fmt"
+)
+
+var ErrSessionNotFound = errors.New("quiz session not found")
+
+// MaxSessionEventPage is the most events ListSessionEvents returns at once.
+const MaxSessionEventPage = 500
+
+// RecordSessionEvents appends events to their sessions' logs.
+func (s *QuizService) RecordSessionEvents(events []model.QuizSessionEvent) error {
+	if err := s.quizRepo.CreateSessionEvents(events); err != nil {
+		return fmt.Errorf("failed to record session events: %w", err)
+	}
+	return nil
+}
+
+// ListSessionEvents returns up to limit events of a session's log that come
+// after afterSeq, so the log can be paged or stepped through. The log holds
+// every player's answers, so only the quiz creator may read it.
+func (s *QuizService) ListSessionEvents(quizUUID string, sessionID uint, userID uint, afterSeq int, limit int) ([]dtos.SessionEventDTO, error) {
+	session, err := s.quizRepo.GetQuizSessionByID(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quiz session: %w", err)
+	}
+	if session == nil || session.QuizUUID != quizUUID {
+		return nil, ErrSessionNotFound
+	}
+	if _, err := s.getCreatedQuiz(quizUUID, userID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > MaxSessionEventPage {
+		limit = MaxSessionEventPage
+	}
+
+	events, err := s.quizRepo.GetSessionEvents(sessionID, afterSeq, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session events: %w", err)
+	}
+	result := make([]dtos.SessionEventDTO, len(events))
+	for i, event := range events {
+		result[i] = dtos.SessionEventDTO{
+			Seq:        event.Seq,
+			OccurredAt: event.OccurredAt,
+			Direction:  event.Direction,
+			Rejected:   event.Rejected,
+			UserID:     event.UserID,
+			GuestID:    event.GuestID,
+			Message:    dtos.WebsocketMessage{Type: event.Type, Payload: json.RawMessage(event.Payload)},
+		}
+	}
+	return result, nil
+}
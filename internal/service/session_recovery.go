@@ -0,0 +1,115 @@
+package service
+
+import (
+	"encoding/json"
+	"exam/internal/dtos"
+	"exam/internal/model"
+	"fmt"
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// OpenRoomSessions returns the room sessions that never ended, e.g. because
+// the server stopped while they were being played.
+func (s *QuizService) OpenRoomSessions() ([]model.QuizSession, error) {
+	sessions, err := s.quizRepo.GetOpenRoomSessions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get open quiz sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// CheckpointQuizSession stores the state of a session's room so the game can
+// be restored after a restart. A nil checkpoint clears it.
+func (s *QuizService) CheckpointQuizSession(sessionID uint, checkpoint []byte) error {
+	session, err := s.quizRepo.GetQuizSessionByID(sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get quiz session: %w", err)
+	}
+	if session == nil {
+		return ErrSessionNotFound
+	}
+
+	session.Checkpoint = nil
+	session.CheckpointedAt = nil
+	if checkpoint != nil {
+		now := time.Now()
+		session.Checkpoint = datatypes.JSON(checkpoint)
+		session.CheckpointedAt = &now
+	}
+	if err := s.quizRepo.UpdateQuizSession(session); err != nil {
+		return fmt.Errorf("failed to checkpoint quiz session: %w", err)
+	}
+	return nil
+}
+
+// AbortQuizSession ends a session whose game cannot be restored. Its final
+// scores are the points of the answers recorded so far.
+func (s *QuizService) AbortQuizSession(session *model.QuizSession) error {
+	answers, err := s.quizRepo.GetQuizAnswersBySessionID(session.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get quiz answers: %w", err)
+	}
+
+	// Everyone who was there at the start is listed, then the players who
+	// joined later, in the order they first answered.
+	var participants []dtos.ConnectedStudentDTO
+	_ = json.Unmarshal(session.Participants, &participants)
+	scores := make(map[uint]*dtos.PlayerScore)
+	var players []uint
+	for _, participant := range participants {
+		profile := dtos.PlayerProfile{UserID: participant.UserID, Name: participant.UserName, Nickname: participant.Nickname}
+		scores[participant.UserID] = &dtos.PlayerScore{
+			UserID:    participant.UserID,
+			UserName:  profile.DisplayName(),
+			AvatarURL: participant.AvatarURL,
+			TeamID:    participant.TeamID,
+			IsGuest:   participant.IsGuest,
+		}
+		players = append(players, participant.UserID)
+	}
+
+	for _, answer := range answers {
+		var playerID uint
+		switch {
+		case answer.GuestID != nil:
+			playerID = GuestPlayerID(*answer.GuestID)
+		case answer.UserID != nil:
+			playerID = *answer.UserID
+		default:
+			continue
+		}
+
+		score, ok := scores[playerID]
+		if !ok {
+			profile := dtos.PlayerProfile{UserID: playerID, IsGuest: answer.GuestID != nil}
+			if !profile.IsGuest {
+				if resolved, err := s.ResolvePlayerProfile(playerID, ""); err == nil {
+					profile = resolved
+				}
+			}
+			score = &dtos.PlayerScore{UserID: playerID, UserName: profile.DisplayName(), AvatarURL: profile.AvatarURL, IsGuest: profile.IsGuest}
+			scores[playerID] = score
+			players = append(players, playerID)
+		}
+		score.Score += answer.Points
+	}
+
+	result := dtos.SessionResult{Scores: []dtos.PlayerScore{}}
+	for _, playerID := range players {
+		result.Scores = append(result.Scores, *scores[playerID])
+	}
+
+	now := time.Now()
+	finalScoresJSON, _ := json.Marshal(result)
+	session.EndedAt = &now
+	session.FinalScores = datatypes.JSON(finalScoresJSON)
+	session.Aborted = true
+	session.Checkpoint = nil
+	session.CheckpointedAt = nil
+	if err := s.quizRepo.UpdateQuizSession(session); err != nil {
+		return fmt.Errorf("failed to abort quiz session: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"exam/internal/model"
+	"hash/fnv"
+	"math"
+	"math/rand"
+
+	"gorm.io/datatypes"
+)
+
+// Shuffle is one player's view of a quiz: the order they get the questions in
+// and the order of each question's options. It depends on nothing but the
+// session's seed and the player, so it can be rebuilt at any time, e.g. to map
+// what a player saw back to the quiz. Option IDs are never changed, so answers
+// always refer to the canonical QuestionOption IDs.
+type Shuffle struct {
+	seed      int64
+	playerID  uint
+	questions bool
+	options   bool
+}
+
+// NewShuffleSeed returns a fresh seed for a session. It is never zero, which
+// marks sessions played before shuffling existed.
+func NewShuffleSeed() int64 {
+	return rand.Int63n(math.MaxInt64-1) + 1
+}
+
+// NewShuffle returns the player's shuffle for a session, following the quiz's
+// shuffle settings.
+func NewShuffle(quiz *model.Quiz, seed int64, playerID uint) Shuffle {
+	return Shuffle{
+		seed:      seed,
+		playerID:  playerID,
+		questions: quiz.ShuffleQuestions,
+		options:   quiz.ShuffleOptions,
+	}
+}
+
+// QuestionOrder returns the indexes of n questions in the order the player gets them.
+func (s Shuffle) QuestionOrder(n int) []int {
+	if s.questions {
+		return rand.New(rand.NewSource(s.source(0))).Perm(n)
+	}
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	return order
+}
+
+// Question returns the question the player gets at the given position, with
+// its options in the player's order.
+func (s Shuffle) Question(questions []model.Question, position int) model.Question {
+	return s.Options(questions[s.QuestionOrder(len(questions))[position]])
+}
+
+// Options returns the question with its options in the player's order.
+func (s Shuffle) Options(question model.Question) model.Question {
+	if !s.options {
+		return question
+	}
+	var options []json.RawMessage
+	if err := json.Unmarshal(question.Options, &options); err != nil || len(options) < 2 {
+		return question
+	}
+	rng := rand.New(rand.NewSource(s.source(uint64(question.ID))))
+	rng.Shuffle(len(options), func(i, j int) { options[i], options[j] = options[j], options[i] })
+
+	shuffled, err := json.Marshal(options)
+	if err != nil {
+		return question
+	}
+	question.Options = datatypes.JSON(shuffled)
+	return question
+}
+
+// source derives a random source from the seed, the player and a salt, so
+// every player and every question gets an independent order.
+func (s Shuffle) source(salt uint64) int64 {
+	var buf [24]byte
+	binary.BigEndian.PutUint64(buf[0:], uint64(s.seed))
+	binary.BigEndian.PutUint64(buf[8:], uint64(s.playerID))
+	binary.BigEndian.PutUint64(buf[16:], salt)
+	h := fnv.New64a()
+	h.Write(buf[:])
+	return int64(h.Sum64())
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"encoding/json"
+	"exam/internal/model"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestShuffleQuestionOrder(t *testing.T) {
+	shuffled := &model.Quiz{ShuffleQuestions: true}
+	tests := []struct {
+		name      string
+		quiz      *model.Quiz
+		seed      int64
+		playerID  uint
+		same      bool // Same order as seed 1, player 1
+		unchanged bool // Questions keep the quiz's order
+	}{
+		{"same seed and player", shuffled, 1, 1, true, false},
+		{"other player", shuffled, 1, 2, false, false},
+		{"other seed", shuffled, 2, 1, false, false},
+		{"shuffling off", &model.Quiz{}, 1, 1, false, true},
+	}
+
+	const n = 20
+	reference := NewShuffle(shuffled, 1, 1).QuestionOrder(n)
+	identity := make([]int, n)
+	for i := range identity {
+		identity[i] = i
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := NewShuffle(tt.quiz, tt.seed, tt.playerID).QuestionOrder(n)
+			if reflect.DeepEqual(order, reference) != tt.same {
+				t.Errorf("order %v, reference %v: want same=%v", order, reference, tt.same)
+			}
+			if reflect.DeepEqual(order, identity) != tt.unchanged {
+				t.Errorf("order %v: want unchanged=%v", order, tt.unchanged)
+			}
+			sorted := append([]int(nil), order...)
+			sort.Ints(sorted)
+			if !reflect.DeepEqual(sorted, identity) {
+				t.Errorf("order %v is not a permutation of %d questions", order, n)
+			}
+		})
+	}
+}
+
+func TestShuffleOptions(t *testing.T) {
+	question := model.Question{ID: 3, Options: []byte(`[{"id":"a"},{"id":"b"},{"id":"c"},{"id":"d"},{"id":"e"},{"id":"f"}]`)}
+	optionIDs := func(q model.Question) []string {
+		var options []struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(q.Options, &options); err != nil {
+			t.Fatalf("options %s: %v", q.Options, err)
+		}
+		var ids []string
+		for _, option := range options {
+			ids = append(ids, option.ID)
+		}
+		return ids
+	}
+	canonical := optionIDs(question)
+
+	tests := []struct {
+		name     string
+		quiz     *model.Quiz
+		question model.Question
+		changed  bool
+	}{
+		{"shuffling on", &model.Quiz{ShuffleOptions: true}, question, true},
+		{"shuffling off", &model.Quiz{}, question, false},
+		{"single option", &model.Quiz{ShuffleOptions: true}, model.Question{ID: 3, Options: []byte(`[{"id":"a"}]`)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shuffle := NewShuffle(tt.quiz, 1, 1)
+			got := optionIDs(shuffle.Options(tt.question))
+			if again := optionIDs(shuffle.Options(tt.question)); !reflect.DeepEqual(got, again) {
+				t.Fatalf("options came out as %v, then %v", got, again)
+			}
+			if changed := !reflect.DeepEqual(got, optionIDs(tt.question)); changed != tt.changed {
+				t.Errorf("options %v: want changed=%v", got, tt.changed)
+			}
+			sorted := append([]string(nil), got...)
+			sort.Strings(sorted)
+			if len(got) == len(canonical) && !reflect.DeepEqual(sorted, canonical) {
+				t.Errorf("options %v are not a permutation of %v", got, canonical)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"os"
+)
+
+// defaultJWTSecret signs tokens when JWT_SECRET is not set, in development.
+const defaultJWTSecret = "supersecretjwtkey"
+
+// JWTSecret returns the key account tokens are signed and checked with.
+func JWTSecret() []byte {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		secret = defaultJWTSecret
+	}
+	return []byte(secret)
+}
+
+// GuestTokenSecret returns the key guest tokens are signed and checked with.
+// It is never the JWT secret, so a guest token cannot pass for an account
+// token or the other way round. Without GUEST_TOKEN_SECRET it is derived from
+// the JWT secret.
+func GuestTokenSecret() []byte {
+	if secret := os.Getenv("GUEST_TOKEN_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	mac := hmac.New(sha256.New, JWTSecret())
+	mac.Write([]byte("guest-token"))
+	return mac.Sum(nil)
+}
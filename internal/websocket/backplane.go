@@ -0,0 +1,236 @@
+package websocket
+
+import (
+	"sync"
+	"time"
+)
+
+// Backplane connects the hubs of several API instances, called nodes, so that
+// every room can be reached from any of them. It holds a registry all nodes
+// share, of keys that expire unless they are refreshed, e.g. which node owns
+// which room. And it carries messages between nodes over named channels:
+// every room has a channel the node that owns it listens on, and every node
+// has one for the clients it relays and for replies, see relay.go.
+type Backplane interface {
+	// SetIfAbsent stores value under key for ttl, unless the key exists. It
+	// reports whether the value was stored.
+	SetIfAbsent(key string, value string, ttl time.Duration) (bool, error)
+	// Set stores value under key for ttl.
+	Set(key string, value string, ttl time.Duration) error
+	// Get returns the value under key, or false if there is none.
+	Get(key string) (string, bool, error)
+	// Take removes the value under key and returns it, or false if there is
+	// none. Of callers taking the same key at once, only one gets the value.
+	Take(key string) (string, bool, error)
+	// Delete removes key.
+	Delete(key string) error
+	// Publish sends message to whoever listens on channel. Nobody gets it if
+	// nobody listens.
+	Publish(channel string, message []byte) error
+	// Subscribe calls handler with every message published on channel, one at
+	// a time and in order, until the returned function is called.
+	Subscribe(channel string, handler func(message []byte)) (func(), error)
+	// Close releases the backplane's connections.
+	Close() error
+}
+
+// NewBackplane returns the backplane the configuration asks for: Redis if
+// RedisAddr is set, otherwise one that only connects the hubs of this process.
+func NewBackplane(config Config) Backplane {
+	if config.RedisAddr != "" {
+		return NewRedisBackplane(config.RedisAddr, config.RedisPassword)
+	}
+	return NewMemoryBackplane()
+}
+
+// subscriptionBacklog is how many messages a subscription holds before
+// publishers have to wait for its handler.
+const subscriptionBacklog = 1024
+
+// subscription runs a handler on the messages of a channel in its own
+// goroutine, so a slow handler holds up neither publishers nor other channels.
+type subscription struct {
+	messages chan []byte
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func newSubscription(handler func(message []byte)) *subscription {
+	s := &subscription{messages: make(chan []byte, subscriptionBacklog), done: make(chan struct{})}
+	go func() {
+		for {
+			select {
+			case message := <-s.messages:
+				handler(message)
+			case <-s.done:
+				return
+			}
+		}
+	}()
+	return s
+}
+
+func (s *subscription) push(message []byte) {
+	select {
+	case s.messages <- message:
+	case <-s.done:
+	}
+}
+
+func (s *subscription) stop() {
+	s.stopOnce.Do(func() { close(s.done) })
+}
+
+// memorySweepInterval is how often a memoryBackplane drops the values that
+// expired without being read again, such as tickets nobody redeemed.
+const memorySweepInterval = time.Minute
+
+// memoryBackplane is a Backplane within a single process. It is what a lone
+// node uses, and lets several hubs of one process stand in for nodes.
+type memoryBackplane struct {
+	mu            sync.Mutex
+	values        map[string]memoryValue
+	subscriptions map[string]map[*subscription]bool // Channel -> subscriptions
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+type memoryValue struct {
+	value     string
+	expiresAt time.Time // Zero for a value that does not expire
+}
+
+func NewMemoryBackplane() Backplane {
+	b := &memoryBackplane{
+		values:        make(map[string]memoryValue),
+		subscriptions: make(map[string]map[*subscription]bool),
+		closed:        make(chan struct{}),
+	}
+	go b.sweep(memorySweepInterval)
+	return b
+}
+
+// sweep drops expired values every interval until the backplane is closed.
+func (b *memoryBackplane) sweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			b.removeExpired(time.Now())
+		case <-b.closed:
+			return
+		}
+	}
+}
+
+func (b *memoryBackplane) removeExpired(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for key, v := range b.values {
+		if !v.expiresAt.IsZero() && now.After(v.expiresAt) {
+			delete(b.values, key)
+		}
+	}
+}
+
+// get returns the live value under key. The caller holds mu.
+func (b *memoryBackplane) get(key string) (string, bool) {
+	v, ok := b.values[key]
+	if !ok {
+		return "", false
+	}
+	if !v.expiresAt.IsZero() && time.Now().After(v.expiresAt) {
+		delete(b.values, key)
+		return "", false
+	}
+	return v.value, true
+}
+
+// set stores value under key. The caller holds mu.
+func (b *memoryBackplane) set(key string, value string, ttl time.Duration) {
+	v := memoryValue{value: value}
+	if ttl > 0 {
+		v.expiresAt = time.Now().Add(ttl)
+	}
+	b.values[key] = v
+}
+
+func (b *memoryBackplane) SetIfAbsent(key string, value string, ttl time.Duration) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.get(key); ok {
+		return false, nil
+	}
+	b.set(key, value, ttl)
+	return true, nil
+}
+
+func (b *memoryBackplane) Set(key string, value string, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.set(key, value, ttl)
+	return nil
+}
+
+func (b *memoryBackplane) Get(key string) (string, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	value, ok := b.get(key)
+	return value, ok, nil
+}
+
+func (b *memoryBackplane) Take(key string) (string, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	value, ok := b.get(key)
+	delete(b.values, key)
+	return value, ok, nil
+}
+
+func (b *memoryBackplane) Delete(key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.values, key)
+	return nil
+}
+
+func (b *memoryBackplane) Publish(channel string, message []byte) error {
+	b.mu.Lock()
+	var subscribers []*subscription
+	for s := range b.subscriptions[channel] {
+		subscribers = append(subscribers, s)
+	}
+	b.mu.Unlock()
+
+	for _, s := range subscribers {
+		s.push(message)
+	}
+	return nil
+}
+
+func (b *memoryBackplane) Subscribe(channel string, handler func(message []byte)) (func(), error) {
+	s := newSubscription(handler)
+	b.mu.Lock()
+	if b.subscriptions[channel] == nil {
+		b.subscriptions[channel] = make(map[*subscription]bool)
+	}
+	b.subscriptions[channel][s] = true
+	b.mu.Unlock()
+
+	return func() {
+		b.mu.Lock()
+		delete(b.subscriptions[channel], s)
+		if len(b.subscriptions[channel]) == 0 {
+			delete(b.subscriptions, channel)
+		}
+		b.mu.Unlock()
+		s.stop()
+	}, nil
+}
+
+func (b *memoryBackplane) Close() error {
+	b.closeOnce.Do(func() { close(b.closed) })
+	return nil
+}
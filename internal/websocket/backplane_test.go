@@ -0,0 +1,52 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryBackplaneRemoveExpired(t *testing.T) {
+	b := NewMemoryBackplane().(*memoryBackplane)
+	defer b.Close()
+
+	b.Set("quiz:ticket:expired", "{}", time.Millisecond)
+	b.Set("quiz:ticket:live", "{}", time.Hour)
+	b.Set("quiz:pin:123456", "room", 0)
+
+	b.removeExpired(time.Now().Add(time.Second))
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"quiz:ticket:expired", false},
+		{"quiz:ticket:live", true},
+		{"quiz:pin:123456", true},
+	}
+	for _, tt := range tests {
+		if _, ok := b.values[tt.key]; ok != tt.want {
+			t.Errorf("%s kept = %v, want %v", tt.key, ok, tt.want)
+		}
+	}
+}
+
+func TestMemoryBackplaneTake(t *testing.T) {
+	b := NewMemoryBackplane()
+	defer b.Close()
+
+	b.Set("quiz:ticket:a", "claims", time.Minute)
+	if value, ok, err := b.Take("quiz:ticket:a"); err != nil || !ok || value != "claims" {
+		t.Fatalf("Take = %q, %v, %v; want the value", value, ok, err)
+	}
+	if _, ok, _ := b.Take("quiz:ticket:a"); ok {
+		t.Fatal("a key could be taken twice")
+	}
+
+	b.Set("quiz:ticket:b", "claims", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok, _ := b.Take("quiz:ticket:b"); ok {
+		t.Fatal("an expired key could be taken")
+	}
+}
@@ -0,0 +1,429 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"exam/internal/dtos"
+	"exam/internal/model"
+	"exam/internal/service"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+var errHubClosed = errors.New("the server is shutting down")
+
+// roomCheckpoint is a game saved when the server shuts down, see
+// Hub.Shutdown. The game was paused first, so every timer is stored as the
+// time it had left; the game is restored paused and goes on once the host
+// resumes it.
+type roomCheckpoint struct {
+	RoomID         string                      `json:"room_id"`
+	HostID         uint                        `json:"host_id"`
+	Mode           string                      `json:"mode"`
+	Scoring        string                      `json:"scoring"`
+	RevealDuration time.Duration               `json:"reveal_duration"`
+	ShuffleSeed    int64                       `json:"shuffle_seed"`
+	QuestionIDs    []uint                      `json:"question_ids"`    // The questions of a sync game, in order
+	Draws          map[uint][]uint             `json:"draws,omitempty"` // UserID -> the questions a parallel player drew
+	Scores         []dtos.PlayerScore          `json:"scores"`
+	Streaks        map[uint]int                `json:"streaks"`
+	Profiles       []dtos.PlayerProfile        `json:"profiles"`
+	Progress       map[uint]checkpointProgress `json:"progress"`
+	Kicked         []uint                      `json:"kicked,omitempty"`
+	Teams          []team                      `json:"teams,omitempty"`
+	PlayerTeams    map[uint]string             `json:"player_teams,omitempty"`
+	TeamScoring    string                      `json:"team_scoring"`
+	EventSeq       int                         `json:"event_seq"` // The last event logged
+
+	SessionRemaining *time.Duration `json:"session_remaining,omitempty"` // Nil without a session time limit
+	AdvanceRemaining *time.Duration `json:"advance_remaining,omitempty"` // Nil unless a move to the next step was pending
+
+	// Sync games
+	QuestionIndex     int                          `json:"question_index"`
+	QuestionOpen      bool                         `json:"question_open"`
+	QuestionElapsed   time.Duration                `json:"question_elapsed"`             // Since the question was sent, not counting pauses
+	QuestionRemaining *time.Duration               `json:"question_remaining,omitempty"` // Nil unless the question timer was running
+	Answered          []uint                       `json:"answered,omitempty"`
+	AnsweredCorrectly bool                         `json:"answered_correctly"`
+	Tally             checkpointTally              `json:"tally"`
+	Results           *dtos.QuestionResultsPayload `json:"results,omitempty"`
+
+	// Parallel games
+	PlayerQuestions map[uint]int           `json:"player_questions,omitempty"` // UserID -> current question index
+	Finished        []uint                 `json:"finished,omitempty"`
+	PlayerElapsed   map[uint]time.Duration `json:"player_elapsed,omitempty"` // UserID -> time on the current question
+	PlayerTimers    map[uint]time.Duration `json:"player_timers,omitempty"`  // UserID -> time left on the current question
+}
+
+type checkpointProgress struct {
+	Correct   int           `json:"correct"`
+	Incorrect int           `json:"incorrect"`
+	TimeSpent time.Duration `json:"time_spent"`
+}
+
+type checkpointTally struct {
+	Options   map[string]int `json:"options"`
+	Correct   int            `json:"correct"`
+	Total     int            `json:"total"`
+	TotalTime time.Duration  `json:"total_time"`
+}
+
+// checkpoint pauses a running game and saves it in its quiz session, so it
+// can be restored after the restart. It reports whether the game was saved.
+func (r *Room) checkpoint() bool {
+	if (r.State != StateInProgress && r.State != StatePaused) || r.quizSessionID == 0 {
+		return false
+	}
+	if r.State == StateInProgress {
+		r.pauseGame(nil)
+	}
+
+	// The restored game logs on from the last event written.
+	eventSeq := 0
+	if r.events != nil {
+		eventSeq = r.events.seq
+		r.events.close()
+		<-r.events.written
+		r.events = nil
+	}
+
+	data, err := json.Marshal(r.newCheckpoint(eventSeq))
+	if err != nil {
+		log.Printf("Error marshalling checkpoint of room %s: %v", r.ID, err)
+		return false
+	}
+	if err := r.quizService.CheckpointQuizSession(r.quizSessionID, data); err != nil {
+		log.Printf("Error checkpointing session %d of room %s: %v", r.quizSessionID, r.ID, err)
+		return false
+	}
+	log.Printf("Checkpointed session %d of room %s", r.quizSessionID, r.ID)
+	return true
+}
+
+// newCheckpoint captures the paused game.
+func (r *Room) newCheckpoint(eventSeq int) roomCheckpoint {
+	cp := roomCheckpoint{
+		RoomID:            r.ID,
+		HostID:            r.hostID,
+		Mode:              r.Mode,
+		Scoring:           r.scoring.Name(),
+		RevealDuration:    r.revealDuration,
+		ShuffleSeed:       r.shuffleSeed,
+		Draws:             make(map[uint][]uint),
+		Scores:            []dtos.PlayerScore{},
+		Streaks:           r.streaks,
+		Profiles:          []dtos.PlayerProfile{},
+		Progress:          make(map[uint]checkpointProgress),
+		PlayerTeams:       r.playerTeams,
+		TeamScoring:       r.teamScoring,
+		EventSeq:          eventSeq,
+		QuestionIndex:     r.currentQuestionIndex,
+		QuestionOpen:      r.questionOpen,
+		AnsweredCorrectly: r.isQuestionAnsweredCorrectly,
+		Tally: checkpointTally{
+			Options:   r.tally.options,
+			Correct:   r.tally.correct,
+			Total:     r.tally.total,
+			TotalTime: r.tally.totalTime,
+		},
+		Results:         r.results,
+		PlayerQuestions: r.clientProgress,
+		PlayerElapsed:   make(map[uint]time.Duration),
+		PlayerTimers:    make(map[uint]time.Duration),
+	}
+
+	cp.QuestionIDs = questionIDs(r.quiz.Questions)
+	for userID, questions := range r.draws {
+		cp.Draws[userID] = questionIDs(questions)
+	}
+	for _, score := range r.scores {
+		cp.Scores = append(cp.Scores, *score)
+	}
+	for _, profile := range r.profiles {
+		cp.Profiles = append(cp.Profiles, profile)
+	}
+	for userID, progress := range r.progress {
+		cp.Progress[userID] = checkpointProgress{Correct: progress.correct, Incorrect: progress.incorrect, TimeSpent: progress.timeSpent}
+	}
+	for userID := range r.kicked {
+		cp.Kicked = append(cp.Kicked, userID)
+	}
+	for _, t := range r.teams {
+		cp.Teams = append(cp.Teams, *t)
+	}
+
+	if r.sessionPaused {
+		cp.SessionRemaining = &r.sessionRemaining
+	}
+	if r.pausedAdvance {
+		remaining := max(time.Until(r.advanceAt), 0)
+		cp.AdvanceRemaining = &remaining
+	}
+	if !r.questionSentAt.IsZero() {
+		cp.QuestionElapsed = r.pausedAt.Sub(r.questionSentAt)
+	}
+	if r.pausedQuestion {
+		cp.QuestionRemaining = &r.pausedRemaining
+	}
+	for userID := range r.answeredPlayers {
+		cp.Answered = append(cp.Answered, userID)
+	}
+
+	for userID := range r.finishedClients {
+		cp.Finished = append(cp.Finished, userID)
+	}
+	for userID, sentAt := range r.clientQuestionSentAt {
+		cp.PlayerElapsed[userID] = r.pausedAt.Sub(sentAt)
+	}
+	for userID, pt := range r.clientTimers {
+		cp.PlayerTimers[userID] = pt.remaining
+	}
+	return cp
+}
+
+func questionIDs(questions []model.Question) []uint {
+	ids := make([]uint, len(questions))
+	for i, question := range questions {
+		ids[i] = question.ID
+	}
+	return ids
+}
+
+// restore puts a checkpointed game back into a new room, paused. Nobody is
+// connected yet, so every player gets the reconnect grace period to come back.
+func (r *Room) restore(sessionID uint, quiz *model.Quiz, cp roomCheckpoint) error {
+	scoring, err := service.NewScoringStrategy(cp.Scoring)
+	if err != nil {
+		return err
+	}
+	if r.Mode = cp.Mode; r.Mode != "parallel" {
+		quiz.Questions = service.QuestionsByID(quiz, cp.QuestionIDs)
+		if len(quiz.Questions) != len(cp.QuestionIDs) {
+			return fmt.Errorf("questions of the quiz were deleted")
+		}
+	}
+	for userID, ids := range cp.Draws {
+		questions := service.QuestionsByID(quiz, ids)
+		if len(questions) != len(ids) {
+			return fmt.Errorf("questions of the quiz were deleted")
+		}
+		r.draws[userID] = questions
+	}
+
+	now := time.Now()
+	r.quizSessionID = sessionID
+	r.quiz = quiz
+	r.scoring = scoring
+	r.revealDuration = cp.RevealDuration
+	r.shuffleSeed = cp.ShuffleSeed
+	r.State = StatePaused
+	r.pausedAt = now
+	r.activitySince = now
+	r.epoch++
+
+	for _, profile := range cp.Profiles {
+		r.profiles[profile.UserID] = profile
+		if profile.IsGuest {
+			r.guestNicknames[strings.ToLower(profile.Nickname)] = profile.UserID
+		}
+	}
+	for _, score := range cp.Scores {
+		score.TeamID = ""
+		r.scores[score.UserID] = &score
+	}
+	for userID, streak := range cp.Streaks {
+		r.streaks[userID] = streak
+	}
+	for userID, progress := range cp.Progress {
+		r.progress[userID] = &playerProgress{correct: progress.Correct, incorrect: progress.Incorrect, timeSpent: progress.TimeSpent}
+	}
+	for _, userID := range cp.Kicked {
+		r.kicked[userID] = true
+	}
+	for i := range cp.Teams {
+		r.teams = append(r.teams, &cp.Teams[i])
+	}
+	for userID, teamID := range cp.PlayerTeams {
+		r.playerTeams[userID] = teamID
+	}
+	r.teamScoring = cp.TeamScoring
+
+	r.currentQuestionIndex = cp.QuestionIndex
+	r.questionOpen = cp.QuestionOpen
+	r.questionSentAt = now.Add(-cp.QuestionElapsed)
+	for _, userID := range cp.Answered {
+		r.answeredPlayers[userID] = true
+	}
+	r.isQuestionAnsweredCorrectly = cp.AnsweredCorrectly
+	for option, count := range cp.Tally.Options {
+		r.tally.options[option] = count
+	}
+	r.tally.correct = cp.Tally.Correct
+	r.tally.total = cp.Tally.Total
+	r.tally.totalTime = cp.Tally.TotalTime
+	r.results = cp.Results
+
+	for userID, questionIndex := range cp.PlayerQuestions {
+		r.clientProgress[userID] = questionIndex
+	}
+	for _, userID := range cp.Finished {
+		r.finishedClients[userID] = true
+	}
+	for userID, elapsed := range cp.PlayerElapsed {
+		r.clientQuestionSentAt[userID] = now.Add(-elapsed)
+	}
+	for userID, remaining := range cp.PlayerTimers {
+		r.clientTimerSeq++
+		r.clientTimers[userID] = &playerTimer{
+			token:         r.clientTimerSeq,
+			questionIndex: r.clientProgress[userID],
+			deadline:      now.Add(remaining),
+			paused:        true,
+			remaining:     remaining,
+		}
+	}
+
+	// resumeGame picks the timers up from here.
+	if cp.QuestionRemaining != nil {
+		r.pausedQuestion = true
+		r.pausedRemaining = *cp.QuestionRemaining
+	}
+	if cp.AdvanceRemaining != nil {
+		r.pausedAdvance = true
+		r.advanceAt = now.Add(*cp.AdvanceRemaining)
+		r.advanceFn = r.pendingStep()
+	}
+	if cp.SessionRemaining != nil {
+		r.sessionPaused = true
+		r.sessionRemaining = *cp.SessionRemaining
+	}
+
+	r.events = newEventLog(sessionID, cp.EventSeq, r.quizService)
+	for userID := range r.scores {
+		if !r.kicked[userID] {
+			r.startGracePeriod(userID)
+		}
+	}
+	return nil
+}
+
+// pendingStep is the move to the next step of the game that was pending when
+// it was checkpointed: the first question after the countdown, the reveal
+// after a sync question closed, or the next sync question after a reveal.
+func (r *Room) pendingStep() func() {
+	switch {
+	case r.Mode == "parallel":
+		return r.sendFirstQuestions
+	case r.currentQuestionIndex >= 0 && !r.questionOpen && r.results == nil:
+		return r.revealResults
+	default:
+		return r.sendNextQuestion
+	}
+}
+
+// Shutdown closes every room for a server restart. Running games are paused
+// and checkpointed for RestoreSessions, and clients get a 'server_restart'
+// message before their connection is closed with 1012 (service restart).
+// Rooms can no longer be opened, and clients relayed to rooms on other nodes
+// are closed the same way, to reconnect through another node. It returns once
+// every room has shut down, or with ctx's error if that takes too long.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	if !h.closed {
+		close(h.quit)
+	}
+	h.closed = true
+	rooms := make([]*Room, 0, len(h.rooms))
+	for _, room := range h.rooms {
+		rooms = append(rooms, room)
+	}
+	h.mu.Unlock()
+
+	h.closeRelays()
+	for _, room := range rooms {
+		room.Suspend()
+	}
+	for _, room := range rooms {
+		select {
+		case <-room.Done():
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return h.backplane.Close()
+}
+
+// RestoreSessions picks up the games left open when the server last stopped.
+// Games checkpointed within Config.CheckpointTTL are restored into their
+// rooms, paused; the others, e.g. after a crash, are aborted with the scores
+// of the answers recorded so far. Games whose room is live on another node
+// are left to it. Call it once at startup, before serving.
+func (h *Hub) RestoreSessions() error {
+	if h.quizService == nil {
+		return fmt.Errorf("hub has no quiz service configured")
+	}
+	sessions, err := h.quizService.OpenRoomSessions()
+	if err != nil {
+		return err
+	}
+
+	for i := range sessions {
+		session := &sessions[i]
+		// The claim also keeps nodes starting at the same time from both
+		// restoring or aborting a game.
+		if err := h.claimRoom(session.RoomID, session.QuizUUID); err != nil {
+			if !errors.Is(err, errRoomOwned) {
+				log.Printf("Error claiming room %s of session %d: %v", session.RoomID, session.ID, err)
+			}
+			continue
+		}
+		if session.CheckpointedAt != nil && time.Since(*session.CheckpointedAt) <= h.config.CheckpointTTL {
+			err := h.restoreRoom(session)
+			if err == nil {
+				continue
+			}
+			log.Printf("Error restoring session %d: %v", session.ID, err)
+		}
+		err := h.quizService.AbortQuizSession(session)
+		h.releaseRoom(session.RoomID, "")
+		if err != nil {
+			log.Printf("Error aborting session %d: %v", session.ID, err)
+			continue
+		}
+		log.Printf("Aborted session %d of room %s, which was left open", session.ID, session.RoomID)
+	}
+	return nil
+}
+
+// restoreRoom reopens the room of a checkpointed session with its game.
+func (h *Hub) restoreRoom(session *model.QuizSession) error {
+	var cp roomCheckpoint
+	if err := json.Unmarshal(session.Checkpoint, &cp); err != nil {
+		return fmt.Errorf("invalid checkpoint: %w", err)
+	}
+	if _, ok := h.GetRoom(cp.RoomID); ok {
+		return fmt.Errorf("room %s is already open", cp.RoomID)
+	}
+	quiz, err := h.quizService.GetQuizWithQuestions(session.QuizUUID)
+	if err != nil {
+		return err
+	}
+	// A game is restored once; should the server go down again without a
+	// checkpoint, it is aborted with the answers recorded until then.
+	if err := h.quizService.CheckpointQuizSession(session.ID, nil); err != nil {
+		return err
+	}
+
+	room := NewRoom(cp.RoomID, session.QuizUUID, cp.HostID, h.quizService, h.config)
+	if err := room.restore(session.ID, quiz, cp); err != nil {
+		return err
+	}
+	if _, created := h.getOrCreateRoom(room.ID, func() *Room { return room }); !created {
+		return errHubClosed
+	}
+	log.Printf("Restored session %d in room %s, paused at question %d", session.ID, room.ID, cp.QuestionIndex+1)
+	return nil
+}
@@ -0,0 +1,251 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"exam/internal/dtos"
+	"exam/internal/i18n"
+	"exam/internal/model"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"gorm.io/datatypes"
+)
+
+// checkpointOf returns the checkpoint stored in a session.
+func checkpointOf(t *testing.T, repo *fakeQuizRepository, sessionID uint) roomCheckpoint {
+	t.Helper()
+	session, _ := repo.GetQuizSessionByID(sessionID)
+	var cp roomCheckpoint
+	if session == nil || session.CheckpointedAt == nil || json.Unmarshal(session.Checkpoint, &cp) != nil {
+		t.Fatalf("session %d was not checkpointed: %+v", sessionID, session)
+	}
+	return cp
+}
+
+// lastEventSeq is the sequence number of the last event logged for a session.
+func lastEventSeq(repo *fakeQuizRepository, sessionID uint) int {
+	seq := 0
+	for _, event := range repo.sessionEvents(sessionID) {
+		seq = max(seq, event.Seq)
+	}
+	return seq
+}
+
+func roomScores(r *Room) map[uint]int {
+	scores := make(map[uint]int)
+	for userID, score := range r.scores {
+		scores[userID] = score.Score
+	}
+	return scores
+}
+
+func TestRestoreSyncGame(t *testing.T) {
+	i18n.Init()
+	repo := newFakeQuizRepository(3)
+	for i := range repo.quiz.Questions {
+		repo.quiz.Questions[i].Timer = 30
+	}
+	hub, quizService := newTestNode(t, "node", NewMemoryBackplane(), repo)
+
+	lobby, err := quizService.OpenLobby("quiz", 99)
+	if err != nil {
+		t.Fatalf("OpenLobby returned %v", err)
+	}
+	host := dialRoom(t, hub, lobby.RoomID, dtos.PlayerProfile{UserID: 99, Nickname: "host"})
+	readMessage(t, host, "state_snapshot")
+	bee := dialRoom(t, hub, lobby.RoomID, dtos.PlayerProfile{UserID: 1, Nickname: "bee"})
+	dialRoom(t, hub, lobby.RoomID, dtos.PlayerProfile{UserID: 2, Nickname: "ant"})
+	eventually(t, "the players are in the room", func() bool {
+		return hub.GetRoomClientCount(lobby.RoomID) == 2
+	})
+	if err := quizService.StartQuiz("quiz", lobby.RoomID, 99, dtos.StartQuizRequest{Mode: "sync"}); err != nil {
+		t.Fatalf("StartQuiz returned %v", err)
+	}
+	readMessage(t, bee, "next_question")
+	bee.WriteMessage(websocket.TextMessage, []byte(`{"type":"submit_answer","payload":{"question_id":1,"answer":"a"}}`))
+	readMessage(t, bee, "answer_result")
+	host.WriteMessage(websocket.TextMessage, []byte(`{"type":"pause_game"}`))
+	readMessage(t, host, "game_paused")
+
+	// The game as it was paused, with the question still open for ant.
+	room, _ := hub.GetRoom(lobby.RoomID)
+	var scores map[uint]int
+	var remaining time.Duration
+	room.query(func() {
+		scores = roomScores(room)
+		remaining = room.pausedRemaining
+	})
+	if scores[1] == 0 || remaining <= 0 || remaining > 30*time.Second {
+		t.Fatalf("paused with scores %v and %v left on the question", scores, remaining)
+	}
+
+	if err := hub.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown returned %v", err)
+	}
+	restarted, _ := newTestNode(t, "node", NewMemoryBackplane(), repo)
+	if err := restarted.RestoreSessions(); err != nil {
+		t.Fatalf("RestoreSessions returned %v", err)
+	}
+	restored, ok := restarted.GetRoom(lobby.RoomID)
+	if !ok {
+		t.Fatal("the game was not restored")
+	}
+	seq := lastEventSeq(repo, 1)
+	if seq == 0 {
+		t.Fatal("no events were logged before the restart")
+	}
+	restored.query(func() {
+		if restored.State != StatePaused {
+			t.Errorf("restored in state %v, want paused", restored.State)
+		}
+		if got := roomScores(restored); len(got) != len(scores) || got[1] != scores[1] || got[2] != scores[2] {
+			t.Errorf("restored scores %v, want %v", got, scores)
+		}
+		if restored.currentQuestionIndex != 0 || !restored.questionOpen || !restored.answeredPlayers[1] {
+			t.Errorf("restored at question %d, open %v, answered %v; want question 0 open and answered by 1",
+				restored.currentQuestionIndex, restored.questionOpen, restored.answeredPlayers)
+		}
+		if !restored.pausedQuestion || restored.pausedRemaining != remaining {
+			t.Errorf("restored with %v left on the question, want %v", restored.pausedRemaining, remaining)
+		}
+		if restored.events == nil || restored.events.seq != seq {
+			t.Errorf("the restored event log does not go on from event %d", seq)
+		}
+	})
+	if session, _ := repo.GetQuizSessionByID(1); session.Checkpoint != nil || session.EndedAt != nil {
+		t.Errorf("the restored session kept its checkpoint or was ended: %+v", session)
+	}
+}
+
+func TestRestoreParallelGame(t *testing.T) {
+	i18n.Init()
+	repo := newFakeQuizRepository(3)
+	for i := range repo.quiz.Questions {
+		repo.quiz.Questions[i].Timer = 30
+	}
+	hub, quizService := newTestNode(t, "node", NewMemoryBackplane(), repo)
+
+	lobby, err := quizService.OpenLobby("quiz", 99)
+	if err != nil {
+		t.Fatalf("OpenLobby returned %v", err)
+	}
+	bee := dialRoom(t, hub, lobby.RoomID, dtos.PlayerProfile{UserID: 1, Nickname: "bee"})
+	ant := dialRoom(t, hub, lobby.RoomID, dtos.PlayerProfile{UserID: 2, Nickname: "ant"})
+	eventually(t, "the players are in the room", func() bool {
+		return hub.GetRoomClientCount(lobby.RoomID) == 2
+	})
+	if err := quizService.StartQuiz("quiz", lobby.RoomID, 99, dtos.StartQuizRequest{Mode: "parallel"}); err != nil {
+		t.Fatalf("StartQuiz returned %v", err)
+	}
+	readMessage(t, bee, "next_question")
+	readMessage(t, ant, "next_question")
+	bee.WriteMessage(websocket.TextMessage, []byte(`{"type":"submit_answer","payload":{"question_id":1,"answer":"a"}}`))
+	room, _ := hub.GetRoom(lobby.RoomID)
+	eventually(t, "bee is on the second question", func() bool {
+		var progress int
+		room.query(func() { progress = room.clientProgress[1] })
+		return progress == 1
+	})
+
+	// Shutting down pauses the running game before checkpointing it.
+	if err := hub.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown returned %v", err)
+	}
+	cp := checkpointOf(t, repo, 1)
+	if cp.PlayerQuestions[1] != 1 || cp.PlayerQuestions[2] != 0 {
+		t.Fatalf("checkpointed at questions %v, want bee on 1 and ant on 0", cp.PlayerQuestions)
+	}
+	for userID, remaining := range cp.PlayerTimers {
+		if remaining <= 0 || remaining > 30*time.Second {
+			t.Fatalf("checkpointed with %v left on player %d's question", remaining, userID)
+		}
+	}
+	if cp.EventSeq == 0 || cp.EventSeq != lastEventSeq(repo, 1) {
+		t.Fatalf("checkpointed at event %d, but %d were logged", cp.EventSeq, lastEventSeq(repo, 1))
+	}
+
+	restarted, _ := newTestNode(t, "node", NewMemoryBackplane(), repo)
+	if err := restarted.RestoreSessions(); err != nil {
+		t.Fatalf("RestoreSessions returned %v", err)
+	}
+	restored, ok := restarted.GetRoom(lobby.RoomID)
+	if !ok {
+		t.Fatal("the game was not restored")
+	}
+	restored.query(func() {
+		if restored.State != StatePaused || restored.Mode != "parallel" {
+			t.Errorf("restored a %s game in state %v, want a paused parallel game", restored.Mode, restored.State)
+		}
+		scores := roomScores(restored)
+		for _, score := range cp.Scores {
+			if scores[score.UserID] != score.Score {
+				t.Errorf("restored score %d for player %d, want %d", scores[score.UserID], score.UserID, score.Score)
+			}
+		}
+		if scores[1] == 0 {
+			t.Error("bee's correct answer scored nothing")
+		}
+		for userID, questionIndex := range cp.PlayerQuestions {
+			if restored.clientProgress[userID] != questionIndex {
+				t.Errorf("restored player %d at question %d, want %d", userID, restored.clientProgress[userID], questionIndex)
+			}
+		}
+		for userID, remaining := range cp.PlayerTimers {
+			timer := restored.clientTimers[userID]
+			if timer == nil || !timer.paused || timer.remaining != remaining || timer.questionIndex != cp.PlayerQuestions[userID] {
+				t.Errorf("restored player %d's timer as %+v, want %v left, paused", userID, timer, remaining)
+			}
+		}
+		if restored.events == nil || restored.events.seq != cp.EventSeq {
+			t.Errorf("the restored event log does not go on from event %d", cp.EventSeq)
+		}
+	})
+}
+
+func TestRestoreSessionsAbortsUnrestorableGames(t *testing.T) {
+	repo := newFakeQuizRepository(2)
+	participants, _ := json.Marshal([]dtos.ConnectedStudentDTO{{UserID: 1, UserName: "Bee"}, {UserID: 2, UserName: "Ant"}})
+	staleAt := time.Now().Add(-DefaultConfig().CheckpointTTL - time.Minute)
+	sessions := map[string]*model.QuizSession{
+		"without a checkpoint": {QuizUUID: "quiz", RoomID: "crashed", Mode: "sync", Participants: participants},
+		"with a stale checkpoint": {QuizUUID: "quiz", RoomID: "stale", Mode: "parallel", Participants: participants,
+			Checkpoint: datatypes.JSON(`{"room_id":"stale","mode":"parallel"}`), CheckpointedAt: &staleAt},
+	}
+	bee, ant := uint(1), uint(2)
+	for _, session := range sessions {
+		repo.CreateQuizSession(session)
+		for questionID := uint(1); questionID <= 2; questionID++ {
+			repo.CreateQuizAnswer(&model.QuizAnswer{QuizSessionID: session.ID, QuestionID: questionID, UserID: &bee, IsCorrect: true, Points: 100})
+		}
+		repo.CreateQuizAnswer(&model.QuizAnswer{QuizSessionID: session.ID, QuestionID: 1, UserID: &ant, Points: 0})
+	}
+
+	hub, _ := newTestNode(t, "node", NewMemoryBackplane(), repo)
+	if err := hub.RestoreSessions(); err != nil {
+		t.Fatalf("RestoreSessions returned %v", err)
+	}
+	for name, session := range sessions {
+		t.Run(name, func(t *testing.T) {
+			if _, ok := hub.GetRoom(session.RoomID); ok {
+				t.Fatal("the game was restored")
+			}
+			aborted, _ := repo.GetQuizSessionByID(session.ID)
+			if !aborted.Aborted || aborted.EndedAt == nil || aborted.Checkpoint != nil || aborted.CheckpointedAt != nil {
+				t.Fatalf("the session was not aborted: %+v", aborted)
+			}
+			var result dtos.SessionResult
+			if err := json.Unmarshal(aborted.FinalScores, &result); err != nil {
+				t.Fatalf("malformed final scores: %v", err)
+			}
+			if len(result.Scores) != 2 || result.Scores[0].UserID != 1 || result.Scores[0].Score != 200 ||
+				result.Scores[1].UserID != 2 || result.Scores[1].Score != 0 {
+				t.Fatalf("final scores %+v, want 200 for player 1 and 0 for player 2", result.Scores)
+			}
+		})
+	}
+	if hub.HasRoom("crashed") || hub.HasRoom("stale") {
+		t.Error("an aborted game's room is still claimed")
+	}
+}
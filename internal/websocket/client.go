@@ -1,9 +1,10 @@
 package websocket
 
 import (
-	"encoding/json"
 	"exam/internal/dtos"
 	"log"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -16,20 +17,69 @@ const (
 
 // Client is a middleman between the websocket connection and the hub.
 type Client struct {
-	Room   *Room
-	Conn   *websocket.Conn
-	Send   chan []byte
-	UserID uint
+	Room    *Room // Nil while the room lives on another node, see relay
+	Conn    *websocket.Conn
+	Send    chan []byte
+	UserID  uint
+	Profile dtos.PlayerProfile // Resolved when the client joins
+
+	// Spectator clients watch the room without playing, see spectator.go.
+	Spectator bool
+
+	// relay passes the client on to its room when the room lives on another
+	// node, see relay.go.
+	relay *relayLink
+
+	// Heartbeat fields, written by ReadPump and read by the room.
+	lastPong atomic.Int64 // Unix nanoseconds of the last pong
+	rtt      atomic.Int64 // Round trip of the last ping, in nanoseconds
+
+	// closeCode goes in the close frame once the room closes Send; 0 sends
+	// an empty one. The room sets it before closing Send.
+	closeCode int
+
+	// Presence fields, owned by the room.
+	presence     string
+	lastActivity time.Time
+	reportedIdle bool // The client said its player stepped away
+
+	// Outbound fields, owned by the room, see outbound.go.
+	pending map[string][]byte // State messages held back until Send drains, by type
+	slow    bool              // Fell too far behind and is being evicted
 }
 
 // ReadPump pumps messages from the websocket connection to the room.
 func (c *Client) ReadPump() {
 	defer func() {
-		c.Room.Unregister <- c
+		if c.relay != nil {
+			c.relay.leave()
+		} else {
+			deliver(c.Room, c.Room.Unregister, c)
+		}
 		c.Conn.Close()
 	}()
 	c.Conn.SetReadLimit(maxMessageSize)
+	config := c.config()
+
+	// A client that stops answering pings is dropped once PongTimeout passes.
+	if pongTimeout := config.PongTimeout; config.PingInterval > 0 {
+		c.Conn.SetReadDeadline(time.Now().Add(pongTimeout))
+		c.Conn.SetPongHandler(func(appData string) error {
+			now := time.Now()
+			c.lastPong.Store(now.UnixNano())
+			if sentAt, err := strconv.ParseInt(appData, 10, 64); err == nil {
+				c.rtt.Store(now.UnixNano() - sentAt)
+			}
+			if c.relay != nil {
+				c.relay.heartbeat(c.lastPong.Load(), c.rtt.Load())
+			}
+			return c.Conn.SetReadDeadline(now.Add(pongTimeout))
+		})
+	}
 
+	limiter := newTokenBucket(config.InboundRate, config.InboundBurst)
+	abuse := abuseTracker{max: config.MaxViolations, window: config.ViolationWindow}
+	limited, disconnecting := false, false
 	for {
 		_, message, err := c.Conn.ReadMessage()
 		if err != nil {
@@ -38,35 +88,82 @@ func (c *Client) ReadPump() {
 			}
 			break
 		}
+		if disconnecting {
+			continue // The room is closing the connection.
+		}
 
-		var msg dtos.WebsocketMessage
-		if err := json.Unmarshal(message, &msg); err != nil {
-			log.Printf("Error unmarshalling message: %v", err)
-			continue
+		now := time.Now()
+		var inboundMsg *InboundMessage
+		if limiter.allow(now) {
+			limited = false
+			inboundMsg = decodeInbound(message)
+		} else {
+			inboundMsg = &InboundMessage{err: &inboundError{code: dtos.ErrorCodeRateLimited, message: "Too many messages, slow down"}}
 		}
+		inboundMsg.Client = c
 
-		inboundMsg := &InboundMessage{
-			Client:  c,
-			Type:    msg.Type,
-			Payload: msg.Payload,
+		if inboundMsg.err != nil {
+			if abuse.strike(now) {
+				inboundMsg.err = &inboundError{code: dtos.ErrorCodeAbuse, message: "Too many invalid or excess messages", disconnect: true}
+				disconnecting = true
+			} else if inboundMsg.err.code == dtos.ErrorCodeRateLimited {
+				// Only the first message over the limit gets a reply, so a
+				// flood does not reach the room.
+				if limited {
+					continue
+				}
+				limited = true
+			}
 		}
 
-		c.Room.Inbound <- inboundMsg
+		if c.relay != nil {
+			if !c.relay.inbound(inboundMsg, message) {
+				break // The backplane is down.
+			}
+		} else if !deliver(c.Room, c.Room.Inbound, inboundMsg) {
+			break // The room has shut down.
+		}
 	}
 }
 
+// config returns the configuration of the client's room, or of this node's
+// hub for a relayed client.
+func (c *Client) config() Config {
+	if c.relay != nil {
+		return c.relay.hub.config
+	}
+	return c.Room.config
+}
+
 // WritePump pumps messages from the Send channel to the websocket connection.
 func (c *Client) WritePump() {
+	var ping <-chan time.Time
+	if interval := c.config().PingInterval; interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		ping = ticker.C
+	}
 	defer func() {
 		c.Conn.Close()
 	}()
 	for {
 		select {
+		case <-ping:
+			// The send time rides along so the pong tells us the round trip.
+			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+			sentAt := strconv.FormatInt(time.Now().UnixNano(), 10)
+			if err := c.Conn.WriteMessage(websocket.PingMessage, []byte(sentAt)); err != nil {
+				return
+			}
 		case message, ok := <-c.Send:
 			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
 				// The room closed the channel.
-				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+				closeMessage := []byte{}
+				if c.closeCode != 0 {
+					closeMessage = websocket.FormatCloseMessage(c.closeCode, "")
+				}
+				c.Conn.WriteMessage(websocket.CloseMessage, closeMessage)
 				return
 			}
 
@@ -0,0 +1,181 @@
+package websocket
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the tunables for the hub and the rooms it manages.
+type Config struct {
+	// PINLength is the number of digits in a lobby game PIN.
+	PINLength int
+	// PINTTL is how long a lobby PIN can be used to find its room.
+	PINTTL time.Duration
+	// ReconnectGrace is how long a dropped player keeps their slot before
+	// player_left is broadcast.
+	ReconnectGrace time.Duration
+	// GuestTTL is how long a guest's join token stays valid.
+	GuestTTL time.Duration
+	// NicknameBlocklist holds words guests may not use in their nickname.
+	NicknameBlocklist []string
+	// PingInterval is how often clients are pinged; zero disables heartbeats.
+	PingInterval time.Duration
+	// PongTimeout is how long a client may stay silent before its connection
+	// is considered dead and closed. It must be longer than PingInterval.
+	PongTimeout time.Duration
+	// IdleTimeout is how long a player may go without sending anything before
+	// they count as idle.
+	IdleTimeout time.Duration
+	// UnstableRTT is the ping round trip above which a connection counts as unstable.
+	UnstableRTT time.Duration
+	// RoomIdleTimeout is how long a room may stay empty before it is closed.
+	RoomIdleTimeout time.Duration
+	// FinishedRoomTTL is how long a room stays open after its game finished.
+	FinishedRoomTTL time.Duration
+	// AnswerGrace is how long after a question's deadline answers are still
+	// accepted, to make up for network latency.
+	AnswerGrace time.Duration
+	// RevealDuration is how long the results of a sync question are shown
+	// before the next question, unless the session sets its own.
+	RevealDuration time.Duration
+	// InboundRate is how many messages a second a client may send on average;
+	// zero disables rate limiting.
+	InboundRate int
+	// InboundBurst is how many messages a client may send at once.
+	InboundBurst int
+	// MaxViolations is how many invalid or rate-limited messages a client may
+	// send within ViolationWindow before it is disconnected; zero never disconnects.
+	MaxViolations int
+	// ViolationWindow is the window MaxViolations is counted in.
+	ViolationWindow time.Duration
+	// CheckpointTTL is how long after a shutdown a checkpointed game can
+	// still be restored; older games are aborted instead.
+	CheckpointTTL time.Duration
+	// NodeID identifies this API instance to the others sharing the
+	// backplane. It must be unique among them and should survive restarts,
+	// so the instance takes its rooms back. LoadConfig defaults it to the
+	// host name; a hub without one makes one up.
+	NodeID string
+	// RedisAddr is the host:port of the Redis server instances share rooms
+	// through; empty keeps rooms within this instance.
+	RedisAddr string
+	// RedisPassword authenticates with the Redis server, if it needs it.
+	RedisPassword string
+	// TicketTTL is how long a websocket ticket can be redeemed, see ticket.go.
+	TicketTTL time.Duration
+	// AllowedOrigins are the origins, like https://quiz.example.com, browsers
+	// may open websockets from; "*" allows any. Empty allows only the API's
+	// own host.
+	AllowedOrigins []string
+}
+
+// DefaultConfig returns the configuration used when nothing is set in the environment.
+func DefaultConfig() Config {
+	return Config{
+		PINLength:       6,
+		PINTTL:          2 * time.Hour,
+		ReconnectGrace:  30 * time.Second,
+		GuestTTL:        time.Hour,
+		PingInterval:    25 * time.Second,
+		PongTimeout:     60 * time.Second,
+		IdleTimeout:     90 * time.Second,
+		UnstableRTT:     2 * time.Second,
+		RoomIdleTimeout: 30 * time.Minute,
+		FinishedRoomTTL: 10 * time.Minute,
+		AnswerGrace:     time.Second,
+		RevealDuration:  5 * time.Second,
+		InboundRate:     10,
+		InboundBurst:    20,
+		MaxViolations:   10,
+		ViolationWindow: time.Minute,
+		CheckpointTTL:   time.Hour,
+		TicketTTL:       30 * time.Second,
+	}
+}
+
+// LoadConfig reads the websocket configuration from the environment, falling back
+// to DefaultConfig for anything missing or invalid.
+func LoadConfig() Config {
+	cfg := DefaultConfig()
+	cfg.PINLength = envInt("WS_PIN_LENGTH", cfg.PINLength)
+	cfg.PINTTL = envDuration("WS_PIN_TTL", cfg.PINTTL)
+	cfg.ReconnectGrace = envDuration("WS_RECONNECT_GRACE", cfg.ReconnectGrace)
+	cfg.GuestTTL = envDuration("WS_GUEST_TTL", cfg.GuestTTL)
+	cfg.NicknameBlocklist = envList("WS_NICKNAME_BLOCKLIST")
+	cfg.PingInterval = envDuration("WS_PING_INTERVAL", cfg.PingInterval)
+	cfg.PongTimeout = envDuration("WS_PONG_TIMEOUT", cfg.PongTimeout)
+	cfg.IdleTimeout = envDuration("WS_IDLE_TIMEOUT", cfg.IdleTimeout)
+	cfg.UnstableRTT = envDuration("WS_UNSTABLE_RTT", cfg.UnstableRTT)
+	cfg.RoomIdleTimeout = envDuration("WS_ROOM_IDLE_TIMEOUT", cfg.RoomIdleTimeout)
+	cfg.FinishedRoomTTL = envDuration("WS_FINISHED_ROOM_TTL", cfg.FinishedRoomTTL)
+	cfg.AnswerGrace = envDuration("WS_ANSWER_GRACE", cfg.AnswerGrace)
+	cfg.RevealDuration = envDuration("WS_REVEAL_DURATION", cfg.RevealDuration)
+	cfg.InboundRate = envInt("WS_INBOUND_RATE", cfg.InboundRate)
+	cfg.InboundBurst = envInt("WS_INBOUND_BURST", cfg.InboundBurst)
+	cfg.MaxViolations = envInt("WS_MAX_VIOLATIONS", cfg.MaxViolations)
+	cfg.ViolationWindow = envDuration("WS_VIOLATION_WINDOW", cfg.ViolationWindow)
+	cfg.CheckpointTTL = envDuration("WS_CHECKPOINT_TTL", cfg.CheckpointTTL)
+	cfg.NodeID = envString("WS_NODE_ID", hostname())
+	cfg.RedisAddr = envString("WS_REDIS_ADDR", cfg.RedisAddr)
+	cfg.RedisPassword = envString("WS_REDIS_PASSWORD", cfg.RedisPassword)
+	cfg.TicketTTL = envDuration("WS_TICKET_TTL", cfg.TicketTTL)
+	cfg.AllowedOrigins = envList("WS_ALLOWED_ORIGINS")
+	if cfg.PingInterval > 0 && cfg.PongTimeout <= cfg.PingInterval {
+		log.Printf("WS_PONG_TIMEOUT %s must be longer than WS_PING_INTERVAL %s, using %s", cfg.PongTimeout, cfg.PingInterval, 2*cfg.PingInterval)
+		cfg.PongTimeout = 2 * cfg.PingInterval
+	}
+	return cfg
+}
+
+// envList reads a comma-separated list, skipping empty entries.
+func envList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, strings.ToLower(item))
+		}
+	}
+	return list
+}
+
+// hostname returns the machine's host name, or "" if it has none.
+func hostname() string {
+	name, _ := os.Hostname()
+	return name
+}
+
+func envString(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func envInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using default %d", key, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using default %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
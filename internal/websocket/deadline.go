@@ -0,0 +1,70 @@
+package websocket
+
+import (
+	"exam/internal/dtos"
+	"exam/internal/model"
+	"log"
+	"time"
+)
+
+// acceptsAnswer checks an answer against the question the server is asking the
+// player and its deadline. The server's clock decides: answers for another
+// question, or arriving later than Config.AnswerGrace after the deadline, are
+// rejected with an 'answer_rejected' message.
+func (r *Room) acceptsAnswer(client *Client, payload dtos.SubmitAnswerPayload, question model.Question, deadline time.Time, now time.Time) bool {
+	if payload.QuestionID != question.ID {
+		r.rejectAnswer(client, payload, question.ID, dtos.AnswerRejectedWrongQuestion, "The answer is not for the current question")
+		return false
+	}
+	if !deadline.IsZero() && now.After(deadline.Add(r.config.AnswerGrace)) {
+		log.Printf("User %d answered question %d %s after the deadline in room %s", client.UserID, question.ID, now.Sub(deadline), r.ID)
+		r.rejectAnswer(client, payload, question.ID, dtos.AnswerRejectedDeadlinePassed, "Time is up for this question")
+		return false
+	}
+	return true
+}
+
+func (r *Room) rejectAnswer(client *Client, payload dtos.SubmitAnswerPayload, currentQuestionID uint, reason string, message string) {
+	r.rejectInbound(client)
+	r.sendMessageToClient(client, "answer_rejected", dtos.AnswerRejectedPayload{
+		QuestionID:        payload.QuestionID,
+		CurrentQuestionID: currentQuestionID,
+		Reason:            reason,
+		Message:           message,
+	})
+}
+
+// nextQuestionPayload stamps a question with when it was issued and its deadline.
+func nextQuestionPayload(question model.Question, issuedAt time.Time, deadline time.Time) dtos.NextQuestionPayload {
+	return dtos.NextQuestionPayload{
+		QuizQuestionDTO: dtos.NewQuizQuestionDTO(question),
+		IssuedAt:        issuedAt,
+		Deadline:        deadlinePtr(deadline),
+	}
+}
+
+// deadlinePtr returns nil for a zero deadline, i.e. a question without a timer.
+func deadlinePtr(deadline time.Time) *time.Time {
+	if deadline.IsZero() {
+		return nil
+	}
+	return &deadline
+}
+
+// syncDeadline is the deadline of the current sync question while its clock runs.
+func (r *Room) syncDeadline() time.Time {
+	if r.State != StateInProgress {
+		return time.Time{}
+	}
+	return r.questionDeadline
+}
+
+// playerDeadline is the deadline of a parallel player's current question while
+// their clock runs.
+func (r *Room) playerDeadline(userID uint) time.Time {
+	pt, ok := r.clientTimers[userID]
+	if !ok || pt.paused {
+		return time.Time{}
+	}
+	return pt.deadline
+}
@@ -0,0 +1,178 @@
+package websocket
+
+import (
+	"encoding/json"
+	"exam/internal/model"
+	"exam/internal/service"
+	"log"
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+const (
+	eventLogBuffer = 1024 // Events waiting to be written before the room waits for the database
+	eventLogBatch  = 100  // Most events written at once
+)
+
+// unloggedMessages are sent to single clients but only restate what the log
+// already holds.
+var unloggedMessages = map[string]bool{
+	"monitor_update": true,
+	"state_snapshot": true,
+}
+
+// eventLog records every message of a game session in order, so the game can
+// be audited and replayed. The room numbers and timestamps the events; they
+// are written in batches in the background so the room loop does not wait
+// for every insert.
+type eventLog struct {
+	sessionID uint
+	seq       int
+	events    chan model.QuizSessionEvent
+	written   chan struct{} // Closed once every event has been written
+}
+
+// newEventLog starts a log whose next event comes after seq; seq is 0 for a
+// new session.
+func newEventLog(sessionID uint, seq int, quizService *service.QuizService) *eventLog {
+	l := &eventLog{
+		sessionID: sessionID,
+		seq:       seq,
+		events:    make(chan model.QuizSessionEvent, eventLogBuffer),
+		written:   make(chan struct{}),
+	}
+	go l.write(quizService)
+	return l
+}
+
+// add appends a message to the log. playerID is who sent or got the
+// message, or 0 for broadcasts and the REST API. rejected marks an inbound
+// message the room turned down.
+func (l *eventLog) add(direction string, playerID uint, msgType string, payload []byte, rejected bool) {
+	l.seq++
+	event := model.QuizSessionEvent{
+		QuizSessionID: l.sessionID,
+		Seq:           l.seq,
+		Direction:     direction,
+		Type:          msgType,
+		Payload:       datatypes.JSON(payload),
+		Rejected:      rejected,
+		OccurredAt:    time.Now(),
+	}
+	if guestID, ok := service.GuestIDFromPlayerID(playerID); ok {
+		event.GuestID = &guestID
+	} else if playerID != 0 {
+		event.UserID = &playerID
+	}
+	l.events <- event
+}
+
+// close ends the log. Events already added are still written.
+func (l *eventLog) close() {
+	close(l.events)
+}
+
+func (l *eventLog) write(quizService *service.QuizService) {
+	defer close(l.written)
+	for event := range l.events {
+		batch := []model.QuizSessionEvent{event}
+	fill:
+		for len(batch) < eventLogBatch {
+			select {
+			case event, ok := <-l.events:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, event)
+			default:
+				break fill
+			}
+		}
+		if err := quizService.RecordSessionEvents(batch); err != nil {
+			log.Printf("Error writing %d events of session %d: %v", len(batch), l.sessionID, err)
+		}
+	}
+}
+
+// openEventLog starts logging the current quiz session, if there is one.
+func (r *Room) openEventLog() {
+	r.closeEventLog()
+	if r.quizSessionID != 0 {
+		r.events = newEventLog(r.quizSessionID, 0, r.quizService)
+	}
+}
+
+func (r *Room) closeEventLog() {
+	if r.events != nil {
+		r.events.close()
+		r.events = nil
+	}
+}
+
+// logOutbound logs a message from the room; client is nil for broadcasts.
+// Messages to spectators are not logged, they only see what is broadcast.
+func (r *Room) logOutbound(client *Client, msgType string, payload []byte) {
+	if r.events == nil {
+		return
+	}
+	var playerID uint
+	if client != nil {
+		if client.Spectator || unloggedMessages[msgType] {
+			return
+		}
+		playerID = client.UserID
+	}
+	r.flushInbound()
+	r.events.add(model.EventDirectionOut, playerID, msgType, payload, false)
+}
+
+// handledMessage is the inbound message the room is handling. It is logged
+// right before the first message the room logs in response, or once it has
+// been handled, so that the log keeps the order things happened in and still
+// tells whether the room turned the message down.
+type handledMessage struct {
+	msg      *InboundMessage
+	events   *eventLog // The log when the message came in; a game it starts is not logged in its own log
+	rejected bool
+}
+
+// rejectInbound marks the message being handled as rejected, if client sent
+// it. Every error the room answers a message with goes through here.
+func (r *Room) rejectInbound(client *Client) {
+	if r.handling != nil && r.handling.msg.Client == client {
+		r.handling.rejected = true
+	}
+}
+
+// flushInbound logs the message being handled, if it has not been yet.
+func (r *Room) flushInbound() {
+	if handling := r.handling; handling != nil {
+		r.handling = nil
+		if handling.events == r.events {
+			r.logInbound(handling.msg, handling.rejected)
+		}
+	}
+}
+
+// logInbound logs a message from a client or the REST API; rejected marks one
+// the room turned down. Messages that never reached the room's handlers, see
+// handleInboundError, are not logged.
+func (r *Room) logInbound(msg *InboundMessage, rejected bool) {
+	if r.events == nil {
+		return
+	}
+	var playerID uint
+	if msg.Client != nil {
+		if msg.Client.Spectator {
+			return
+		}
+		playerID = msg.Client.UserID
+	}
+	payload, err := json.Marshal(msg.Payload)
+	if err != nil {
+		log.Printf("Error marshalling %s payload for the event log: %v", msg.Type, err)
+		return
+	}
+	r.events.add(model.EventDirectionIn, playerID, msg.Type, payload, rejected)
+}
@@ -0,0 +1,65 @@
+package websocket
+
+import (
+	"exam/internal/dtos"
+	"exam/internal/i18n"
+	"exam/internal/model"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestEventLogMarksRejectedMessages(t *testing.T) {
+	i18n.Init()
+	repo := newFakeQuizRepository(2)
+	hub, quizService := newTestNode(t, "node", NewMemoryBackplane(), repo)
+
+	lobby, err := quizService.OpenLobby("quiz", 99)
+	if err != nil {
+		t.Fatalf("OpenLobby returned %v", err)
+	}
+	conn := dialRoom(t, hub, lobby.RoomID, dtos.PlayerProfile{UserID: 1, Nickname: "bee"})
+	eventually(t, "the player is in the room", func() bool {
+		return hub.GetRoomClientCount(lobby.RoomID) == 1
+	})
+	if err := quizService.StartQuiz("quiz", lobby.RoomID, 99, dtos.StartQuizRequest{Mode: "sync"}); err != nil {
+		t.Fatalf("StartQuiz returned %v", err)
+	}
+	readMessage(t, conn, "next_question")
+
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"submit_answer","payload":{"question_id":2,"answer":"a"}}`))
+	readMessage(t, conn, "answer_rejected")
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"submit_answer","payload":{"question_id":1,"answer":"a"}}`))
+	readMessage(t, conn, "answer_result")
+
+	// Each answer is logged right before what the room sent in response.
+	want := []struct {
+		direction string
+		msgType   string
+		rejected  bool
+	}{
+		{model.EventDirectionIn, "submit_answer", true},
+		{model.EventDirectionOut, "answer_rejected", false},
+		{model.EventDirectionIn, "submit_answer", false},
+		{model.EventDirectionOut, "answer_result", false},
+	}
+	var answers []model.QuizSessionEvent
+	eventually(t, "the answers are logged", func() bool {
+		answers = answers[:0]
+		for _, event := range repo.sessionEvents(1) {
+			if event.Type == "submit_answer" || event.Type == "answer_rejected" || event.Type == "answer_result" {
+				answers = append(answers, event)
+			}
+		}
+		return len(answers) >= len(want)
+	})
+	for i, event := range answers[:len(want)] {
+		if event.Direction != want[i].direction || event.Type != want[i].msgType || event.Rejected != want[i].rejected {
+			t.Errorf("event %d = %s %s rejected=%v, want %s %s rejected=%v", i,
+				event.Direction, event.Type, event.Rejected, want[i].direction, want[i].msgType, want[i].rejected)
+		}
+		if i > 0 && event.Seq <= answers[i-1].Seq {
+			t.Errorf("event %d has seq %d after %d", i, event.Seq, answers[i-1].Seq)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package websocket
+
+import (
+	"exam/internal/model"
+	"exam/internal/repository"
+	"sync"
+)
+
+// fakeQuizRepository keeps a single quiz, and the sessions played from it,
+// in memory. Rooms and their hubs call it from many goroutines at once.
+type fakeQuizRepository struct {
+	repository.QuizRepository
+
+	mu       sync.Mutex
+	quiz     model.Quiz
+	sessions map[uint]*model.QuizSession
+	answers  []model.QuizAnswer
+	draws    []model.QuizSessionDraw
+	events   []model.QuizSessionEvent
+}
+
+// newFakeQuizRepository returns a repository with a quiz, "quiz", of
+// questions questions with the options a and b, a being correct.
+func newFakeQuizRepository(questions int) *fakeQuizRepository {
+	quiz := model.Quiz{ID: 1, UUID: "quiz", Title: "Quiz", CreatedBy: 99}
+	for i := 1; i <= questions; i++ {
+		quiz.Questions = append(quiz.Questions, model.Question{
+			ID:            uint(i),
+			QuizID:        quiz.ID,
+			Content:       []byte(`[]`),
+			Options:       []byte(`[{"id":"a"},{"id":"b"}]`),
+			CorrectAnswer: "a",
+		})
+	}
+	return &fakeQuizRepository{quiz: quiz, sessions: make(map[uint]*model.QuizSession)}
+}
+
+func (r *fakeQuizRepository) GetQuizByUUID(uuid string) (*model.Quiz, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	quiz := r.quiz
+	quiz.Questions = nil
+	return &quiz, nil
+}
+
+func (r *fakeQuizRepository) GetQuizWithQuestionsByUUID(uuid string) (*model.Quiz, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	quiz := r.quiz
+	quiz.Questions = append([]model.Question(nil), r.quiz.Questions...)
+	return &quiz, nil
+}
+
+func (r *fakeQuizRepository) CreateQuizSession(session *model.QuizSession) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session.ID = uint(len(r.sessions) + 1)
+	stored := *session
+	r.sessions[session.ID] = &stored
+	return nil
+}
+
+func (r *fakeQuizRepository) UpdateQuizSession(session *model.QuizSession) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *session
+	r.sessions[session.ID] = &stored
+	return nil
+}
+
+func (r *fakeQuizRepository) GetQuizSessionByID(sessionID uint) (*model.QuizSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.sessions[sessionID]
+	if !ok {
+		return nil, nil
+	}
+	session := *stored
+	return &session, nil
+}
+
+func (r *fakeQuizRepository) GetOpenRoomSessions() ([]model.QuizSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var sessions []model.QuizSession
+	for id := uint(1); id <= uint(len(r.sessions)); id++ {
+		if session := r.sessions[id]; session.EndedAt == nil && session.Mode != "async" {
+			sessions = append(sessions, *session)
+		}
+	}
+	return sessions, nil
+}
+
+func (r *fakeQuizRepository) CreateQuizAnswer(answer *model.QuizAnswer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.answers = append(r.answers, *answer)
+	return nil
+}
+
+func (r *fakeQuizRepository) GetQuizAnswersBySessionID(sessionID uint) ([]model.QuizAnswer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var answers []model.QuizAnswer
+	for _, answer := range r.answers {
+		if answer.QuizSessionID == sessionID {
+			answers = append(answers, answer)
+		}
+	}
+	return answers, nil
+}
+
+func (r *fakeQuizRepository) GetSessionDraws(sessionID uint) ([]model.QuizSessionDraw, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var draws []model.QuizSessionDraw
+	for _, draw := range r.draws {
+		if draw.QuizSessionID == sessionID {
+			draws = append(draws, draw)
+		}
+	}
+	return draws, nil
+}
+
+func (r *fakeQuizRepository) CreateSessionDraw(draw *model.QuizSessionDraw) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.draws = append(r.draws, *draw)
+	return nil
+}
+
+func (r *fakeQuizRepository) CreateSessionEvents(events []model.QuizSessionEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, events...)
+	return nil
+}
+
+func (r *fakeQuizRepository) GetSessionEvents(sessionID uint, afterSeq int, limit int) ([]model.QuizSessionEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []model.QuizSessionEvent
+	for _, event := range r.events {
+		if event.QuizSessionID == sessionID && event.Seq > afterSeq && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// sessionEvents returns the events logged for a session so far.
+func (r *fakeQuizRepository) sessionEvents(sessionID uint) []model.QuizSessionEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []model.QuizSessionEvent
+	for _, event := range r.events {
+		if event.QuizSessionID == sessionID {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// sessionCount returns how many sessions were recorded so far.
+func (r *fakeQuizRepository) sessionCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.sessions)
+}
@@ -0,0 +1,50 @@
+package websocket
+
+import (
+	"exam/internal/service"
+	"log"
+	"strings"
+)
+
+// guestAdmission asks a room to reserve a nickname for a guest about to join.
+type guestAdmission struct {
+	playerID uint
+	nickname string
+	reply    chan error
+}
+
+func (r *Room) handleGuestAdmission(admission guestAdmission) {
+	admission.reply <- r.admitGuest(admission.playerID, admission.nickname)
+}
+
+// admitGuest reserves a nickname for the room's lifetime, so a guest keeps it
+// across reconnects and nobody else can take it meanwhile.
+func (r *Room) admitGuest(playerID uint, nickname string) error {
+	key := strings.ToLower(nickname)
+	for _, word := range r.config.NicknameBlocklist {
+		if strings.Contains(key, word) {
+			return service.ErrNicknameNotAllowed
+		}
+	}
+	if r.nicknameTaken(key) {
+		return service.ErrNicknameTaken
+	}
+
+	r.guestNicknames[key] = playerID
+	log.Printf("Guest %d admitted to room %s as %q", playerID, r.ID, nickname)
+	return nil
+}
+
+// nicknameTaken reports whether a reserved guest nickname or a player's
+// display name already matches the lowercased nickname.
+func (r *Room) nicknameTaken(key string) bool {
+	if _, ok := r.guestNicknames[key]; ok {
+		return true
+	}
+	for _, profile := range r.profiles {
+		if strings.ToLower(profile.DisplayName()) == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,232 @@
+package websocket
+
+import (
+	"errors"
+	"exam/internal/dtos"
+	"exam/internal/service"
+	"fmt"
+	"log"
+	"time"
+)
+
+// isHostClient reports whether the connection belongs to the room's host.
+func (r *Room) isHostClient(client *Client) bool {
+	return client != nil && client.UserID == r.hostID
+}
+
+// canControl reports whether the sender may drive the game. Messages without a
+// client come from the REST API, which does its own authorization.
+func (r *Room) canControl(client *Client) bool {
+	return client == nil || r.isHostClient(client)
+}
+
+// handleHostStartGame starts the game for the host, who sends the settings of
+// its session over the websocket.
+func (r *Room) handleHostStartGame(client *Client, payload startGamePayload) {
+	if !r.canControl(client) {
+		r.sendError(client, dtos.ErrorCodeForbidden, "Only the host can start the game")
+		return
+	}
+	if payload.Mode != "sync" && payload.Mode != "parallel" {
+		r.sendError(client, dtos.ErrorCodeInvalidPayload, "Mode must be sync or parallel")
+		return
+	}
+	if payload.Scoring != "" {
+		if _, err := service.NewScoringStrategy(payload.Scoring); err != nil {
+			r.sendError(client, dtos.ErrorCodeInvalidPayload, err.Error())
+			return
+		}
+	}
+	if payload.RevealSeconds < 0 || payload.RevealSeconds > maxRevealSeconds {
+		r.sendError(client, dtos.ErrorCodeInvalidPayload, fmt.Sprintf("Reveal duration must be between 0 and %d seconds", maxRevealSeconds))
+		return
+	}
+
+	req := dtos.StartQuizRequest{Mode: payload.Mode, Scoring: payload.Scoring, TimeLimit: payload.TimeLimit, RevealSeconds: payload.RevealSeconds}
+	if err := r.startSession(req); err != nil {
+		if errors.Is(err, service.ErrGameInProgress) {
+			r.sendError(client, dtos.ErrorCodeInvalidState, "The game is already in progress")
+			return
+		}
+		log.Printf("Error creating quiz session for room %s: %v", r.ID, err)
+		r.sendError(client, dtos.ErrorCodeInternal, "Failed to start the game")
+	}
+}
+
+// startSession records a new session of the quiz and starts its game, for
+// the host and for the API alike. While a game is in progress it records
+// nothing and returns service.ErrGameInProgress.
+func (r *Room) startSession(req dtos.StartQuizRequest) error {
+	if r.State == StateInProgress || r.State == StatePaused {
+		return service.ErrGameInProgress
+	}
+
+	session, err := r.quizService.CreateQuizSession(r.QuizID, r.ID, req, r.connectedStudents())
+	if err != nil {
+		return err
+	}
+
+	r.startGame(startGamePayload{
+		SessionID:     session.ID,
+		Mode:          session.Mode,
+		Scoring:       session.ScoringStrategy,
+		TimeLimit:     session.TimeLimit,
+		RevealSeconds: session.RevealSeconds,
+		ShuffleSeed:   session.ShuffleSeed,
+	})
+	return nil
+}
+
+func (r *Room) handleHostCommand(msg *InboundMessage) {
+	if !r.canControl(msg.Client) {
+		r.sendError(msg.Client, dtos.ErrorCodeForbidden, "Only the host can send "+msg.Type)
+		return
+	}
+
+	switch msg.Type {
+	case "pause_game":
+		r.pauseGame(msg.Client)
+	case "resume_game":
+		r.resumeGame(msg.Client)
+	case "skip_question":
+		r.skipQuestion(msg.Client)
+	case "kick_player":
+		r.kickPlayer(msg.Client, msg.Payload.(*dtos.KickPlayerPayload).UserID)
+	case "extend_timer":
+		r.extendTimer(msg.Client, time.Duration(msg.Payload.(*dtos.ExtendTimerPayload).Seconds)*time.Second)
+	case "end_game":
+		if r.State != StateInProgress && r.State != StatePaused {
+			r.sendError(msg.Client, dtos.ErrorCodeInvalidState, "No game is in progress")
+			return
+		}
+		log.Printf("Host ended the game in room %s", r.ID)
+		r.endGame()
+	case "create_teams", "assign_team", "auto_assign_teams", "clear_teams":
+		r.handleTeamCommand(msg)
+	}
+}
+
+func (r *Room) pauseGame(client *Client) {
+	if r.State != StateInProgress {
+		r.sendError(client, dtos.ErrorCodeInvalidState, "No game is in progress")
+		return
+	}
+
+	if r.stopQuestionTimer() {
+		r.pausedQuestion = true
+		r.pausedRemaining = time.Until(r.questionDeadline)
+		if r.pausedRemaining < 0 {
+			r.pausedRemaining = 0
+		}
+	}
+	if r.stopAdvance() {
+		r.pausedAdvance = true
+	}
+	r.pausePlayerTimers()
+	r.pauseSessionTimer()
+
+	r.State = StatePaused
+	r.pausedAt = time.Now()
+	log.Printf("Game paused in room %s", r.ID)
+	r.broadcastMessage("game_paused", r.timerPayload(), nil)
+}
+
+func (r *Room) resumeGame(client *Client) {
+	if r.State != StatePaused {
+		r.sendError(client, dtos.ErrorCodeInvalidState, "The game is not paused")
+		return
+	}
+
+	r.State = StateInProgress
+	// Time spent paused does not count towards answer speed.
+	pausedFor := time.Since(r.pausedAt)
+	r.questionSentAt = r.questionSentAt.Add(pausedFor)
+	for userID, sentAt := range r.clientQuestionSentAt {
+		r.clientQuestionSentAt[userID] = sentAt.Add(pausedFor)
+	}
+	if r.pausedQuestion {
+		r.pausedQuestion = false
+		r.startQuestionTimer(r.pausedRemaining)
+	}
+	if r.pausedAdvance {
+		r.pausedAdvance = false
+		remaining := time.Until(r.advanceAt)
+		if remaining < 0 {
+			remaining = 0
+		}
+		// The pause happened between steps, so give players the rest of the gap.
+		r.scheduleAdvance(remaining, r.advanceFn)
+	}
+	r.resumePlayerTimers()
+	r.resumeSessionTimer()
+
+	log.Printf("Game resumed in room %s", r.ID)
+	r.broadcastMessage("game_resumed", r.timerPayload(), nil)
+}
+
+func (r *Room) skipQuestion(client *Client) {
+	if r.State != StateInProgress {
+		r.sendError(client, dtos.ErrorCodeInvalidState, "No game is in progress")
+		return
+	}
+	if r.Mode != "sync" {
+		r.sendError(client, dtos.ErrorCodeInvalidState, "Questions can only be skipped in sync mode")
+		return
+	}
+
+	r.stopQuestionTimers()
+	if r.currentQuestionIndex >= 0 && r.currentQuestionIndex < len(r.quiz.Questions) {
+		r.broadcastMessage("question_skipped", dtos.TimerPayload{QuestionID: r.quiz.Questions[r.currentQuestionIndex].ID}, nil)
+	}
+	log.Printf("Host skipped question %d in room %s", r.currentQuestionIndex+1, r.ID)
+	r.sendNextQuestion()
+}
+
+func (r *Room) kickPlayer(client *Client, userID uint) {
+	if userID == r.hostID {
+		r.sendError(client, dtos.ErrorCodeForbidden, "The host cannot be kicked")
+		return
+	}
+
+	r.kicked[userID] = true
+	r.cancelGracePeriod(userID)
+	delete(r.playerTeams, userID)
+	if target, ok := r.clientsByUserID[userID]; ok {
+		r.sendMessageToClient(target, "kicked", nil)
+		r.removeClient(target)
+	}
+
+	log.Printf("Host kicked user %d from room %s", userID, r.ID)
+	r.announcePlayerLeft(userID)
+	r.sendMonitor()
+	r.checkAllAnswered()
+}
+
+func (r *Room) extendTimer(client *Client, extra time.Duration) {
+	if r.Mode != "sync" {
+		r.sendError(client, dtos.ErrorCodeInvalidState, "Timers can only be extended in sync mode")
+		return
+	}
+
+	switch {
+	case r.State == StatePaused && r.pausedQuestion:
+		r.pausedRemaining += extra
+	case r.State == StateInProgress && r.stopQuestionTimer():
+		r.startQuestionTimer(time.Until(r.questionDeadline) + extra)
+	default:
+		r.sendError(client, dtos.ErrorCodeInvalidState, "No question timer is running")
+		return
+	}
+
+	log.Printf("Host extended the timer by %s in room %s", extra, r.ID)
+	r.broadcastMessage("timer_extended", r.timerPayload(), nil)
+}
+
+// timerPayload describes the current sync question and the time left on it.
+func (r *Room) timerPayload() dtos.TimerPayload {
+	payload := dtos.TimerPayload{RemainingSeconds: r.syncRemainingSeconds(), Deadline: deadlinePtr(r.syncDeadline())}
+	if r.quiz != nil && r.currentQuestionIndex >= 0 && r.currentQuestionIndex < len(r.quiz.Questions) {
+		payload.QuestionID = r.quiz.Questions[r.currentQuestionIndex].ID
+	}
+	return payload
+}
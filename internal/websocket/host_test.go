@@ -0,0 +1,86 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"exam/internal/dtos"
+	"exam/internal/i18n"
+	"exam/internal/model"
+	"exam/internal/service"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestStartGameInProgress(t *testing.T) {
+	i18n.Init()
+	backplane := NewMemoryBackplane()
+	repo := newFakeQuizRepository(2)
+	owner, ownerQuizzes := newTestNode(t, "owner", backplane, repo)
+	_, relayQuizzes := newTestNode(t, "relay", backplane, repo)
+
+	lobby, err := ownerQuizzes.OpenLobby("quiz", 99)
+	if err != nil {
+		t.Fatalf("OpenLobby returned %v", err)
+	}
+	host := dialRoom(t, owner, lobby.RoomID, dtos.PlayerProfile{UserID: 99, Nickname: "host"})
+	readMessage(t, host, "state_snapshot")
+	if err := ownerQuizzes.StartQuiz("quiz", lobby.RoomID, 99, dtos.StartQuizRequest{Mode: "sync"}); err != nil {
+		t.Fatalf("StartQuiz returned %v", err)
+	}
+
+	// Neither the API, on any node, nor the host records a second session.
+	for name, quizzes := range map[string]*service.QuizService{"owner": ownerQuizzes, "relay": relayQuizzes} {
+		if err := quizzes.StartQuiz("quiz", lobby.RoomID, 99, dtos.StartQuizRequest{Mode: "sync"}); !errors.Is(err, service.ErrGameInProgress) {
+			t.Errorf("StartQuiz on the %s node during a game = %v, want ErrGameInProgress", name, err)
+		}
+	}
+	host.WriteMessage(websocket.TextMessage, []byte(`{"type":"start_game","payload":{"mode":"sync"}}`))
+	var failure dtos.ErrorPayload
+	if err := json.Unmarshal(readMessage(t, host, "error"), &failure); err != nil || failure.Code != dtos.ErrorCodeInvalidState {
+		t.Errorf("start_game during a game = %+v, %v; want %s", failure, err, dtos.ErrorCodeInvalidState)
+	}
+
+	if n := repo.sessionCount(); n != 1 {
+		t.Fatalf("%d sessions recorded, want 1", n)
+	}
+}
+
+func TestHostJoinsPooledParallelGame(t *testing.T) {
+	i18n.Init()
+	repo := newFakeQuizRepository(3)
+	repo.quiz.Pools = []model.QuizPool{{ID: 1, QuizID: repo.quiz.ID, DrawCount: 2}}
+	hub, quizService := newTestNode(t, "node", NewMemoryBackplane(), repo)
+
+	lobby, err := quizService.OpenLobby("quiz", 99)
+	if err != nil {
+		t.Fatalf("OpenLobby returned %v", err)
+	}
+	player := dialRoom(t, hub, lobby.RoomID, dtos.PlayerProfile{UserID: 1, Nickname: "bee"})
+	eventually(t, "the player is in the room", func() bool {
+		return hub.GetRoomClientCount(lobby.RoomID) == 1
+	})
+	if err := quizService.StartQuiz("quiz", lobby.RoomID, 99, dtos.StartQuizRequest{Mode: "parallel"}); err != nil {
+		t.Fatalf("StartQuiz returned %v", err)
+	}
+	readMessage(t, player, "next_question")
+
+	host := dialRoom(t, hub, lobby.RoomID, dtos.PlayerProfile{UserID: 99, Nickname: "host"})
+	var snapshot dtos.StateSnapshotPayload
+	if err := json.Unmarshal(readMessage(t, host, "state_snapshot"), &snapshot); err != nil {
+		t.Fatalf("malformed state_snapshot: %v", err)
+	}
+	if snapshot.QuestionIndex != -1 || snapshot.Question != nil {
+		t.Errorf("the host was shown question %d: %+v", snapshot.QuestionIndex, snapshot.Question)
+	}
+
+	draws, _ := repo.GetSessionDraws(1)
+	for _, draw := range draws {
+		if draw.UserID != nil && *draw.UserID == 99 {
+			t.Fatalf("a draw was recorded for the host: %s", draw.QuestionIDs)
+		}
+	}
+	if len(draws) != 1 {
+		t.Fatalf("%d draws recorded, want the player's only", len(draws))
+	}
+}
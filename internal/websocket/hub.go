@@ -1,88 +1,304 @@
 package websocket
 
 import (
-	"encoding/json"
 	"exam/internal/dtos"
+	"exam/internal/service"
 	"fmt"
 	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
 )
 
-// Hub maintains the set of active rooms and broadcasts messages to the
-// rooms.
+// Hub keeps the registry of live rooms. It is safe for concurrent use: HTTP
+// handlers look rooms up while rooms remove themselves when they shut down.
+// Hubs on several nodes share rooms through a backplane, see relay.go.
 type Hub struct {
-	// Registered rooms.
-	Rooms map[string]*Room
+	mu        sync.RWMutex
+	rooms     map[string]*Room  // Live rooms, keyed by room ID
+	listeners map[string]func() // Room ID -> stops listening on the room's channel
+	closed    bool              // Set by Shutdown; no rooms are opened after it
+
+	config      Config
+	pins        *pinRegistry
+	quizService *service.QuizService
+
+	node      string // This node's ID in the backplane
+	backplane Backplane
+	quit      chan struct{} // Closed by Shutdown to stop keepAlive
+
+	relayMu    sync.Mutex
+	relayed    map[string]*Client           // Clients connected here to rooms on other nodes, by connection ID
+	remotes    map[string]*Client           // Clients connected elsewhere to rooms on this node, by remoteKey
+	requests   map[uint64]chan relayMessage // Queries waiting for their reply, by request
+	requestSeq uint64
+}
+
+// NewHub returns a hub on the backplane the configuration asks for.
+func NewHub(config Config) *Hub {
+	return NewHubWithBackplane(config, NewBackplane(config))
+}
+
+// NewHubWithBackplane returns a hub that shares its rooms with the hubs of
+// other nodes on the given backplane.
+func NewHubWithBackplane(config Config, backplane Backplane) *Hub {
+	h := &Hub{
+		rooms:     make(map[string]*Room),
+		listeners: make(map[string]func()),
+		config:    config,
+		pins:      newPINRegistry(config.PINLength, config.PINTTL),
+		node:      config.NodeID,
+		backplane: backplane,
+		quit:      make(chan struct{}),
+		relayed:   make(map[string]*Client),
+		remotes:   make(map[string]*Client),
+		requests:  make(map[uint64]chan relayMessage),
+	}
+	if h.node == "" {
+		h.node = uuid.New().String()
+	}
+	if _, err := backplane.Subscribe(nodeChannel(h.node), h.handleNodeMessage); err != nil {
+		log.Printf("Error subscribing node %s to the backplane: %v", h.node, err)
+	}
+	go h.keepAlive()
+	return h
+}
+
+// SetQuizService wires the quiz service used by rooms created through OpenRoom.
+// The service itself depends on the hub, so it cannot be passed to NewHub.
+func (h *Hub) SetQuizService(quizService *service.QuizService) {
+	h.quizService = quizService
+}
+
+// GetRoom returns the live room with the given ID.
+func (h *Hub) GetRoom(roomID string) (*Room, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	room, ok := h.rooms[roomID]
+	return room, ok
+}
 
-	// Register requests for rooms.
-	Register chan *Room
+// getOrCreateRoom returns the room registered under roomID, creating and
+// starting it with create if there is none. Lookup and insert happen under one
+// lock, so concurrent callers always end up in the same room. Once the hub has
+// shut down there is no room to return. The caller has claimed the room in
+// the backplane; a new room listens on its channel for other nodes.
+func (h *Hub) getOrCreateRoom(roomID string, create func() *Room) (*Room, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if room, ok := h.rooms[roomID]; ok {
+		return room, false
+	}
+	if h.closed {
+		return nil, false
+	}
+	room := create()
+	room.hub = h
+	h.rooms[roomID] = room
+	if unsubscribe, err := h.backplane.Subscribe(roomChannel(roomID), h.handleRoomMessage); err == nil {
+		h.listeners[roomID] = unsubscribe
+	} else {
+		log.Printf("Error subscribing room %s to the backplane: %v", roomID, err)
+	}
+	go room.Run()
+	log.Printf("Room %s registered for quiz %s", room.ID, room.QuizID)
+	return room, true
+}
+
+// removeRoom unregisters a room that shut down and frees its PIN and its
+// claim in the backplane.
+func (h *Hub) removeRoom(room *Room) {
+	h.mu.Lock()
+	if h.rooms[room.ID] != room {
+		h.mu.Unlock()
+		return
+	}
+	delete(h.rooms, room.ID)
+	pin := h.pins.Release(room.ID)
+	unsubscribe := h.listeners[room.ID]
+	delete(h.listeners, room.ID)
+	h.mu.Unlock()
+
+	if unsubscribe != nil {
+		unsubscribe()
+	}
+	h.releaseRoom(room.ID, pin)
+	log.Printf("Room %s unregistered", room.ID)
+}
+
+// OpenRoom creates a new lobby for the given quiz and returns its room ID and
+// game PIN. Several rooms may be open for the same quiz at once.
+func (h *Hub) OpenRoom(quizUUID string, hostID uint) (*dtos.LobbyResponse, error) {
+	if h.quizService == nil {
+		return nil, fmt.Errorf("hub has no quiz service configured")
+	}
+
+	roomID := uuid.New().String()
+	if err := h.claimRoom(roomID, quizUUID); err != nil {
+		return nil, fmt.Errorf("failed to claim room: %w", err)
+	}
+	pin, expiresAt, err := h.allocatePIN(roomID)
+	if err != nil {
+		h.releaseRoom(roomID, "")
+		return nil, err
+	}
+
+	room, _ := h.getOrCreateRoom(roomID, func() *Room {
+		return NewRoom(roomID, quizUUID, hostID, h.quizService, h.config)
+	})
+	if room == nil {
+		h.releaseRoom(roomID, h.pins.Release(roomID))
+		return nil, errHubClosed
+	}
 
-	// Unregister requests for rooms.
-	Unregister chan *Room
+	return &dtos.LobbyResponse{
+		RoomID:       room.ID,
+		QuizUUID:     quizUUID,
+		PIN:          pin,
+		PINExpiresAt: &expiresAt,
+	}, nil
 }
 
-func NewHub() *Hub {
-	return &Hub{
-		Rooms:      make(map[string]*Room),
-		Register:   make(chan *Room),
-		Unregister: make(chan *Room),
+// allocatePIN reserves a PIN for the room that no room on any node uses.
+func (h *Hub) allocatePIN(roomID string) (string, time.Time, error) {
+	for i := 0; i < maxPINAttempts; i++ {
+		pin, expiresAt, err := h.pins.Allocate(roomID)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		claimed, err := h.backplane.SetIfAbsent(pinKey(pin), roomID, h.config.PINTTL)
+		if err != nil {
+			h.pins.Release(roomID)
+			return "", time.Time{}, fmt.Errorf("failed to claim PIN: %w", err)
+		}
+		if claimed {
+			return pin, expiresAt, nil
+		}
+		h.pins.Release(roomID) // Taken on another node
 	}
+	return "", time.Time{}, fmt.Errorf("no free PIN available")
 }
 
-func (h *Hub) Run() {
-	for {
-		select {
-		case room := <-h.Register:
-			h.Rooms[room.QuizID] = room
-			log.Printf("Room %s registered", room.QuizID)
-		case room := <-h.Unregister:
-			if _, ok := h.Rooms[room.QuizID]; ok {
-				delete(h.Rooms, room.QuizID)
-				log.Printf("Room %s unregistered", room.QuizID)
-			}
+// ResolvePIN returns the ID of the live room behind a game PIN, on any node.
+func (h *Hub) ResolvePIN(pin string) (string, bool) {
+	roomID, ok := h.pins.Resolve(pin)
+	if !ok {
+		var err error
+		if roomID, ok, err = h.backplane.Get(pinKey(pin)); err != nil || !ok {
+			return "", false
 		}
 	}
+	if !h.HasRoom(roomID) {
+		return "", false
+	}
+	return roomID, true
+}
+
+// GetRoomQuizUUID returns the UUID of the quiz played in the given room.
+func (h *Hub) GetRoomQuizUUID(roomID string) (string, bool) {
+	if room, ok := h.GetRoom(roomID); ok {
+		return room.QuizID, true
+	}
+	if owner, ok := h.lookupRoom(roomID); ok {
+		return owner.QuizUUID, true
+	}
+	return "", false
+}
+
+// AdmitGuest reserves a guest's nickname in a room and returns until when the
+// guest may use it to join.
+func (h *Hub) AdmitGuest(roomID string, playerID uint, nickname string) (time.Time, error) {
+	room, ok := h.GetRoom(roomID)
+	if !ok {
+		var expiresAt time.Time
+		err := h.ask(roomID, queryAdmitGuest, admitGuestQuery{PlayerID: playerID, Nickname: nickname}, &expiresAt)
+		return expiresAt, err
+	}
+	reply := make(chan error, 1)
+	if !deliver(room, room.guestAdmissions, guestAdmission{playerID: playerID, nickname: nickname, reply: reply}) {
+		return time.Time{}, service.ErrRoomNotFound
+	}
+	if err := <-reply; err != nil {
+		return time.Time{}, err
+	}
+	return time.Now().Add(h.config.GuestTTL), nil
 }
 
-func (h *Hub) GetRoomClientCount(quizUUID string) int {
-	if room, ok := h.Rooms[quizUUID]; ok {
-		return len(room.Clients)
+func (h *Hub) GetRoomClientCount(roomID string) int {
+	var count int
+	if room, ok := h.GetRoom(roomID); ok {
+		room.query(func() { count = room.playerCount() })
+	} else if err := h.ask(roomID, queryClientCount, nil, &count); err != nil {
+		log.Printf("Error counting clients of room %s: %v", roomID, err)
 	}
-	return 0
+	return count
 }
 
-func (h *Hub) GetRoomClients(quizUUID string) []dtos.ConnectedStudentDTO {
+func (h *Hub) GetRoomClients(roomID string) []dtos.ConnectedStudentDTO {
 	var students []dtos.ConnectedStudentDTO
-	if room, ok := h.Rooms[quizUUID]; ok {
-		for client := range room.Clients {
-			// Assuming client.UserName is available or can be derived
-			// For now, we'll use a placeholder or derive from UserID
-			userName := fmt.Sprintf("Player %d", client.UserID)
-			students = append(students, dtos.ConnectedStudentDTO{
-				UserID:   client.UserID,
-				UserName: userName,
-			})
-		}
+	if room, ok := h.GetRoom(roomID); ok {
+		room.query(func() { students = room.connectedStudents() })
+	} else if err := h.ask(roomID, queryClients, nil, &students); err != nil {
+		log.Printf("Error listing clients of room %s: %v", roomID, err)
 	}
 	return students
 }
 
-func (h *Hub) StartQuizInRoom(quizUUID string, sessionID uint, mode string) error {
-	if room, ok := h.Rooms[quizUUID]; ok {
-		// Marshal the session ID and mode into a JSON payload
-		payload, err := json.Marshal(struct {
-			SessionID uint   `json:"session_id"`
-			Mode      string `json:"mode"`
-		}{
-			SessionID: sessionID,
-			Mode:      mode,
-		})
-		if err != nil {
-			return fmt.Errorf("failed to marshal start_game payload: %w", err)
+// GetRoomStats returns a snapshot of a room taken inside its event loop.
+func (h *Hub) GetRoomStats(roomID string) (*dtos.RoomStatsDTO, bool) {
+	var stats *dtos.RoomStatsDTO
+	room, ok := h.GetRoom(roomID)
+	if !ok {
+		if err := h.ask(roomID, queryStats, nil, &stats); err != nil || stats == nil {
+			return nil, false
+		}
+		return stats, true
+	}
+	if !room.query(func() { stats = room.stats() }) {
+		return nil, false
+	}
+	return stats, true
+}
+
+// GetRoomMonitor returns the host's view of every student in a room.
+func (h *Hub) GetRoomMonitor(roomID string) (*dtos.MonitorPayload, bool) {
+	var monitor *dtos.MonitorPayload
+	room, ok := h.GetRoom(roomID)
+	if !ok {
+		if err := h.ask(roomID, queryMonitor, nil, &monitor); err != nil || monitor == nil {
+			return nil, false
+		}
+		return monitor, true
+	}
+	if !room.query(func() { monitor = room.monitor() }) {
+		return nil, false
+	}
+	return monitor, true
+}
+
+// StartQuizInRoom starts a game in the room with the given settings. The room
+// records its session, and refuses with service.ErrGameInProgress while a
+// game is being played.
+func (h *Hub) StartQuizInRoom(roomID string, req dtos.StartQuizRequest) error {
+	if _, ok := h.GetRoom(roomID); !ok {
+		if err := h.ask(roomID, queryStart, req, nil); err != nil {
+			return fmt.Errorf("quiz room %s: %w", roomID, err)
 		}
-		// Send a message to the room's inbound channel to start the game
-		// This simulates the "start_game" websocket message but from the API
-		room.Inbound <- &InboundMessage{Type: "start_game", Payload: payload}
 		return nil
 	}
-	return fmt.Errorf("quiz room %s not found", quizUUID)
-}
\ No newline at end of file
+	return h.startGame(roomID, req)
+}
+
+// startGame starts the game of a room on this node.
+func (h *Hub) startGame(roomID string, req dtos.StartQuizRequest) error {
+	room, ok := h.GetRoom(roomID)
+	if !ok {
+		return fmt.Errorf("quiz room %s not found", roomID)
+	}
+	var err error
+	if !room.query(func() { err = room.startSession(req) }) {
+		return fmt.Errorf("quiz room %s has shut down", roomID)
+	}
+	return err
+}
@@ -0,0 +1,150 @@
+package websocket
+
+import (
+	"encoding/json"
+	"exam/internal/dtos"
+	"exam/internal/utils"
+	"fmt"
+	"log"
+	"time"
+)
+
+// inboundType describes a message type clients may send.
+type inboundType struct {
+	payload func() any // Returns what the payload decodes into; nil if the message has none
+}
+
+func payloadOf[T any]() func() any {
+	return func() any { return new(T) }
+}
+
+// inboundTypes is every message type clients may send. Messages are decoded
+// and validated against it before they reach the room, so room handlers get
+// their payload ready to use, e.g. msg.Payload.(*dtos.SubmitAnswerPayload).
+var inboundTypes = map[string]inboundType{
+	"start_game":        {payload: payloadOf[startGamePayload]()},
+	"pause_game":        {},
+	"resume_game":       {},
+	"skip_question":     {},
+	"kick_player":       {payload: payloadOf[dtos.KickPlayerPayload]()},
+	"extend_timer":      {payload: payloadOf[dtos.ExtendTimerPayload]()},
+	"end_game":          {},
+	"create_teams":      {payload: payloadOf[dtos.CreateTeamsPayload]()},
+	"assign_team":       {payload: payloadOf[dtos.AssignTeamPayload]()},
+	"auto_assign_teams": {},
+	"clear_teams":       {},
+	"submit_answer":     {payload: payloadOf[dtos.SubmitAnswerPayload]()},
+	"resume":            {},
+	"presence":          {payload: payloadOf[presencePayload]()},
+}
+
+// inboundError is why a client's message was rejected before reaching the
+// room. The room only replies to it.
+type inboundError struct {
+	code       string
+	message    string
+	fields     map[string]string
+	disconnect bool // The client is disconnected after the reply
+}
+
+// decodeInbound parses a raw message from a client and validates it against
+// inboundTypes. A rejected message comes back with err set.
+func decodeInbound(raw []byte) *InboundMessage {
+	var envelope dtos.WebsocketMessage
+	if err := json.Unmarshal(raw, &envelope); err != nil || envelope.Type == "" {
+		return &InboundMessage{err: &inboundError{code: dtos.ErrorCodeInvalidMessage, message: "Messages must be JSON objects with a type and a payload"}}
+	}
+	msg := &InboundMessage{Type: envelope.Type}
+
+	spec, ok := inboundTypes[envelope.Type]
+	if !ok {
+		msg.err = &inboundError{code: dtos.ErrorCodeUnknownType, message: fmt.Sprintf("Unknown message type %q", envelope.Type)}
+		return msg
+	}
+	if spec.payload == nil {
+		return msg
+	}
+
+	payload := spec.payload()
+	raw = envelope.Payload
+	if len(raw) == 0 || string(raw) == "null" {
+		raw = []byte("{}") // So missing required fields are reported as such
+	}
+	if err := json.Unmarshal(raw, payload); err != nil {
+		msg.err = &inboundError{code: dtos.ErrorCodeInvalidPayload, message: "Invalid " + envelope.Type + " payload"}
+		return msg
+	}
+	if fields, ok := utils.ValidateStruct(payload, ""); !ok {
+		msg.err = &inboundError{code: dtos.ErrorCodeInvalidPayload, message: "Invalid " + envelope.Type + " payload", fields: fields}
+		return msg
+	}
+	msg.Payload = payload
+	return msg
+}
+
+// handleInboundError replies to a rejected message, and drops the client if
+// it has been rejected too often.
+func (r *Room) handleInboundError(msg *InboundMessage) {
+	r.sendMessageToClient(msg.Client, "error", dtos.ErrorPayload{
+		Code:    msg.err.code,
+		Message: msg.err.message,
+		Type:    msg.Type,
+		Fields:  msg.err.fields,
+	})
+	if msg.err.disconnect {
+		log.Printf("Disconnecting client %d from room %s after repeated invalid or excess messages", msg.Client.UserID, r.ID)
+		r.handleClientUnregister(msg.Client)
+	}
+}
+
+// tokenBucket limits how fast a client may send: it holds up to burst
+// tokens, refills at rate tokens a second, and every message takes one.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket returns a full bucket. A rate of zero or less lets everything through.
+func newTokenBucket(rate, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: float64(rate), burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+func (b *tokenBucket) allow(now time.Time) bool {
+	if b.rate <= 0 {
+		return true
+	}
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// abuseTracker counts a client's rejected messages and reports abuse once
+// there are max of them within window. A max of zero never reports abuse.
+type abuseTracker struct {
+	max     int
+	window  time.Duration
+	strikes []time.Time
+}
+
+func (a *abuseTracker) strike(now time.Time) bool {
+	if a.max <= 0 {
+		return false
+	}
+	recent := a.strikes[:0]
+	for _, at := range a.strikes {
+		if now.Sub(at) < a.window {
+			recent = append(recent, at)
+		}
+	}
+	a.strikes = append(recent, now)
+	return len(a.strikes) >= a.max
+}
@@ -0,0 +1,111 @@
+package websocket
+
+import (
+	"exam/internal/dtos"
+	"exam/internal/i18n"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	type step struct {
+		at      time.Duration // Since the bucket was made
+		allowed bool
+	}
+	tests := []struct {
+		name        string
+		rate, burst int
+		steps       []step
+	}{
+		{"burst, then the rate", 10, 3, []step{
+			{0, true}, {0, true}, {0, true}, {0, false},
+			{100 * time.Millisecond, true}, {100 * time.Millisecond, false},
+			// A second later the bucket is full again, but no fuller.
+			{time.Second, true}, {time.Second, true}, {time.Second, true}, {time.Second, false},
+		}},
+		{"burst below one", 1, 0, []step{
+			{0, true}, {0, false}, {500 * time.Millisecond, false}, {time.Second, true},
+		}},
+		{"no limit", 0, 1, []step{
+			{0, true}, {0, true}, {0, true}, {0, true},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTokenBucket(tt.rate, tt.burst)
+			start := b.last
+			for i, step := range tt.steps {
+				if allowed := b.allow(start.Add(step.at)); allowed != step.allowed {
+					t.Fatalf("step %d at %v: allow = %v, want %v", i, step.at, allowed, step.allowed)
+				}
+			}
+		})
+	}
+}
+
+func TestAbuseTracker(t *testing.T) {
+	tests := []struct {
+		name    string
+		max     int
+		strikes []time.Duration
+		abuse   []bool
+	}{
+		{"strikes within the window", 3, []time.Duration{0, 10 * time.Second, 20 * time.Second}, []bool{false, false, true}},
+		{"strikes spread out", 3, []time.Duration{0, 40 * time.Second, 80 * time.Second, 120 * time.Second}, []bool{false, false, false, false}},
+		{"old strikes expire", 2, []time.Duration{0, 90 * time.Second, 100 * time.Second}, []bool{false, false, true}},
+		{"disabled", 0, []time.Duration{0, 0, 0}, []bool{false, false, false}},
+	}
+	start := time.Now()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &abuseTracker{max: tt.max, window: time.Minute}
+			for i, at := range tt.strikes {
+				if abuse := a.strike(start.Add(at)); abuse != tt.abuse[i] {
+					t.Fatalf("strike %d at %v: abuse = %v, want %v", i, at, abuse, tt.abuse[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeInbound(t *testing.T) {
+	i18n.Init()
+	tests := []struct {
+		name    string
+		raw     string
+		msgType string
+		code    string // Empty if the message is accepted
+	}{
+		{"not JSON", `hello`, "", dtos.ErrorCodeInvalidMessage},
+		{"no type", `{"payload":{}}`, "", dtos.ErrorCodeInvalidMessage},
+		{"unknown type", `{"type":"cheat"}`, "cheat", dtos.ErrorCodeUnknownType},
+		{"no payload needed", `{"type":"pause_game"}`, "pause_game", ""},
+		{"valid payload", `{"type":"submit_answer","payload":{"question_id":1,"answer":"a"}}`, "submit_answer", ""},
+		{"missing payload", `{"type":"submit_answer"}`, "submit_answer", dtos.ErrorCodeInvalidPayload},
+		{"mistyped payload", `{"type":"submit_answer","payload":{"question_id":"one","answer":"a"}}`, "submit_answer", dtos.ErrorCodeInvalidPayload},
+		{"out of range", `{"type":"extend_timer","payload":{"seconds":601}}`, "extend_timer", dtos.ErrorCodeInvalidPayload},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := decodeInbound([]byte(tt.raw))
+			if msg.Type != tt.msgType {
+				t.Errorf("type = %q, want %q", msg.Type, tt.msgType)
+			}
+			code := ""
+			if msg.err != nil {
+				code = msg.err.code
+			}
+			if code != tt.code {
+				t.Fatalf("error code = %q, want %q", code, tt.code)
+			}
+			if code == "" && inboundTypes[msg.Type].payload != nil && msg.Payload == nil {
+				t.Fatal("accepted message has no payload")
+			}
+		})
+	}
+
+	fields := decodeInbound([]byte(`{"type":"submit_answer","payload":{"answer":"a"}}`)).err.fields
+	if _, ok := fields["questionid"]; !ok {
+		t.Errorf("fields = %v, want the missing question_id reported", fields)
+	}
+}
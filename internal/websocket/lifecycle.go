@@ -0,0 +1,139 @@
+package websocket
+
+import (
+	"exam/internal/dtos"
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// deliver sends v to one of the room's channels, giving up if the room has
+// shut down. Everything outside the room loop talks to the room through it,
+// so nobody blocks forever on a room that is gone.
+func deliver[T any](r *Room, ch chan T, v T) bool {
+	select {
+	case ch <- v:
+		return true
+	case <-r.done:
+		return false
+	}
+}
+
+// query runs fn inside the room loop and waits for it, so fn may use room
+// state safely. It reports false if the room has shut down.
+func (r *Room) query(fn func()) bool {
+	finished := make(chan struct{})
+	if !deliver(r, r.queries, func() {
+		fn()
+		close(finished)
+	}) {
+		return false
+	}
+	<-finished
+	return true
+}
+
+// Join hands a new connection to the room. It reports false if the room has
+// shut down in the meantime.
+func (r *Room) Join(client *Client) bool {
+	return deliver(r, r.Register, client)
+}
+
+// Stop shuts the room down: a running game is ended and its scores saved,
+// everyone is disconnected and the room leaves the hub. It does not wait for
+// the shutdown to finish.
+func (r *Room) Stop() {
+	r.stopOnce.Do(func() { close(r.stop) })
+}
+
+// Suspend shuts the room down for a server restart. Unlike with Stop, a
+// running game is not ended but paused and checkpointed, see Hub.Shutdown.
+func (r *Room) Suspend() {
+	r.suspended.Store(true)
+	r.Stop()
+}
+
+// Done is closed once the room has shut down.
+func (r *Room) Done() <-chan struct{} {
+	return r.done
+}
+
+// checkLifecycle stops rooms nobody uses any more: rooms that have been empty
+// for RoomIdleTimeout, and rooms whose game finished FinishedRoomTTL ago.
+func (r *Room) checkLifecycle() {
+	now := time.Now()
+	if len(r.Clients) > 0 || len(r.spectators) > 0 {
+		r.emptySince = time.Time{}
+	} else if r.emptySince.IsZero() {
+		r.emptySince = now
+	}
+
+	switch {
+	case r.config.RoomIdleTimeout > 0 && !r.emptySince.IsZero() && now.Sub(r.emptySince) > r.config.RoomIdleTimeout:
+		log.Printf("Room %s has been empty for %s, closing it", r.ID, r.config.RoomIdleTimeout)
+		r.Stop()
+	case r.config.FinishedRoomTTL > 0 && r.State == StateFinished && now.Sub(r.finishedAt) > r.config.FinishedRoomTTL:
+		log.Printf("Game in room %s finished %s ago, closing the room", r.ID, r.config.FinishedRoomTTL)
+		r.Stop()
+	}
+}
+
+// shutdown runs in the room loop as its last step.
+func (r *Room) shutdown() {
+	restarting := r.suspended.Load()
+	resumable := restarting && r.checkpoint()
+	if !resumable && (r.State == StateInProgress || r.State == StatePaused) {
+		r.endGame()
+	}
+	r.stopTimers()
+
+	for client := range r.Clients {
+		r.sayGoodbye(client, restarting, resumable)
+	}
+	for spectator := range r.spectators {
+		r.sayGoodbye(spectator, restarting, resumable)
+	}
+	r.closeEventLog()
+
+	if r.hub != nil {
+		r.hub.removeRoom(r)
+	}
+	log.Printf("Room %s shut down", r.ID)
+}
+
+// sayGoodbye tells a client the room is closing and disconnects it.
+func (r *Room) sayGoodbye(client *Client, restarting bool, resumable bool) {
+	if restarting {
+		client.closeCode = websocket.CloseServiceRestart
+		r.sendMessageToClient(client, "server_restart", dtos.ServerRestartPayload{Resumable: resumable})
+	} else {
+		r.sendMessageToClient(client, "room_closed", nil)
+	}
+	r.removeClient(client)
+}
+
+// stats is a snapshot of the room for the REST API.
+func (r *Room) stats() *dtos.RoomStatsDTO {
+	_, hostConnected := r.clientsByUserID[r.hostID]
+	stats := &dtos.RoomStatsDTO{
+		RoomID:         r.ID,
+		QuizUUID:       r.QuizID,
+		State:          r.State,
+		Mode:           r.Mode,
+		PlayerCount:    r.playerCount(),
+		SpectatorCount: len(r.spectators),
+		HostConnected:  hostConnected,
+		CreatedAt:      r.createdAt,
+		Outbound:       r.outbound.dto(),
+	}
+	if r.quiz != nil {
+		stats.TotalQuestions = r.questionCount()
+		stats.QuestionIndex = r.currentQuestionIndex
+	}
+	if r.State == StateFinished {
+		finishedAt := r.finishedAt
+		stats.FinishedAt = &finishedAt
+	}
+	return stats
+}
@@ -0,0 +1,115 @@
+package websocket
+
+import (
+	"exam/internal/dtos"
+	"sort"
+	"time"
+)
+
+// Student statuses on the monitor besides the presence states.
+const (
+	StatusFinished = "finished"
+	StatusLeft     = "left" // Gone for longer than the reconnect grace period
+)
+
+// playerProgress is what the monitor shows about a player's answers.
+type playerProgress struct {
+	correct   int
+	incorrect int
+	timeSpent time.Duration
+}
+
+// trackProgress counts an answer, or a question run out of time on, for the monitor.
+func (r *Room) trackProgress(userID uint, isCorrect bool, responseTime time.Duration) {
+	progress, ok := r.progress[userID]
+	if !ok {
+		progress = &playerProgress{}
+		r.progress[userID] = progress
+	}
+	if isCorrect {
+		progress.correct++
+	} else {
+		progress.incorrect++
+	}
+	progress.timeSpent += responseTime
+}
+
+// monitor builds the host's view of every student in the game.
+func (r *Room) monitor() *dtos.MonitorPayload {
+	now := time.Now()
+	payload := &dtos.MonitorPayload{
+		RoomID:    r.ID,
+		State:     r.State,
+		Mode:      r.Mode,
+		Students:  []dtos.StudentProgressDTO{},
+		UpdatedAt: now,
+	}
+	if r.quiz != nil {
+		payload.TotalQuestions = r.questionCount()
+	}
+
+	for userID, score := range r.scores {
+		if r.kicked[userID] {
+			continue
+		}
+		student := dtos.StudentProgressDTO{
+			UserID:        userID,
+			UserName:      score.UserName,
+			TeamID:        r.playerTeams[userID],
+			IsGuest:       score.IsGuest,
+			QuestionIndex: r.currentQuestionIndex,
+			Score:         score.Score,
+			Finished:      r.State == StateFinished,
+			Status:        r.studentStatus(userID),
+		}
+		if progress, ok := r.progress[userID]; ok {
+			student.Correct = progress.correct
+			student.Incorrect = progress.incorrect
+			student.TimeSpentMs = progress.timeSpent.Milliseconds()
+		}
+
+		if r.Mode == "parallel" {
+			student.QuestionIndex = r.clientProgress[userID]
+			student.Finished = r.finishedClients[userID]
+			// Count the time on the current question while the clock runs.
+			if sentAt, ok := r.clientQuestionSentAt[userID]; ok && !student.Finished && r.State == StateInProgress {
+				student.TimeSpentMs += now.Sub(sentAt).Milliseconds()
+			}
+		}
+		if student.Finished {
+			student.Status = StatusFinished
+		}
+		payload.Students = append(payload.Students, student)
+	}
+
+	sort.Slice(payload.Students, func(i, j int) bool {
+		a, b := payload.Students[i], payload.Students[j]
+		if a.UserName != b.UserName {
+			return a.UserName < b.UserName
+		}
+		return a.UserID < b.UserID
+	})
+	return payload
+}
+
+// studentStatus is a student's presence, or whether they dropped or left.
+func (r *Room) studentStatus(userID uint) string {
+	if client, ok := r.clientsByUserID[userID]; ok {
+		return client.presence
+	}
+	if _, ok := r.disconnected[userID]; ok {
+		return PresenceDisconnected
+	}
+	return StatusLeft
+}
+
+// sendMonitor streams the monitor to the host while a game runs. Only the host
+// gets it; students and spectators never see each other's progress.
+func (r *Room) sendMonitor() {
+	if r.State == StateWaiting {
+		return
+	}
+	if host, ok := r.clientsByUserID[r.hostID]; ok {
+		r.sendMessageToClient(host, "monitor_update", r.monitor())
+	}
+}
@@ -0,0 +1,127 @@
+package websocket
+
+import (
+	"exam/internal/dtos"
+	"log"
+
+	"github.com/gorilla/websocket"
+)
+
+// The room never waits for a client: messages go into the client's Send
+// buffer, which WritePump drains. A client that reads slower than the room
+// writes fills it up, and once it is filled past its high water mark messages
+// are shed by class, so that what the game cannot go on without still gets
+// through:
+//
+//   - state messages carry the full current state of something, so only the
+//     latest of each type is held back, and sent once the buffer drains;
+//   - informational messages are dropped;
+//   - critical messages, everything else, may fill the buffer to the brim. A
+//     client that cannot take one any more is evicted as if its connection had
+//     dropped, and catches up with a state snapshot when it reconnects.
+type messageClass int
+
+const (
+	classCritical messageClass = iota
+	classState
+	classInfo
+)
+
+// messageClasses holds the message types that are not critical.
+var messageClasses = map[string]messageClass{
+	"score_update":    classState,
+	"monitor_update":  classState,
+	"teams_updated":   classState,
+	"player_joined":   classInfo,
+	"player_left":     classInfo,
+	"presence_update": classInfo,
+}
+
+// highWater is how full a client's Send buffer may get before only critical
+// messages are let in.
+func highWater(client *Client) int {
+	return cap(client.Send) * 3 / 4
+}
+
+// outboundStats counts what slow clients cost a room.
+type outboundStats struct {
+	dropped   map[string]int // Message type -> messages never sent
+	coalesced int            // State messages replaced by a later one before they were sent
+	evicted   int            // Clients disconnected because they could not keep up
+}
+
+func (s outboundStats) dto() dtos.OutboundStatsDTO {
+	dto := dtos.OutboundStatsDTO{Coalesced: s.coalesced, Evicted: s.evicted, DroppedByType: map[string]int{}}
+	for msgType, n := range s.dropped {
+		dto.Dropped += n
+		dto.DroppedByType[msgType] = n
+	}
+	return dto
+}
+
+// send queues an encoded message for a client according to its class.
+func (r *Room) send(client *Client, msgType string, msg []byte) {
+	if client.slow {
+		return // Being evicted
+	}
+	r.flushPending(client)
+
+	class := messageClasses[msgType]
+	if class != classCritical && len(client.Send) >= highWater(client) {
+		if class == classState {
+			if client.pending == nil {
+				client.pending = make(map[string][]byte)
+			}
+			if _, ok := client.pending[msgType]; ok {
+				r.outbound.coalesced++
+			}
+			client.pending[msgType] = msg
+			r.backlogged[client] = true
+		} else {
+			r.outbound.dropped[msgType]++
+		}
+		return
+	}
+
+	select {
+	case client.Send <- msg:
+	default:
+		r.outbound.dropped[msgType]++
+		client.slow = true
+		r.slowClients = append(r.slowClients, client)
+	}
+}
+
+// flushPending sends a client the state messages held back for it, as far as
+// its buffer has drained.
+func (r *Room) flushPending(client *Client) {
+	for msgType, msg := range client.pending {
+		if len(client.Send) >= highWater(client) {
+			return
+		}
+		client.Send <- msg
+		delete(client.pending, msgType)
+	}
+	delete(r.backlogged, client)
+}
+
+// drainOutbound runs after every event of the room loop: held back state
+// messages go out to clients that caught up, and clients that fell too far
+// behind are evicted. Evicting is left until here so that no broadcast is cut
+// short by the reactions to a client leaving.
+func (r *Room) drainOutbound() {
+	for client := range r.backlogged {
+		r.flushPending(client)
+	}
+	for len(r.slowClients) > 0 {
+		client := r.slowClients[0]
+		r.slowClients = r.slowClients[1:]
+		if !r.Clients[client] && !r.spectators[client] {
+			continue // Gone already
+		}
+		r.outbound.evicted++
+		log.Printf("Client %d in room %s cannot keep up, disconnecting it", client.UserID, r.ID)
+		client.closeCode = websocket.CloseTryAgainLater
+		r.handleClientUnregister(client)
+	}
+}
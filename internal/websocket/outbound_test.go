@@ -0,0 +1,92 @@
+package websocket
+
+import (
+	"exam/internal/dtos"
+	"exam/internal/i18n"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestSendShedsByClass(t *testing.T) {
+	r := NewRoom("room", "quiz", 99, nil, DefaultConfig())
+	client := &Client{Send: make(chan []byte, 4), UserID: 1} // High water at 3
+
+	for _, msg := range []string{"q1", "q2", "q3"} {
+		r.send(client, "next_question", []byte(msg))
+	}
+	// Past the high water mark, information is dropped and state coalesced...
+	r.send(client, "player_joined", []byte("joined"))
+	r.send(client, "score_update", []byte("s1"))
+	r.send(client, "score_update", []byte("s2"))
+	// ...while critical messages still get in.
+	r.send(client, "answer_result", []byte("a"))
+	if r.outbound.dropped["player_joined"] != 1 || r.outbound.coalesced != 1 || len(client.Send) != 4 {
+		t.Fatalf("dropped %v, coalesced %d, %d queued; want player_joined dropped, 1 coalesced, 4 queued",
+			r.outbound.dropped, r.outbound.coalesced, len(client.Send))
+	}
+
+	// Once the client reads, the latest state held back follows.
+	<-client.Send
+	<-client.Send
+	r.drainOutbound()
+	var queued []string
+	for len(client.Send) > 0 {
+		queued = append(queued, string(<-client.Send))
+	}
+	if want := []string{"q3", "a", "s2"}; len(queued) != len(want) || queued[0] != want[0] || queued[1] != want[1] || queued[2] != want[2] {
+		t.Fatalf("queued %v, want %v", queued, want)
+	}
+	if r.backlogged[client] {
+		t.Fatal("the client is still backlogged after catching up")
+	}
+
+	// A critical message that does not fit marks the client for eviction.
+	for i := 0; i < 5; i++ {
+		r.send(client, "next_question", []byte("q"))
+	}
+	if !client.slow || len(r.slowClients) != 1 || r.outbound.dropped["next_question"] != 1 {
+		t.Fatalf("slow %v, %d to evict, dropped %v; want the client marked once", client.slow, len(r.slowClients), r.outbound.dropped)
+	}
+}
+
+func TestSlowClientEvictedOnce(t *testing.T) {
+	i18n.Init()
+	hub, quizService := newTestNode(t, "node", NewMemoryBackplane(), newFakeQuizRepository(1))
+	lobby, err := quizService.OpenLobby("quiz", 99)
+	if err != nil {
+		t.Fatalf("OpenLobby returned %v", err)
+	}
+	room, _ := hub.GetRoom(lobby.RoomID)
+
+	// Nobody reads this client's buffer, as if its connection had stalled.
+	client := &Client{Send: make(chan []byte, 4), UserID: 1, Profile: dtos.PlayerProfile{UserID: 1, Nickname: "bee"}}
+	if !hub.Join(lobby.RoomID, client) {
+		t.Fatal("the client could not join")
+	}
+	// Every error reply is critical, so they fill the buffer to the brim.
+	for i := 0; i < 2*cap(client.Send); i++ {
+		msg := decodeInbound([]byte(`not json`))
+		msg.Client = client
+		deliver(room, room.Inbound, msg)
+	}
+
+	var evicted int
+	var closeCode int
+	room.query(func() {
+		evicted = room.outbound.evicted
+		closeCode = client.closeCode
+	})
+	if evicted != 1 || closeCode != websocket.CloseTryAgainLater {
+		t.Fatalf("evicted %d clients with close code %d; want the client evicted once with %d", evicted, closeCode, websocket.CloseTryAgainLater)
+	}
+	for range client.Send {
+		// Drained until the room closed it.
+	}
+
+	// The connection dropping afterwards unregisters the client again.
+	deliver(room, room.Unregister, client)
+	if !room.query(func() { evicted = room.outbound.evicted }) || evicted != 1 {
+		t.Fatalf("after the client unregistered, the room is gone or evicted %d clients", evicted)
+	}
+}
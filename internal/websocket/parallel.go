@@ -0,0 +1,187 @@
+package websocket
+
+import (
+	"exam/internal/dtos"
+	"log"
+	"time"
+)
+
+// playerTimer is the server-side countdown for a parallel player's question.
+type playerTimer struct {
+	timer         *time.Timer
+	token         int
+	questionIndex int
+	deadline      time.Time
+	paused        bool
+	remaining     time.Duration // Time left when the game was paused
+}
+
+// playerTimeout is delivered to the room loop when a player's question timer
+// runs out, Config.AnswerGrace after the deadline so late answers still count.
+// The token guards against timers that were stopped or replaced.
+type playerTimeout struct {
+	userID        uint
+	questionIndex int
+	token         int
+}
+
+func (r *Room) startPlayerTimer(userID uint, questionIndex int, d time.Duration) {
+	r.stopPlayerTimer(userID)
+
+	r.clientTimerSeq++
+	token := r.clientTimerSeq
+	r.clientTimers[userID] = &playerTimer{
+		token:         token,
+		questionIndex: questionIndex,
+		deadline:      time.Now().Add(d),
+		timer: time.AfterFunc(d+r.config.AnswerGrace, func() {
+			deliver(r, r.clientTimeouts, playerTimeout{userID: userID, questionIndex: questionIndex, token: token})
+		}),
+	}
+}
+
+func (r *Room) stopPlayerTimer(userID uint) {
+	if pt, ok := r.clientTimers[userID]; ok {
+		if pt.timer != nil { // Nil for a timer restored from a checkpoint, which was never started
+			pt.timer.Stop()
+		}
+		delete(r.clientTimers, userID)
+	}
+}
+
+func (r *Room) stopPlayerTimers() {
+	for userID := range r.clientTimers {
+		r.stopPlayerTimer(userID)
+	}
+}
+
+func (r *Room) pausePlayerTimers() {
+	for _, pt := range r.clientTimers {
+		pt.timer.Stop()
+		pt.paused = true
+		pt.remaining = time.Until(pt.deadline)
+		if pt.remaining < 0 {
+			pt.remaining = 0
+		}
+	}
+}
+
+func (r *Room) resumePlayerTimers() {
+	for userID, pt := range r.clientTimers {
+		if pt.paused {
+			r.startPlayerTimer(userID, pt.questionIndex, pt.remaining)
+		}
+	}
+}
+
+// playerRemainingSeconds returns what is left on a parallel player's timer.
+func (r *Room) playerRemainingSeconds(userID uint) int {
+	pt, ok := r.clientTimers[userID]
+	if !ok {
+		return 0
+	}
+	if pt.paused {
+		return durationSeconds(pt.remaining)
+	}
+	return durationSeconds(time.Until(pt.deadline))
+}
+
+// handlePlayerTimeout records a "no answer" for a player who ran out of time
+// and moves them on to their next question.
+func (r *Room) handlePlayerTimeout(timeout playerTimeout) {
+	pt, ok := r.clientTimers[timeout.userID]
+	if !ok || pt.token != timeout.token || r.State != StateInProgress {
+		return
+	}
+	delete(r.clientTimers, timeout.userID)
+
+	userID := timeout.userID
+	if r.finishedClients[userID] || r.clientProgress[userID] != timeout.questionIndex {
+		return
+	}
+
+	question := r.playerQuestion(userID, timeout.questionIndex)
+	log.Printf("User %d ran out of time on question %d in room %s", userID, timeout.questionIndex+1, r.ID)
+
+	r.streaks[userID] = 0
+	elapsed := time.Since(r.clientQuestionSentAt[userID])
+	r.trackProgress(userID, false, elapsed)
+	r.recordAnswer(userID, question.ID, "", false, 0, elapsed)
+
+	if client, ok := r.clientsByUserID[userID]; ok {
+		r.sendMessageToClient(client, "time_up", dtos.TimerPayload{QuestionID: question.ID})
+	}
+
+	r.clientProgress[userID]++
+	r.sendQuestionToPlayer(userID, r.clientProgress[userID])
+	r.sendMonitor()
+}
+
+// allPlayersFinished reports whether every parallel player who is still
+// around has answered all questions.
+func (r *Room) allPlayersFinished() bool {
+	for userID := range r.clientProgress {
+		if r.finishedClients[userID] {
+			continue
+		}
+		_, connected := r.clientsByUserID[userID]
+		_, reconnecting := r.disconnected[userID]
+		if connected || reconnecting {
+			return false
+		}
+	}
+	return true
+}
+
+// --- Session deadline ---
+
+func (r *Room) startSessionTimer(d time.Duration) {
+	r.stopSessionTimer()
+
+	r.sessionTimerSeq++
+	token := r.sessionTimerSeq
+	r.sessionDeadline = time.Now().Add(d)
+	r.sessionTimer = time.AfterFunc(d, func() {
+		deliver(r, r.sessionExpired, token)
+	})
+}
+
+func (r *Room) stopSessionTimer() {
+	if r.sessionTimer != nil {
+		r.sessionTimer.Stop()
+		r.sessionTimer = nil
+	}
+	r.sessionPaused = false
+}
+
+func (r *Room) pauseSessionTimer() {
+	if r.sessionTimer == nil {
+		return
+	}
+	r.sessionTimer.Stop()
+	r.sessionTimer = nil
+	r.sessionPaused = true
+	r.sessionRemaining = time.Until(r.sessionDeadline)
+	if r.sessionRemaining < 0 {
+		r.sessionRemaining = 0
+	}
+}
+
+func (r *Room) resumeSessionTimer() {
+	if r.sessionPaused {
+		r.startSessionTimer(r.sessionRemaining)
+	}
+}
+
+// handleSessionExpired force-ends the game when the session's time limit is up.
+// Answers given so far are kept and the partial scores are saved.
+func (r *Room) handleSessionExpired(token int) {
+	if token != r.sessionTimerSeq || r.sessionTimer == nil || r.State != StateInProgress {
+		return
+	}
+	r.sessionTimer = nil
+
+	log.Printf("Session time limit reached in room %s", r.ID)
+	r.broadcastMessage("session_expired", nil, nil)
+	r.endGame()
+}
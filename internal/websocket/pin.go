@@ -0,0 +1,89 @@
+package websocket
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// maxPINAttempts bounds how often we retry when a generated PIN is taken.
+const maxPINAttempts = 100
+
+type pinEntry struct {
+	roomID    string
+	expiresAt time.Time
+}
+
+// pinRegistry hands out short numeric PINs for live rooms. A PIN stays reserved
+// until its room is unregistered, even after it has expired, so two live rooms
+// can never share one.
+type pinRegistry struct {
+	mu       sync.Mutex
+	length   int
+	ttl      time.Duration
+	pins     map[string]pinEntry
+	roomPins map[string]string // room ID -> PIN
+}
+
+func newPINRegistry(length int, ttl time.Duration) *pinRegistry {
+	return &pinRegistry{
+		length:   length,
+		ttl:      ttl,
+		pins:     make(map[string]pinEntry),
+		roomPins: make(map[string]string),
+	}
+}
+
+// Allocate reserves a fresh PIN for the room.
+func (p *pinRegistry) Allocate(roomID string) (string, time.Time, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	min := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(p.length-1)), nil)
+	span := new(big.Int).Sub(new(big.Int).Mul(min, big.NewInt(10)), min)
+
+	for i := 0; i < maxPINAttempts; i++ {
+		n, err := rand.Int(rand.Reader, span)
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("failed to generate PIN: %w", err)
+		}
+		pin := new(big.Int).Add(n, min).String()
+		if _, taken := p.pins[pin]; taken {
+			continue
+		}
+
+		expiresAt := time.Now().Add(p.ttl)
+		p.pins[pin] = pinEntry{roomID: roomID, expiresAt: expiresAt}
+		p.roomPins[roomID] = pin
+		return pin, expiresAt, nil
+	}
+	return "", time.Time{}, fmt.Errorf("no free PIN available")
+}
+
+// Resolve returns the room ID for a PIN that has not expired yet.
+func (p *pinRegistry) Resolve(pin string) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry, ok := p.pins[pin]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return "", false
+	}
+	return entry.roomID, true
+}
+
+// Release frees the PIN of a room so it can be recycled, and returns it; ""
+// if the room had none.
+func (p *pinRegistry) Release(roomID string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pin, ok := p.roomPins[roomID]
+	if ok {
+		delete(p.pins, pin)
+		delete(p.roomPins, roomID)
+	}
+	return pin
+}
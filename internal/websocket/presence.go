@@ -0,0 +1,109 @@
+package websocket
+
+import (
+	"exam/internal/dtos"
+	"log"
+	"time"
+)
+
+// Presence states of a player's connection.
+const (
+	PresenceConnected    = "connected"
+	PresenceIdle         = "idle"     // Connected, but the player has not done anything for a while
+	PresenceUnstable     = "unstable" // Pongs are late or slow
+	PresenceDisconnected = "disconnected"
+)
+
+// presenceCheckInterval is how often the room re-evaluates presence.
+const presenceCheckInterval = 5 * time.Second
+
+// presencePayload is the payload of a client's 'presence' message, sent when
+// the player leaves or comes back to the page.
+type presencePayload struct {
+	State string `json:"state" validate:"required,oneof=idle active"`
+}
+
+// trackActivity marks a client as active after it sent a message.
+func (r *Room) trackActivity(client *Client) {
+	client.lastActivity = time.Now()
+	client.reportedIdle = false
+}
+
+func (r *Room) handlePresenceMessage(client *Client, payload presencePayload) {
+	client.reportedIdle = payload.State == PresenceIdle
+	r.updatePresence(client)
+}
+
+// presenceOf works out a client's presence from its activity and heartbeats.
+func (r *Room) presenceOf(client *Client) string {
+	now := time.Now()
+
+	// Everyone gets a fresh idle clock when a game starts.
+	activeAt := client.lastActivity
+	if r.activitySince.After(activeAt) {
+		activeAt = r.activitySince
+	}
+	if client.reportedIdle || (r.config.IdleTimeout > 0 && now.Sub(activeAt) > r.config.IdleTimeout) {
+		return PresenceIdle
+	}
+
+	if interval := r.config.PingInterval; interval > 0 {
+		lastPong := time.Unix(0, client.lastPong.Load())
+		if now.Sub(lastPong) > interval+interval/2 {
+			return PresenceUnstable
+		}
+		if r.config.UnstableRTT > 0 && time.Duration(client.rtt.Load()) > r.config.UnstableRTT {
+			return PresenceUnstable
+		}
+	}
+	return PresenceConnected
+}
+
+// updatePresence re-evaluates a client and tells everyone if its presence changed.
+func (r *Room) updatePresence(client *Client) {
+	if r.isHostClient(client) {
+		return
+	}
+	presence := r.presenceOf(client)
+	if presence == client.presence {
+		return
+	}
+	client.presence = presence
+	r.broadcastPresence(client.UserID, presence)
+	r.sendMonitor()
+
+	// Players going idle may have been the last ones the question waited for.
+	r.checkAllAnswered()
+}
+
+// checkPresence runs on a timer to catch players drifting idle or unstable.
+func (r *Room) checkPresence() {
+	for client := range r.Clients {
+		r.updatePresence(client)
+	}
+}
+
+func (r *Room) broadcastPresence(userID uint, presence string) {
+	r.broadcastMessage("presence_update", dtos.PresencePayload{UserID: userID, Presence: presence}, nil)
+}
+
+// checkAllAnswered moves a sync game on once every connected player who is not
+// idle has answered. Dropped players are not in Clients, so they are not waited for.
+func (r *Room) checkAllAnswered() {
+	if r.State != StateInProgress || r.Mode != "sync" || !r.questionOpen || len(r.answeredPlayers) == 0 {
+		return
+	}
+	for client := range r.Clients {
+		if r.isHostClient(client) || client.presence == PresenceIdle {
+			continue
+		}
+		if !r.answeredPlayers[client.UserID] {
+			return
+		}
+	}
+
+	log.Println("All active players have answered. Revealing the results.")
+	r.questionOpen = false
+	r.stopQuestionTimer()
+	r.revealResults()
+}
@@ -0,0 +1,142 @@
+package websocket
+
+import (
+	"exam/internal/dtos"
+	"log"
+	"time"
+)
+
+// graceExpiry is delivered to the room loop when a dropped player's grace
+// period runs out. The token guards against a timer that fired for an
+// earlier disconnect of the same user.
+type graceExpiry struct {
+	userID uint
+	token  int
+}
+
+// startGracePeriod keeps a dropped player's slot open for Config.ReconnectGrace.
+func (r *Room) startGracePeriod(userID uint) {
+	if r.config.ReconnectGrace <= 0 {
+		r.announcePlayerLeft(userID)
+		return
+	}
+
+	r.disconnectSeq++
+	token := r.disconnectSeq
+	r.disconnected[userID] = token
+
+	time.AfterFunc(r.config.ReconnectGrace, func() {
+		deliver(r, r.graceExpired, graceExpiry{userID: userID, token: token})
+	})
+}
+
+// cancelGracePeriod reports whether the user was waiting to reconnect.
+func (r *Room) cancelGracePeriod(userID uint) bool {
+	if _, ok := r.disconnected[userID]; !ok {
+		return false
+	}
+	delete(r.disconnected, userID)
+	return true
+}
+
+func (r *Room) handleGraceExpired(expiry graceExpiry) {
+	token, ok := r.disconnected[expiry.userID]
+	if !ok || token != expiry.token {
+		return // The player came back, or dropped again since.
+	}
+	delete(r.disconnected, expiry.userID)
+
+	log.Printf("Reconnect grace period for user %d in room %s expired", expiry.userID, r.ID)
+	r.announcePlayerLeft(expiry.userID)
+	r.sendMonitor()
+
+	// The player who left may have been the last one still playing.
+	if r.State == StateInProgress && r.Mode == "parallel" && r.allPlayersFinished() {
+		r.endGame()
+	}
+}
+
+func (r *Room) announcePlayerLeft(userID uint) {
+	playerInfo := &dtos.PlayerInfoPayload{UserID: userID}
+	if score, ok := r.scores[userID]; ok {
+		playerInfo.UserName = score.UserName
+		playerInfo.AvatarURL = score.AvatarURL
+	}
+	r.broadcastMessage("player_left", playerInfo, nil)
+}
+
+// sendStateSnapshot tells a (re)connecting client everything it needs to
+// rebuild its screen: room state, the question it is on and its own score.
+func (r *Room) sendStateSnapshot(client *Client) {
+	snapshot := dtos.StateSnapshotPayload{
+		RoomID:        r.ID,
+		State:         r.State,
+		Mode:          r.Mode,
+		QuestionIndex: -1,
+		Scores:        r.scoreList(),
+		Teams:         r.teamStandings(),
+	}
+	if score, ok := r.scores[client.UserID]; ok && !client.Spectator {
+		snapshot.Score = score.Score
+	}
+
+	if r.quiz != nil {
+		snapshot.TotalQuestions = r.questionCount()
+	}
+
+	if (r.State == StateInProgress || r.State == StatePaused) && r.quiz != nil {
+		questionIndex := r.currentQuestionIndex
+		if r.Mode == "parallel" && (client.Spectator || r.isHostClient(client)) {
+			questionIndex = -1 // Every player is on their own question, and the host plays none
+		} else if r.Mode == "parallel" {
+			questionIndex = r.clientProgress[client.UserID]
+			snapshot.Finished = r.finishedClients[client.UserID]
+		} else {
+			snapshot.HasAnswered = !client.Spectator && r.answeredPlayers[client.UserID]
+			snapshot.Results = r.results
+		}
+
+		if questionIndex >= 0 && questionIndex < snapshot.TotalQuestions && !snapshot.Finished {
+			question := r.quiz.Questions[questionIndex]
+			if r.Mode == "parallel" {
+				question = r.playerQuestion(client.UserID, questionIndex)
+			} else if !client.Spectator && !r.isHostClient(client) {
+				question = r.shuffle(client.UserID).Options(question)
+			}
+			questionDTO := dtos.NewQuizQuestionDTO(question)
+			snapshot.Question = &questionDTO
+			snapshot.QuestionIndex = questionIndex
+			if r.Mode == "parallel" {
+				snapshot.RemainingSeconds = r.playerRemainingSeconds(client.UserID)
+				snapshot.Deadline = deadlinePtr(r.playerDeadline(client.UserID))
+			} else {
+				snapshot.RemainingSeconds = r.syncRemainingSeconds()
+				snapshot.Deadline = deadlinePtr(r.syncDeadline())
+			}
+		}
+	}
+
+	r.sendMessageToClient(client, "state_snapshot", snapshot)
+}
+
+// durationSeconds rounds a remaining duration to whole seconds, never below zero.
+func durationSeconds(d time.Duration) int {
+	if d < 0 {
+		return 0
+	}
+	return int(d.Round(time.Second) / time.Second)
+}
+
+// syncRemainingSeconds returns what is left on the shared question timer.
+func (r *Room) syncRemainingSeconds() int {
+	if r.State == StatePaused {
+		if !r.pausedQuestion {
+			return 0
+		}
+		return durationSeconds(r.pausedRemaining)
+	}
+	if r.questionDeadline.IsZero() {
+		return 0
+	}
+	return durationSeconds(time.Until(r.questionDeadline))
+}
@@ -0,0 +1,155 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisDialTimeout    = 5 * time.Second
+	redisCommandTimeout = 5 * time.Second
+)
+
+var errBackplaneClosed = errors.New("backplane is closed")
+
+// redisBackplane is a Backplane on Redis: the registry is plain string keys
+// with an expiry, and channels are pub/sub channels. Commands go through the
+// client's connection pool; subscriptions share one connection of their own,
+// which go-redis dials again, and subscribes again, whenever it drops.
+type redisBackplane struct {
+	client *redis.Client
+	pubsub *redis.PubSub
+
+	mu            sync.Mutex
+	subscriptions map[string]*subscription
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// NewRedisBackplane returns a Backplane on the Redis server at addr. It
+// connects when first used.
+func NewRedisBackplane(addr string, password string) Backplane {
+	client := redis.NewClient(&redis.Options{
+		Addr:         addr,
+		Password:     password,
+		DialTimeout:  redisDialTimeout,
+		ReadTimeout:  redisCommandTimeout,
+		WriteTimeout: redisCommandTimeout,
+	})
+	b := &redisBackplane{
+		client:        client,
+		pubsub:        client.Subscribe(context.Background()),
+		subscriptions: make(map[string]*subscription),
+		closed:        make(chan struct{}),
+	}
+	go b.listen(b.pubsub.Channel(redis.WithChannelSize(subscriptionBacklog)))
+	return b
+}
+
+func (b *redisBackplane) SetIfAbsent(key string, value string, ttl time.Duration) (bool, error) {
+	return b.client.SetNX(context.Background(), key, value, ttl).Result()
+}
+
+func (b *redisBackplane) Set(key string, value string, ttl time.Duration) error {
+	return b.client.Set(context.Background(), key, value, ttl).Err()
+}
+
+func (b *redisBackplane) Get(key string) (string, bool, error) {
+	return redisValue(b.client.Get(context.Background(), key))
+}
+
+// Take needs GETDEL, which came with Redis 6.2.
+func (b *redisBackplane) Take(key string) (string, bool, error) {
+	return redisValue(b.client.GetDel(context.Background(), key))
+}
+
+// redisValue unpacks the reply of a command that returns a key's value or nil.
+func redisValue(cmd *redis.StringCmd) (string, bool, error) {
+	value, err := cmd.Result()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+func (b *redisBackplane) Delete(key string) error {
+	return b.client.Del(context.Background(), key).Err()
+}
+
+func (b *redisBackplane) Publish(channel string, message []byte) error {
+	return b.client.Publish(context.Background(), channel, message).Err()
+}
+
+func (b *redisBackplane) Subscribe(channel string, handler func(message []byte)) (func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	select {
+	case <-b.closed:
+		return nil, errBackplaneClosed
+	default:
+	}
+	if _, ok := b.subscriptions[channel]; ok {
+		return nil, fmt.Errorf("already subscribed to %s", channel)
+	}
+
+	s := newSubscription(handler)
+	b.subscriptions[channel] = s
+	if err := b.pubsub.Subscribe(context.Background(), channel); err != nil {
+		// The channel is remembered all the same, and subscribed to again
+		// once the connection is back.
+		log.Printf("Error subscribing to %s on the Redis backplane: %v", channel, err)
+	}
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.subscriptions[channel] != s {
+			return
+		}
+		delete(b.subscriptions, channel)
+		s.stop()
+		if err := b.pubsub.Unsubscribe(context.Background(), channel); err != nil {
+			log.Printf("Error unsubscribing from %s on the Redis backplane: %v", channel, err)
+		}
+	}, nil
+}
+
+// listen hands the messages of the subscriber connection to the
+// subscriptions of their channels, until the backplane is closed.
+func (b *redisBackplane) listen(messages <-chan *redis.Message) {
+	for msg := range messages {
+		b.mu.Lock()
+		s := b.subscriptions[msg.Channel]
+		b.mu.Unlock()
+		if s != nil {
+			s.push([]byte(msg.Payload))
+		}
+	}
+}
+
+func (b *redisBackplane) Close() error {
+	var err error
+	b.closeOnce.Do(func() {
+		close(b.closed)
+
+		b.mu.Lock()
+		for channel, s := range b.subscriptions {
+			s.stop()
+			delete(b.subscriptions, channel)
+		}
+		b.mu.Unlock()
+
+		err = errors.Join(b.pubsub.Close(), b.client.Close())
+	})
+	return err
+}
@@ -0,0 +1,150 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestRedisBackplane(t *testing.T) (*miniredis.Miniredis, Backplane) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	b := NewRedisBackplane(server.Addr(), "")
+	t.Cleanup(func() { b.Close() })
+	return server, b
+}
+
+func TestRedisBackplaneRegistry(t *testing.T) {
+	server, b := newTestRedisBackplane(t)
+
+	stored, err := b.SetIfAbsent("quiz:room:a", "node-1", 30*time.Second)
+	if err != nil || !stored {
+		t.Fatalf("SetIfAbsent on a new key = %v, %v; want true", stored, err)
+	}
+	stored, err = b.SetIfAbsent("quiz:room:a", "node-2", 30*time.Second)
+	if err != nil || stored {
+		t.Fatalf("SetIfAbsent on a taken key = %v, %v; want false", stored, err)
+	}
+	if value, ok, err := b.Get("quiz:room:a"); err != nil || !ok || value != "node-1" {
+		t.Fatalf("Get = %q, %v, %v; want the first owner", value, ok, err)
+	}
+
+	server.FastForward(31 * time.Second)
+	if _, ok, err := b.Get("quiz:room:a"); err != nil || ok {
+		t.Fatalf("Get after the TTL = %v, %v; want no value", ok, err)
+	}
+	if stored, err := b.SetIfAbsent("quiz:room:a", "node-2", 30*time.Second); err != nil || !stored {
+		t.Fatalf("SetIfAbsent after the TTL = %v, %v; want true", stored, err)
+	}
+
+	if err := b.Delete("quiz:room:a"); err != nil {
+		t.Fatalf("Delete returned %v", err)
+	}
+	if server.Exists("quiz:room:a") {
+		t.Fatal("Delete left the key behind")
+	}
+}
+
+func TestRedisBackplaneTake(t *testing.T) {
+	_, b := newTestRedisBackplane(t)
+
+	if err := b.Set("quiz:ticket:t", `{"id":1}`, time.Minute); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	if value, ok, err := b.Take("quiz:ticket:t"); err != nil || !ok || value != `{"id":1}` {
+		t.Fatalf("Take = %q, %v, %v; want the value", value, ok, err)
+	}
+	if _, ok, err := b.Take("quiz:ticket:t"); err != nil || ok {
+		t.Fatalf("second Take = %v, %v; want no value", ok, err)
+	}
+}
+
+// receive waits for the next message a subscription handler got.
+func receive(t *testing.T, messages <-chan string) string {
+	t.Helper()
+	select {
+	case message := <-messages:
+		return message
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+		return ""
+	}
+}
+
+func TestRedisBackplanePubSub(t *testing.T) {
+	_, b := newTestRedisBackplane(t)
+
+	messages := make(chan string, 10)
+	unsubscribe, err := b.Subscribe("quiz:room:a", func(message []byte) { messages <- string(message) })
+	if err != nil {
+		t.Fatalf("Subscribe returned %v", err)
+	}
+	if _, err := b.Subscribe("quiz:room:a", func([]byte) {}); err == nil {
+		t.Fatal("subscribing twice to a channel succeeded")
+	}
+
+	for _, want := range []string{"first", "second"} {
+		if err := b.Publish("quiz:room:a", []byte(want)); err != nil {
+			t.Fatalf("Publish returned %v", err)
+		}
+		if got := receive(t, messages); got != want {
+			t.Fatalf("received %q, want %q", got, want)
+		}
+	}
+
+	unsubscribe()
+	b.Publish("quiz:room:a", []byte("after"))
+	select {
+	case message := <-messages:
+		t.Fatalf("received %q after unsubscribing", message)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestRedisBackplaneResubscribes(t *testing.T) {
+	server, b := newTestRedisBackplane(t)
+
+	messages := make(chan string, 100)
+	if _, err := b.Subscribe("quiz:node:n", func(message []byte) { messages <- string(message) }); err != nil {
+		t.Fatalf("Subscribe returned %v", err)
+	}
+	b.Publish("quiz:node:n", []byte("before"))
+	if got := receive(t, messages); got != "before" {
+		t.Fatalf("received %q, want %q", got, "before")
+	}
+
+	// Restarting drops every connection, and the subscriptions with them.
+	server.Restart()
+
+	// Messages published before the subscription is back are lost, so keep
+	// publishing until one comes through.
+	deadline := time.After(10 * time.Second)
+	for {
+		b.Publish("quiz:node:n", []byte("after"))
+		select {
+		case message := <-messages:
+			if message != "after" {
+				t.Fatalf("received %q, want %q", message, "after")
+			}
+			return
+		case <-time.After(100 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("the subscription did not come back after the connection dropped")
+		}
+	}
+}
+
+func TestRedisBackplaneClose(t *testing.T) {
+	_, b := newTestRedisBackplane(t)
+
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close returned %v", err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("second Close returned %v", err)
+	}
+	if _, err := b.Subscribe("quiz:room:a", func([]byte) {}); err == nil {
+		t.Fatal("Subscribe succeeded after Close")
+	}
+}
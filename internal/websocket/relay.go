@@ -0,0 +1,545 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"exam/internal/dtos"
+	"exam/internal/service"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// A room lives on the node that opened it, its owner, which records that in
+// the backplane and listens on the room's channel. A client whose connection
+// lands on another node is relayed: that node keeps the websocket and passes
+// what the client sends on to the owner over the room's channel, where the
+// client plays in the room like any other. What the room sends the client
+// goes back over the node's channel. QuizRoomManager calls for a room on
+// another node travel the same way, as queries.
+
+const (
+	roomOwnerTTL   = 30 * time.Second // How long a room stays claimed after its node stops renewing it
+	roomOwnerRenew = 10 * time.Second
+	relayTimeout   = 5 * time.Second // How long a query waits for the owner's reply
+)
+
+var errRoomOwned = errors.New("room is owned by another node")
+
+// relayErrors are the errors queries pass back as themselves rather than as text.
+var relayErrors = []error{service.ErrRoomNotFound, service.ErrGameInProgress, service.ErrNicknameTaken, service.ErrNicknameNotAllowed}
+
+func roomKey(roomID string) string     { return "quiz:room:" + roomID }
+func pinKey(pin string) string         { return "quiz:pin:" + pin }
+func roomChannel(roomID string) string { return "quiz:room:" + roomID }
+func nodeChannel(node string) string   { return "quiz:node:" + node }
+
+// roomRecord is what the backplane holds under roomKey.
+type roomRecord struct {
+	Node     string `json:"node"`
+	QuizUUID string `json:"quiz_uuid"`
+}
+
+// Kinds of relayMessage.
+const (
+	relayJoin      = "join"      // A client connected to the room
+	relayInbound   = "inbound"   // The client sent a message
+	relayHeartbeat = "heartbeat" // The client answered a ping
+	relayLeave     = "leave"     // The client disconnected
+	relaySend      = "send"      // The room sent the client a message
+	relayClose     = "close"     // The room closed the client's connection
+	relayQuery     = "query"     // A QuizRoomManager call for the room
+	relayReply     = "reply"     // The result of a query
+)
+
+// Queries, the Op of a relayQuery.
+const (
+	queryClientCount = "client_count"
+	queryClients     = "clients"
+	queryStats       = "stats"
+	queryMonitor     = "monitor"
+	queryStart       = "start"
+	queryAdmitGuest  = "admit_guest"
+)
+
+// relayMessage is what nodes send each other: join, inbound, heartbeat, leave
+// and query go to the owner over the room's channel, send, close and reply
+// come back over the channel of the node they are for.
+type relayMessage struct {
+	Kind string `json:"kind"`
+	Node string `json:"node"`           // The node that sent it
+	Room string `json:"room,omitempty"` // The room it is about
+	Conn string `json:"conn,omitempty"` // The relayed client's connection, unique on its node
+
+	Profile   *dtos.PlayerProfile `json:"profile,omitempty"` // join
+	Spectator bool                `json:"spectator,omitempty"`
+	Data      []byte              `json:"data,omitempty"`  // inbound, send; query arguments and reply results
+	Error     *relayError         `json:"error,omitempty"` // inbound rejected by the client's node
+	Pong      int64               `json:"pong,omitempty"`  // heartbeat, see Client.lastPong
+	RTT       int64               `json:"rtt,omitempty"`
+	CloseCode int                 `json:"close_code,omitempty"` // close
+
+	Op      string `json:"op,omitempty"` // query
+	Request uint64 `json:"request,omitempty"`
+	Failure string `json:"failure,omitempty"` // reply
+}
+
+// relayError is an inboundError on the wire.
+type relayError struct {
+	Type       string            `json:"type,omitempty"`
+	Code       string            `json:"code"`
+	Message    string            `json:"message"`
+	Fields     map[string]string `json:"fields,omitempty"`
+	Disconnect bool              `json:"disconnect,omitempty"`
+}
+
+// admitGuestQuery holds the arguments of a queryAdmitGuest.
+type admitGuestQuery struct {
+	PlayerID uint   `json:"player_id"`
+	Nickname string `json:"nickname"`
+}
+
+// relayLink ties a client connected to this node to its room on another.
+type relayLink struct {
+	hub  *Hub
+	room string
+	conn string
+}
+
+func (l *relayLink) publish(msg relayMessage) bool {
+	msg.Node, msg.Room, msg.Conn = l.hub.node, l.room, l.conn
+	return l.hub.publish(roomChannel(l.room), msg)
+}
+
+// inbound passes a message from the client on to the room. A message its
+// node rejected is passed on as the error, for the room to reply to.
+func (l *relayLink) inbound(msg *InboundMessage, raw []byte) bool {
+	if msg.err != nil {
+		return l.publish(relayMessage{Kind: relayInbound, Error: &relayError{
+			Type:       msg.Type,
+			Code:       msg.err.code,
+			Message:    msg.err.message,
+			Fields:     msg.err.fields,
+			Disconnect: msg.err.disconnect,
+		}})
+	}
+	return l.publish(relayMessage{Kind: relayInbound, Data: raw})
+}
+
+func (l *relayLink) heartbeat(pong int64, rtt int64) {
+	l.publish(relayMessage{Kind: relayHeartbeat, Pong: pong, RTT: rtt})
+}
+
+// leave tells the room the client disconnected and closes its Send, unless
+// the room closed the connection first.
+func (l *relayLink) leave() {
+	if client := l.hub.takeRelayed(l.conn); client != nil {
+		close(client.Send)
+		l.publish(relayMessage{Kind: relayLeave})
+	}
+}
+
+func (h *Hub) publish(channel string, msg relayMessage) bool {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Error encoding %s relay message: %v", msg.Kind, err)
+		return false
+	}
+	if err := h.backplane.Publish(channel, data); err != nil {
+		log.Printf("Error publishing %s relay message for room %s: %v", msg.Kind, msg.Room, err)
+		return false
+	}
+	return true
+}
+
+// claimRoom records this node as the owner of a room. A node that comes back
+// under the same ID takes its rooms back.
+func (h *Hub) claimRoom(roomID string, quizUUID string) error {
+	record, _ := json.Marshal(roomRecord{Node: h.node, QuizUUID: quizUUID})
+	claimed, err := h.backplane.SetIfAbsent(roomKey(roomID), string(record), roomOwnerTTL)
+	if err != nil {
+		return err
+	}
+	if claimed {
+		return nil
+	}
+	if owner, ok := h.lookupRoom(roomID); ok && owner.Node != h.node {
+		return errRoomOwned
+	}
+	return h.backplane.Set(roomKey(roomID), string(record), roomOwnerTTL)
+}
+
+// releaseRoom drops this node's claim on a room, and the room's PIN if it had one.
+func (h *Hub) releaseRoom(roomID string, pin string) {
+	if owner, ok := h.lookupRoom(roomID); ok && owner.Node == h.node {
+		if err := h.backplane.Delete(roomKey(roomID)); err != nil {
+			log.Printf("Error releasing room %s: %v", roomID, err)
+		}
+	}
+	if pin == "" {
+		return
+	}
+	if owner, ok, _ := h.backplane.Get(pinKey(pin)); ok && owner == roomID {
+		if err := h.backplane.Delete(pinKey(pin)); err != nil {
+			log.Printf("Error releasing PIN of room %s: %v", roomID, err)
+		}
+	}
+}
+
+// lookupRoom returns who owns a room, on any node.
+func (h *Hub) lookupRoom(roomID string) (roomRecord, bool) {
+	var record roomRecord
+	value, ok, err := h.backplane.Get(roomKey(roomID))
+	if err != nil {
+		log.Printf("Error looking up room %s: %v", roomID, err)
+		return record, false
+	}
+	if !ok || json.Unmarshal([]byte(value), &record) != nil {
+		return record, false
+	}
+	return record, true
+}
+
+// keepAlive renews the claims on this node's rooms until the hub shuts down,
+// so they expire should the node die.
+func (h *Hub) keepAlive() {
+	ticker := time.NewTicker(roomOwnerRenew)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.quit:
+			return
+		case <-ticker.C:
+		}
+
+		h.mu.RLock()
+		records := make(map[string]string, len(h.rooms))
+		for roomID, room := range h.rooms {
+			record, _ := json.Marshal(roomRecord{Node: h.node, QuizUUID: room.QuizID})
+			records[roomID] = string(record)
+		}
+		h.mu.RUnlock()
+
+		for roomID, record := range records {
+			if err := h.backplane.Set(roomKey(roomID), record, roomOwnerTTL); err != nil {
+				log.Printf("Error renewing room %s: %v", roomID, err)
+			}
+		}
+	}
+}
+
+// HasRoom reports whether a room is live on any node.
+func (h *Hub) HasRoom(roomID string) bool {
+	if _, ok := h.GetRoom(roomID); ok {
+		return true
+	}
+	_, ok := h.lookupRoom(roomID)
+	return ok
+}
+
+// Join hands a new connection to its room, relaying it if the room lives on
+// another node. It reports false if the room is gone.
+func (h *Hub) Join(roomID string, client *Client) bool {
+	if room, ok := h.GetRoom(roomID); ok {
+		client.Room = room
+		return room.Join(client)
+	}
+	owner, ok := h.lookupRoom(roomID)
+	if !ok || owner.Node == h.node {
+		return false
+	}
+
+	link := &relayLink{hub: h, room: roomID, conn: uuid.New().String()}
+	client.relay = link
+	h.relayMu.Lock()
+	h.relayed[link.conn] = client
+	h.relayMu.Unlock()
+
+	profile := client.Profile
+	profile.UserID = client.UserID
+	if !link.publish(relayMessage{Kind: relayJoin, Profile: &profile, Spectator: client.Spectator}) {
+		h.takeRelayed(link.conn)
+		return false
+	}
+	log.Printf("Client %d relayed to room %s on node %s", client.UserID, roomID, owner.Node)
+	return true
+}
+
+// takeRelayed removes a relayed client, returning nil if it was gone already.
+// Whoever takes it closes its Send.
+func (h *Hub) takeRelayed(conn string) *Client {
+	h.relayMu.Lock()
+	defer h.relayMu.Unlock()
+	client := h.relayed[conn]
+	delete(h.relayed, conn)
+	return client
+}
+
+// dropRelayed closes every relayed connection with closeCode, telling their
+// rooms the clients left.
+func (h *Hub) dropRelayed(closeCode int) {
+	h.relayMu.Lock()
+	clients := make([]*Client, 0, len(h.relayed))
+	for _, client := range h.relayed {
+		clients = append(clients, client)
+	}
+	h.relayMu.Unlock()
+
+	for _, client := range clients {
+		if h.takeRelayed(client.relay.conn) == nil {
+			continue
+		}
+		client.closeCode = closeCode
+		close(client.Send)
+		client.relay.publish(relayMessage{Kind: relayLeave})
+	}
+}
+
+// handleNodeMessage handles what owners send this node: messages for the
+// clients it relays and replies to its queries.
+func (h *Hub) handleNodeMessage(data []byte) {
+	var msg relayMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		log.Printf("Error decoding relay message: %v", err)
+		return
+	}
+
+	switch msg.Kind {
+	case relaySend:
+		h.relayMu.Lock()
+		client, dropped := h.relayed[msg.Conn], false
+		if client != nil {
+			select {
+			case client.Send <- msg.Data:
+			default:
+				// The connection cannot keep up; drop it like the room would.
+				delete(h.relayed, msg.Conn)
+				close(client.Send)
+				dropped = true
+			}
+		}
+		h.relayMu.Unlock()
+		if dropped {
+			client.relay.publish(relayMessage{Kind: relayLeave})
+		}
+	case relayClose:
+		if client := h.takeRelayed(msg.Conn); client != nil {
+			client.closeCode = msg.CloseCode
+			close(client.Send)
+		}
+	case relayReply:
+		h.relayMu.Lock()
+		reply := h.requests[msg.Request]
+		delete(h.requests, msg.Request)
+		h.relayMu.Unlock()
+		if reply != nil {
+			reply <- msg
+		}
+	}
+}
+
+// remoteKey identifies a client relayed from another node among this node's.
+func remoteKey(node string, conn string) string {
+	return node + "/" + conn
+}
+
+// handleRoomMessage handles what other nodes send a room this node owns.
+func (h *Hub) handleRoomMessage(data []byte) {
+	var msg relayMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		log.Printf("Error decoding relay message: %v", err)
+		return
+	}
+	key := remoteKey(msg.Node, msg.Conn)
+
+	switch msg.Kind {
+	case relayJoin:
+		room, ok := h.GetRoom(msg.Room)
+		if !ok || msg.Profile == nil {
+			h.publish(nodeChannel(msg.Node), relayMessage{Kind: relayClose, Node: h.node, Room: msg.Room, Conn: msg.Conn})
+			return
+		}
+		client := &Client{
+			Room:      room,
+			Send:      make(chan []byte, 256),
+			UserID:    msg.Profile.UserID,
+			Profile:   *msg.Profile,
+			Spectator: msg.Spectator,
+		}
+		h.relayMu.Lock()
+		h.remotes[key] = client
+		h.relayMu.Unlock()
+		go h.forward(client, msg.Node, msg.Conn)
+		if !room.Join(client) {
+			close(client.Send)
+		}
+	case relayInbound:
+		client := h.remote(key)
+		if client == nil {
+			return
+		}
+		inbound := &InboundMessage{}
+		if msg.Error != nil {
+			inbound.Type = msg.Error.Type
+			inbound.err = &inboundError{code: msg.Error.Code, message: msg.Error.Message, fields: msg.Error.Fields, disconnect: msg.Error.Disconnect}
+		} else {
+			inbound = decodeInbound(msg.Data)
+		}
+		inbound.Client = client
+		deliver(client.Room, client.Room.Inbound, inbound)
+	case relayHeartbeat:
+		if client := h.remote(key); client != nil {
+			client.lastPong.Store(msg.Pong)
+			client.rtt.Store(msg.RTT)
+		}
+	case relayLeave:
+		if client := h.remote(key); client != nil {
+			deliver(client.Room, client.Room.Unregister, client)
+		}
+	case relayQuery:
+		// The room answers in its own time; other messages need not wait.
+		go h.answer(msg)
+	}
+}
+
+func (h *Hub) remote(key string) *Client {
+	h.relayMu.Lock()
+	defer h.relayMu.Unlock()
+	return h.remotes[key]
+}
+
+// forward passes what the room sends a relayed client on to the client's node
+// until the room closes its Send.
+func (h *Hub) forward(client *Client, node string, conn string) {
+	for data := range client.Send {
+		h.publish(nodeChannel(node), relayMessage{Kind: relaySend, Node: h.node, Room: client.Room.ID, Conn: conn, Data: data})
+	}
+	h.relayMu.Lock()
+	delete(h.remotes, remoteKey(node, conn))
+	h.relayMu.Unlock()
+	h.publish(nodeChannel(node), relayMessage{Kind: relayClose, Node: h.node, Room: client.Room.ID, Conn: conn, CloseCode: client.closeCode})
+}
+
+// ask runs a query on the node that owns a room and decodes its result into
+// result, which may be nil.
+func (h *Hub) ask(roomID string, op string, args any, result any) error {
+	owner, ok := h.lookupRoom(roomID)
+	if !ok || owner.Node == h.node {
+		return service.ErrRoomNotFound
+	}
+	data, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+
+	reply := make(chan relayMessage, 1)
+	request := atomic.AddUint64(&h.requestSeq, 1)
+	h.relayMu.Lock()
+	h.requests[request] = reply
+	h.relayMu.Unlock()
+	defer func() {
+		h.relayMu.Lock()
+		delete(h.requests, request)
+		h.relayMu.Unlock()
+	}()
+
+	if !h.publish(roomChannel(roomID), relayMessage{Kind: relayQuery, Node: h.node, Room: roomID, Op: op, Request: request, Data: data}) {
+		return fmt.Errorf("failed to reach node %s", owner.Node)
+	}
+
+	select {
+	case msg := <-reply:
+		if msg.Failure != "" {
+			for _, err := range relayErrors {
+				if err.Error() == msg.Failure {
+					return err
+				}
+			}
+			return errors.New(msg.Failure)
+		}
+		if result == nil {
+			return nil
+		}
+		return json.Unmarshal(msg.Data, result)
+	case <-time.After(relayTimeout):
+		return fmt.Errorf("node %s did not answer %s for room %s", owner.Node, op, roomID)
+	}
+}
+
+// answer runs a query from another node and replies with its result.
+func (h *Hub) answer(msg relayMessage) {
+	reply := relayMessage{Kind: relayReply, Node: h.node, Room: msg.Room, Request: msg.Request}
+	result, err := h.runQuery(msg)
+	if err == nil {
+		reply.Data, err = json.Marshal(result)
+	}
+	if err != nil {
+		reply.Failure = err.Error()
+	}
+	h.publish(nodeChannel(msg.Node), reply)
+}
+
+func (h *Hub) runQuery(msg relayMessage) (any, error) {
+	// Only rooms of this node are queried here; anything else would go back
+	// over the backplane.
+	if _, ok := h.GetRoom(msg.Room); !ok {
+		return nil, service.ErrRoomNotFound
+	}
+
+	switch msg.Op {
+	case queryClientCount:
+		return h.GetRoomClientCount(msg.Room), nil
+	case queryClients:
+		return h.GetRoomClients(msg.Room), nil
+	case queryStats:
+		stats, ok := h.GetRoomStats(msg.Room)
+		if !ok {
+			return nil, service.ErrRoomNotFound
+		}
+		return stats, nil
+	case queryMonitor:
+		monitor, ok := h.GetRoomMonitor(msg.Room)
+		if !ok {
+			return nil, service.ErrRoomNotFound
+		}
+		return monitor, nil
+	case queryStart:
+		var req dtos.StartQuizRequest
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			return nil, err
+		}
+		return nil, h.startGame(msg.Room, req)
+	case queryAdmitGuest:
+		var args admitGuestQuery
+		if err := json.Unmarshal(msg.Data, &args); err != nil {
+			return nil, err
+		}
+		return h.AdmitGuest(msg.Room, args.PlayerID, args.Nickname)
+	}
+	return nil, fmt.Errorf("unknown query %q", msg.Op)
+}
+
+// closeRelays is part of Shutdown: relayed clients are told the server is
+// restarting, so they reconnect through another node, and their rooms that
+// they left.
+func (h *Hub) closeRelays() {
+	h.relayMu.Lock()
+	for _, client := range h.relayed {
+		select {
+		case client.Send <- restartMessage():
+		default:
+		}
+	}
+	h.relayMu.Unlock()
+	h.dropRelayed(websocket.CloseServiceRestart)
+}
+
+func restartMessage() []byte {
+	payload, _ := json.Marshal(dtos.ServerRestartPayload{Resumable: true})
+	msg, _ := json.Marshal(dtos.WebsocketMessage{Type: "server_restart", Payload: payload})
+	return msg
+}
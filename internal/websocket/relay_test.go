@@ -0,0 +1,187 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"exam/internal/dtos"
+	"exam/internal/i18n"
+	"exam/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newTestNode returns a hub and the quiz service on top of it, as one API
+// instance that shares backplane and repo with the others of a test.
+func newTestNode(t *testing.T, node string, backplane Backplane, repo *fakeQuizRepository) (*Hub, *service.QuizService) {
+	t.Helper()
+	config := DefaultConfig()
+	config.NodeID = node
+	config.RevealDuration = time.Second
+	hub := NewHubWithBackplane(config, backplane)
+	quizService := service.NewQuizService(repo, nil, nil, hub)
+	hub.SetQuizService(quizService)
+	t.Cleanup(func() { hub.Shutdown(context.Background()) })
+	return hub, quizService
+}
+
+// dialRoom connects a player to a room through hub, over a real websocket.
+func dialRoom(t *testing.T, hub *Hub, roomID string, profile dtos.PlayerProfile) *websocket.Conn {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		client := &Client{Conn: conn, Send: make(chan []byte, 256), UserID: profile.UserID, Profile: profile}
+		if !hub.Join(roomID, client) {
+			conn.Close()
+			return
+		}
+		go client.WritePump()
+		go client.ReadPump()
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("failed to dial the room: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readMessage reads from conn until a message of type msgType arrives. A
+// frame may hold several messages, see Client.WritePump.
+func readMessage(t *testing.T, conn *websocket.Conn, msgType string) json.RawMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("waiting for %s: %v", msgType, err)
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		for decoder.More() {
+			var msg dtos.WebsocketMessage
+			if err := decoder.Decode(&msg); err != nil {
+				t.Fatalf("malformed message %q: %v", data, err)
+			}
+			if msg.Type == msgType {
+				return msg.Payload
+			}
+		}
+	}
+}
+
+// eventually polls condition until it holds, or fails the test.
+func eventually(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting until %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRelayBetweenNodes(t *testing.T) {
+	i18n.Init()
+	backplane := NewMemoryBackplane()
+	repo := newFakeQuizRepository(2)
+	owner, ownerQuizzes := newTestNode(t, "owner", backplane, repo)
+	relay, relayQuizzes := newTestNode(t, "relay", backplane, repo)
+
+	lobby, err := ownerQuizzes.OpenLobby("quiz", 99)
+	if err != nil {
+		t.Fatalf("OpenLobby returned %v", err)
+	}
+	if _, ok := relay.GetRoom(lobby.RoomID); ok {
+		t.Fatal("the room runs on the relaying node too")
+	}
+	if roomID, ok := relay.ResolvePIN(lobby.PIN); !ok || roomID != lobby.RoomID {
+		t.Fatalf("ResolvePIN on the other node = %q, %v; want %q", roomID, ok, lobby.RoomID)
+	}
+	if quizUUID, ok := relay.GetRoomQuizUUID(lobby.RoomID); !ok || quizUUID != "quiz" {
+		t.Fatalf("GetRoomQuizUUID on the other node = %q, %v", quizUUID, ok)
+	}
+
+	// Join: the player connects to the relaying node and shows up in the room.
+	conn := dialRoom(t, relay, lobby.RoomID, dtos.PlayerProfile{UserID: 1, Nickname: "bee"})
+	eventually(t, "the relayed player is in the room", func() bool {
+		return owner.GetRoomClientCount(lobby.RoomID) == 1
+	})
+
+	// Queries: what the relaying node asks for is answered by the owner.
+	if n := relay.GetRoomClientCount(lobby.RoomID); n != 1 {
+		t.Fatalf("GetRoomClientCount on the other node = %d, want 1", n)
+	}
+	if clients := relay.GetRoomClients(lobby.RoomID); len(clients) != 1 || clients[0].UserID != 1 {
+		t.Fatalf("GetRoomClients on the other node = %+v", clients)
+	}
+	if _, ok := relay.GetRoomStats(lobby.RoomID); !ok {
+		t.Fatal("GetRoomStats on the other node found no room")
+	}
+	if _, ok := relay.GetRoomMonitor(lobby.RoomID); !ok {
+		t.Fatal("GetRoomMonitor on the other node found no room")
+	}
+	if _, err := relay.AdmitGuest("no-such-room", 5, "guest"); !errors.Is(err, service.ErrRoomNotFound) {
+		t.Fatalf("AdmitGuest to a missing room = %v, want ErrRoomNotFound", err)
+	}
+	if err := relayQuizzes.StartQuiz("quiz", lobby.RoomID, 99, dtos.StartQuizRequest{Mode: "sync"}); err != nil {
+		t.Fatalf("StartQuiz on the other node returned %v", err)
+	}
+	readMessage(t, conn, "next_question")
+
+	// Inbound: what the player sends reaches the room, and the reply comes back.
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"submit_answer","payload":{"question_id":1,"answer":"a"}}`))
+	var result struct {
+		IsCorrect bool `json:"is_correct"`
+	}
+	if err := json.Unmarshal(readMessage(t, conn, "answer_result"), &result); err != nil || !result.IsCorrect {
+		t.Fatalf("answer_result = %+v, %v; want a correct answer", result, err)
+	}
+	conn.WriteMessage(websocket.TextMessage, []byte(`not json`))
+	readMessage(t, conn, "error")
+
+	// Leave: the player disconnects from the relaying node and leaves the room.
+	conn.Close()
+	eventually(t, "the relayed player left the room", func() bool {
+		return owner.GetRoomClientCount(lobby.RoomID) == 0
+	})
+}
+
+func TestRelayedClientsOfAStoppingNode(t *testing.T) {
+	i18n.Init()
+	backplane := NewMemoryBackplane()
+	repo := newFakeQuizRepository(1)
+	owner, ownerQuizzes := newTestNode(t, "owner", backplane, repo)
+	relay, _ := newTestNode(t, "relay", backplane, repo)
+
+	lobby, err := ownerQuizzes.OpenLobby("quiz", 99)
+	if err != nil {
+		t.Fatalf("OpenLobby returned %v", err)
+	}
+	conn := dialRoom(t, relay, lobby.RoomID, dtos.PlayerProfile{UserID: 1, Nickname: "bee"})
+	eventually(t, "the relayed player is in the room", func() bool {
+		return owner.GetRoomClientCount(lobby.RoomID) == 1
+	})
+
+	relay.Shutdown(context.Background())
+	readMessage(t, conn, "server_restart")
+	eventually(t, "the room let the player go", func() bool {
+		return owner.GetRoomClientCount(lobby.RoomID) == 0
+	})
+
+	owner.Shutdown(context.Background())
+	if relay.HasRoom(lobby.RoomID) {
+		t.Fatal("the room is still claimed after its node shut down")
+	}
+}
@@ -0,0 +1,133 @@
+package websocket
+
+import (
+	"encoding/json"
+	"exam/internal/dtos"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const replayPageSize = 200
+
+// ReplayEvents reads a page of a session's event log: up to limit events
+// after afterSeq, in order.
+type ReplayEvents func(afterSeq int, limit int) ([]dtos.SessionEventDTO, error)
+
+// Replay streams a session's event log over conn as 'replay_event' messages,
+// with the gaps between events as they happened divided by speed, and ends
+// with 'replay_complete'. Viewers control it by sending 'pause', 'resume' and,
+// while paused, 'step' to get the next event right away. A replay that starts
+// paused is stepped through one event at a time. Replay returns once the log
+// has been sent or the viewer has gone, and closes conn.
+func Replay(conn *websocket.Conn, events ReplayEvents, afterSeq int, speed float64, paused bool) {
+	defer conn.Close()
+	if speed <= 0 {
+		speed = 1
+	}
+
+	controls, done := make(chan string), make(chan struct{})
+	defer close(done)
+	go readReplayControls(conn, controls, done)
+	p := &replayer{conn: conn, controls: controls, paused: paused}
+
+	var previous time.Time
+	for {
+		page, err := events(afterSeq, replayPageSize)
+		if err != nil {
+			p.send("error", dtos.ErrorPayload{Code: dtos.ErrorCodeInternal, Message: "Failed to read the event log"})
+			return
+		}
+		if len(page) == 0 {
+			p.send("replay_complete", nil)
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(writeWait))
+			return
+		}
+
+		for _, event := range page {
+			var delay time.Duration
+			if !previous.IsZero() {
+				delay = time.Duration(float64(event.OccurredAt.Sub(previous)) / speed)
+			}
+			if !p.wait(delay) {
+				return
+			}
+			if !p.send("replay_event", event) {
+				return
+			}
+			previous = event.OccurredAt
+			afterSeq = event.Seq
+		}
+	}
+}
+
+// replayer holds the state of one replay stream.
+type replayer struct {
+	conn     *websocket.Conn
+	controls <-chan string // Message types sent by the viewer; closed once they have gone
+	paused   bool
+}
+
+// wait holds the next event back for delay, or while paused until the viewer
+// steps or resumes. It reports false once the viewer has gone.
+func (p *replayer) wait(delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	for {
+		var due <-chan time.Time
+		if !p.paused {
+			due = timer.C
+		}
+		select {
+		case <-due:
+			return true
+		case control, ok := <-p.controls:
+			if !ok {
+				return false
+			}
+			switch control {
+			case "pause":
+				p.paused = true
+			case "resume":
+				p.paused = false
+			case "step":
+				if p.paused {
+					return true
+				}
+			default:
+				p.send("error", dtos.ErrorPayload{Code: dtos.ErrorCodeUnknownType, Message: "Replays take pause, resume and step", Type: control})
+			}
+		}
+	}
+}
+
+func (p *replayer) send(msgType string, payload interface{}) bool {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return false
+	}
+	p.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return p.conn.WriteJSON(dtos.WebsocketMessage{Type: msgType, Payload: payloadBytes}) == nil
+}
+
+// readReplayControls passes the type of every message the viewer sends on to
+// controls until the connection is gone or the replay is done.
+func readReplayControls(conn *websocket.Conn, controls chan<- string, done <-chan struct{}) {
+	defer close(controls)
+	conn.SetReadLimit(maxMessageSize)
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var msg dtos.WebsocketMessage
+		if err := json.Unmarshal(message, &msg); err != nil {
+			continue
+		}
+		select {
+		case controls <- msg.Type:
+		case <-done:
+			return
+		}
+	}
+}
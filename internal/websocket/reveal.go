@@ -0,0 +1,72 @@
+package websocket
+
+import (
+	"encoding/json"
+	"exam/internal/dtos"
+	"exam/internal/service"
+	"log"
+	"math"
+	"time"
+)
+
+// maxRevealSeconds caps the reveal duration, as dtos.StartQuizRequest does.
+const maxRevealSeconds = 60
+
+// questionTally counts the answers to the current sync question for its reveal.
+type questionTally struct {
+	options   map[string]int // Option ID -> players who picked it
+	correct   int
+	total     int
+	totalTime time.Duration
+}
+
+func newQuestionTally() *questionTally {
+	return &questionTally{options: make(map[string]int)}
+}
+
+func (t *questionTally) add(answer string, isCorrect bool, responseTime time.Duration) {
+	t.options[answer]++
+	t.total++
+	t.totalTime += responseTime
+	if isCorrect {
+		t.correct++
+	}
+}
+
+// revealResults shows everyone the answer to the current sync question and how
+// the room answered it. The next question follows after the reveal duration.
+func (r *Room) revealResults() {
+	if r.currentQuestionIndex < 0 || r.currentQuestionIndex >= len(r.quiz.Questions) {
+		return
+	}
+	question := r.quiz.Questions[r.currentQuestionIndex]
+	tally := r.tally
+
+	results := &dtos.QuestionResultsPayload{
+		QuestionID:       question.ID,
+		CorrectOptionIDs: service.CorrectOptionIDs(question),
+		OptionCounts:     []dtos.OptionCount{},
+		TotalAnswers:     tally.total,
+		CorrectAnswers:   tally.correct,
+		RevealSeconds:    durationSeconds(r.revealDuration),
+	}
+	var options []dtos.QuestionOption
+	if err := json.Unmarshal(question.Options, &options); err != nil {
+		log.Printf("Error reading the options of question %d: %v", question.ID, err)
+	}
+	for _, option := range options {
+		results.OptionCounts = append(results.OptionCounts, dtos.OptionCount{OptionID: option.ID, Count: tally.options[option.ID]})
+	}
+	if tally.total > 0 {
+		results.PercentCorrect = math.Round(float64(tally.correct)/float64(tally.total)*1000) / 10
+		results.AverageTimeMs = (tally.totalTime / time.Duration(tally.total)).Milliseconds()
+	}
+	if question.Explanation != nil {
+		results.Explanation = *question.Explanation
+	}
+
+	r.results = results
+	log.Printf("Revealing results of question %d in room %s: %d of %d correct", r.currentQuestionIndex+1, r.ID, tally.correct, tally.total)
+	r.broadcastMessage("question_results", results, nil)
+	r.scheduleAdvance(r.revealDuration, r.sendNextQuestion)
+}
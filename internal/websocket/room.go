@@ -5,31 +5,47 @@ import (
 	"exam/internal/dtos"
 	"exam/internal/model"
 	"exam/internal/service"
-	"fmt"
 	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
 	StateWaiting    = "waiting"
 	StateInProgress = "in_progress"
+	StatePaused     = "paused"
 	StateFinished   = "finished"
 )
 
+// startGamePayload is the payload of a 'start_game' message. Once the room has
+// recorded the session, it carries the session's ID and seed, see startSession.
+type startGamePayload struct {
+	SessionID     uint   `json:"session_id"`
+	Mode          string `json:"mode" validate:"required,oneof=sync parallel"`
+	Scoring       string `json:"scoring"`
+	TimeLimit     int    `json:"time_limit" validate:"min=0"`            // Optional overall limit for the session, in seconds
+	RevealSeconds int    `json:"reveal_seconds" validate:"min=0,max=60"` // How long sync question results are shown; 0 for the default
+	ShuffleSeed   int64  `json:"shuffle_seed"`   // The session's seed for per-player question and option order
+}
+
 // InboundMessage is a message from a client to the room.
 type InboundMessage struct {
 	Client  *Client
 	Type    string
-	Payload json.RawMessage
+	Payload any           // Decoded and validated, see inboundTypes
+	err     *inboundError // Set if the message was rejected before reaching the room
 }
 
 // Room maintains the set of active clients and manages the game state.
 type Room struct {
+	ID                          string // Unique ID of this room; several rooms may play the same quiz
 	QuizID                      string
 	quizSessionID               uint // New field to store the ID of the current quiz session
 	State                       string
 	Mode                        string           // "sync" or "parallel"
 	Clients                     map[*Client]bool
+	spectators                  map[*Client]bool // Connections watching the room, kept out of Clients
 	clientsByUserID             map[uint]*Client // New map to track clients by UserID
 	Register                    chan *Client
 	Unregister                  chan *Client
@@ -37,77 +53,217 @@ type Room struct {
 	quizService                 *service.QuizService
 	quiz                        *model.Quiz
 	scores                      map[uint]*dtos.PlayerScore
+	shuffleSeed                 int64 // Seeds every player's question and option order, see service.Shuffle
+	draws                       map[uint][]model.Question // Questions each parallel player drew from the quiz's pools
+	events                      *eventLog                 // Log of the current quiz session; nil when there is none
+	handling                    *handledMessage           // The inbound message being handled, until it is logged
+	profiles                    map[uint]dtos.PlayerProfile // UserID -> how the player is shown; kept while they reconnect
+	guestNicknames              map[string]uint             // Lowercased nickname -> guest player ID
+	guestAdmissions             chan guestAdmission
 	currentQuestionIndex        int
 	answeredPlayers             map[uint]bool // Players who have answered the current question
 	isQuestionAnsweredCorrectly bool          // Flag to track if the current question has been answered correctly by anyone
+	questionOpen                bool          // The current sync question still takes answers towards moving on
+	tally                       *questionTally
+	results                     *dtos.QuestionResultsPayload // Results of the current sync question once revealed
+	revealDuration              time.Duration
+	activitySince               time.Time     // Idle clocks start no earlier than this, i.e. the game start
 	questionTimer               *time.Timer   // Timer for the current question
+	questionTimerToken          int           // Token of the running question timer; 0 when it is not running
+	questionDeadline            time.Time     // When the current sync question times out
+	questionSentAt              time.Time     // When the current sync question was sent, shifted by pauses
+	advanceTimer                *time.Timer   // Pending move to the next step of the game
+	advanceToken                int           // Token of the pending advance; 0 when nothing is pending
+	advanceFn                   func()
+	advanceAt                   time.Time
+	epoch                       int // Bumped with every question and when a game starts or ends
+	timerSeq                    int
+	timerFired                  chan timerEvent
+	config                      Config
+
+	// Host fields
+	hostID          uint          // The quiz creator, who controls the game
+	kicked          map[uint]bool // Players removed by the host
+	pausedQuestion  bool          // Whether the question timer was running when the game was paused
+	pausedRemaining time.Duration // Time left on the paused question timer
+	pausedAdvance   bool          // Whether a move to the next step was pending when the game was paused
+	pausedAt        time.Time
+
+	// Scoring fields
+	scoring service.ScoringStrategy
+	streaks map[uint]int // UserID -> correct answers in a row
+
+	// Team fields
+	teams       []*team
+	playerTeams map[uint]string // UserID -> team ID
+	teamScoring string          // How member scores add up to a team score
+
+	// Reconnection fields
+	disconnected  map[uint]int // UserID -> grace period token for players who dropped
+	disconnectSeq int
+	graceExpired  chan graceExpiry
+
+	// Session deadline fields
+	sessionTimer    *time.Timer
+	sessionDeadline time.Time
+	sessionPaused    bool
+	sessionRemaining time.Duration // Time left on the session when it was paused
+	sessionTimerSeq int
+	sessionExpired  chan int
 
 	// Parallel mode fields
 	clientProgress map[uint]int  // UserID -> current question index
 	finishedClients map[uint]bool // UserID -> bool
+	clientQuestionSentAt map[uint]time.Time // UserID -> when their current question was sent
+	clientTimers         map[uint]*playerTimer
+	progress             map[uint]*playerProgress // UserID -> answers so far, for the host's monitor
+	clientTimerSeq       int
+	clientTimeouts       chan playerTimeout
+
+	// Lifecycle fields
+	hub        *Hub        // Set when the room is registered
+	queries    chan func() // Work on room state from other goroutines, see query
+	stop       chan struct{}
+	stopOnce   sync.Once
+	suspended  atomic.Bool   // Stopping for a server restart, so a running game is checkpointed, see Suspend
+	done       chan struct{} // Closed once Run has returned
+	createdAt  time.Time
+	emptySince time.Time // When the last client left; zero while anyone is connected
+	finishedAt time.Time
+
+	// Outbound fields, see outbound.go.
+	outbound    outboundStats
+	backlogged  map[*Client]bool // Clients with state messages held back
+	slowClients []*Client        // Clients to evict once the current event is handled
 }
 
-func NewRoom(quizID string, quizService *service.QuizService) *Room {
+func NewRoom(roomID string, quizID string, hostID uint, quizService *service.QuizService, config Config) *Room {
 	return &Room{
+		ID:                          roomID,
 		QuizID:                      quizID,
+		hostID:                      hostID,
+		kicked:                      make(map[uint]bool),
+		streaks:                     make(map[uint]int),
+		playerTeams:                 make(map[uint]string),
+		teamScoring:                 TeamScoringSum,
 		quizSessionID:               0, // Initialize with 0, will be set by startGame
 		State:                       StateWaiting,
 		Mode:                        "sync", // Default mode
 		Clients:                     make(map[*Client]bool),
+		spectators:                  make(map[*Client]bool),
 		clientsByUserID:             make(map[uint]*Client), // Initialize the new map
 		Register:                    make(chan *Client),
 		Unregister:                  make(chan *Client),
 		Inbound:                     make(chan *InboundMessage),
 		quizService:                 quizService,
 		scores:                      make(map[uint]*dtos.PlayerScore),
+		draws:                       make(map[uint][]model.Question),
+		profiles:                    make(map[uint]dtos.PlayerProfile),
+		guestNicknames:              make(map[string]uint),
+		guestAdmissions:             make(chan guestAdmission),
 		currentQuestionIndex:        -1,
 		answeredPlayers:             make(map[uint]bool),
 		isQuestionAnsweredCorrectly: false,
+		tally:                       newQuestionTally(),
 		clientProgress:              make(map[uint]int),
 		finishedClients:             make(map[uint]bool),
+		clientQuestionSentAt:        make(map[uint]time.Time),
+		config:                      config,
+		disconnected:                make(map[uint]int),
+		graceExpired:                make(chan graceExpiry),
+		sessionExpired:              make(chan int),
+		clientTimers:                make(map[uint]*playerTimer),
+		progress:                    make(map[uint]*playerProgress),
+		clientTimeouts:              make(chan playerTimeout),
+		timerFired:                  make(chan timerEvent),
+		queries:                     make(chan func()),
+		stop:                        make(chan struct{}),
+		done:                        make(chan struct{}),
+		createdAt:                   time.Now(),
+		emptySince:                  time.Now(),
+		outbound:                    outboundStats{dropped: make(map[string]int)},
+		backlogged:                  make(map[*Client]bool),
 	}
 }
 
 func (r *Room) Run() {
-	log.Printf("Room %s is running", r.QuizID)
+	log.Printf("Room %s is running for quiz %s", r.ID, r.QuizID)
+	presenceTicker := time.NewTicker(presenceCheckInterval)
+	defer presenceTicker.Stop()
+	defer close(r.done)
 	for {
 		select {
+		case <-r.stop:
+			r.shutdown()
+			return
+		case fn := <-r.queries:
+			fn()
+		case event := <-r.timerFired:
+			r.handleTimerEvent(event)
 		case client := <-r.Register:
 			r.handleClientRegister(client)
 		case client := <-r.Unregister:
 			r.handleClientUnregister(client)
 		case msg := <-r.Inbound:
 			r.handleInboundMessage(msg)
+		case expiry := <-r.graceExpired:
+			r.handleGraceExpired(expiry)
+		case timeout := <-r.clientTimeouts:
+			r.handlePlayerTimeout(timeout)
+		case token := <-r.sessionExpired:
+			r.handleSessionExpired(token)
+		case admission := <-r.guestAdmissions:
+			r.handleGuestAdmission(admission)
+		case <-presenceTicker.C:
+			r.checkPresence()
+			r.checkLifecycle()
 		}
+		r.drainOutbound()
 	}
 }
 
 func (r *Room) handleInboundMessage(msg *InboundMessage) {
+	if msg.err != nil {
+		r.handleInboundError(msg)
+		return
+	}
+	r.handling = &handledMessage{msg: msg, events: r.events}
+	defer r.flushInbound()
+	if msg.Client != nil && msg.Client.Spectator {
+		r.handleSpectatorMessage(msg)
+		return
+	}
+	if msg.Client != nil {
+		r.trackActivity(msg.Client)
+		r.updatePresence(msg.Client)
+	}
+
 	switch msg.Type {
 	case "start_game":
-		var payload struct {
-			SessionID uint   `json:"session_id"`
-			Mode      string `json:"mode"`
-		}
-		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
-			log.Printf("Error unmarshalling start_game payload: %v", err)
-			return
-		}
-		r.startGame(payload.SessionID, payload.Mode)
+		r.handleHostStartGame(msg.Client, *msg.Payload.(*startGamePayload))
+
+	case "pause_game", "resume_game", "skip_question", "kick_player", "extend_timer", "end_game",
+		"create_teams", "assign_team", "auto_assign_teams", "clear_teams":
+		r.handleHostCommand(msg)
 
 	case "submit_answer":
-		var payload dtos.SubmitAnswerPayload
-		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
-			log.Printf("Error unmarshalling submit_answer payload: %v", err)
-			return
+		r.handleSubmitAnswer(msg.Client, *msg.Payload.(*dtos.SubmitAnswerPayload))
+
+	case "resume":
+		if msg.Client != nil {
+			r.sendStateSnapshot(msg.Client)
+		}
+
+	case "presence":
+		if msg.Client != nil {
+			r.handlePresenceMessage(msg.Client, *msg.Payload.(*presencePayload))
 		}
-		r.handleSubmitAnswer(msg.Client, payload)
 	}
 }
 
-func (r *Room) startGame(sessionID uint, mode string) {
-	if r.State == StateInProgress {
-		log.Printf("Attempted to start a game that is already in progress for room %s.", r.QuizID)
+func (r *Room) startGame(settings startGamePayload) {
+	if r.State == StateInProgress || r.State == StatePaused {
+		log.Printf("Attempted to start a game that is already in progress for room %s.", r.ID)
 		return
 	}
 
@@ -116,101 +272,312 @@ func (r *Room) startGame(sessionID uint, mode string) {
 		r.reset()
 	}
 
-	r.quizSessionID = sessionID
-	r.Mode = mode
+	scoring, err := service.NewScoringStrategy(service.ResolveScoringStrategy(settings.Scoring, "", settings.Mode))
+	if err != nil {
+		log.Printf("Error selecting scoring strategy for room %s: %v", r.ID, err)
+		return
+	}
+
+	r.quizSessionID = settings.SessionID
+	r.Mode = settings.Mode
+	r.scoring = scoring
+	r.revealDuration = r.config.RevealDuration
+	if settings.RevealSeconds > 0 {
+		r.revealDuration = time.Duration(settings.RevealSeconds) * time.Second
+	}
+	r.shuffleSeed = settings.ShuffleSeed
+	if r.shuffleSeed == 0 {
+		r.shuffleSeed = service.NewShuffleSeed()
+	}
 
 	quiz, err := r.quizService.GetQuizWithQuestions(r.QuizID)
 	if err != nil {
 		log.Printf("Error loading quiz: %v", err)
 		return
 	}
+	// A sync game is played from a single draw. Parallel players each draw
+	// their own, see playerQuestions.
+	if len(quiz.Pools) > 0 && r.Mode != "parallel" {
+		quiz.Questions = service.DrawQuestions(quiz, r.shuffleSeed, 0)
+		r.recordDraw(0, quiz.Questions)
+	}
 	r.quiz = quiz
 	r.State = StateInProgress
+	r.epoch++
+	r.activitySince = time.Now()
+	r.openEventLog()
 
-	log.Printf("Starting game for quiz: %s (Session ID: %d, Mode: %s)", r.quiz.Title, r.quizSessionID, r.Mode)
-	r.broadcastMessage("game_starting", map[string]string{"mode": r.Mode}, nil)
+	log.Printf("Starting game for quiz: %s (Session ID: %d, Mode: %s, Scoring: %s)", r.quiz.Title, r.quizSessionID, r.Mode, r.scoring.Name())
+	r.broadcastMessage("game_starting", map[string]string{"mode": r.Mode, "scoring": r.scoring.Name()}, nil)
 
 	if r.Mode == "parallel" {
 		// Initialize progress for all players
 		for client := range r.Clients {
+			if r.isHostClient(client) {
+				continue
+			}
 			r.clientProgress[client.UserID] = 0
 		}
 		// Send the first question to everyone
-		time.AfterFunc(3*time.Second, func() {
-			for client := range r.Clients {
-				r.sendQuestionToClient(client, 0)
-			}
-		})
+		r.scheduleAdvance(3*time.Second, r.sendFirstQuestions)
 	} else { // sync mode
-		time.AfterFunc(3*time.Second, r.sendNextQuestion)
+		r.scheduleAdvance(3*time.Second, r.sendNextQuestion)
+	}
+
+	// The session clock starts once the countdown is over.
+	if settings.TimeLimit > 0 {
+		r.startSessionTimer(3*time.Second + time.Duration(settings.TimeLimit)*time.Second)
 	}
+	r.sendMonitor()
 }
 
 func (r *Room) reset() {
-	log.Printf("Resetting room %s for new game.", r.QuizID)
+	log.Printf("Resetting room %s for new game.", r.ID)
 	r.State = StateWaiting
 	r.scores = make(map[uint]*dtos.PlayerScore)
 	r.currentQuestionIndex = -1
 	r.answeredPlayers = make(map[uint]bool)
 	r.isQuestionAnsweredCorrectly = false
+	r.questionOpen = false
+	r.questionDeadline = time.Time{}
+	r.tally = newQuestionTally()
+	r.results = nil
 	r.clientProgress = make(map[uint]int)
 	r.finishedClients = make(map[uint]bool)
-	if r.questionTimer != nil {
-		r.questionTimer.Stop()
-	}
+	r.clientQuestionSentAt = make(map[uint]time.Time)
+	r.progress = make(map[uint]*playerProgress)
+	r.draws = make(map[uint][]model.Question)
+	r.streaks = make(map[uint]int)
+	r.stopTimers()
 
 	// Re-initialize scores for existing clients
 	for client := range r.Clients {
-		// This logic for generating UserName is duplicated from handleClientRegister
-		// Consider refactoring if it becomes more complex.
-		userName := "Player " + fmt.Sprintf("%d", client.UserID)
-		r.scores[client.UserID] = &dtos.PlayerScore{UserID: client.UserID, UserName: userName, Score: 0}
+		if r.isHostClient(client) {
+			continue
+		}
+		r.scores[client.UserID] = r.newPlayerScore(client.UserID)
 	}
 }
 
 func (r *Room) sendNextQuestion() {
 	r.currentQuestionIndex++
-	log.Printf("sendNextQuestion called. Current question index: %d, Total questions: %d for quiz %s", r.currentQuestionIndex, len(r.quiz.Questions), r.QuizID)
+	log.Printf("sendNextQuestion called. Current question index: %d, Total questions: %d for room %s", r.currentQuestionIndex, len(r.quiz.Questions), r.ID)
 
 	if r.currentQuestionIndex >= len(r.quiz.Questions) {
 		r.endGame()
 		return
 	}
 
-	// Reset trackers for the new question
+	// Reset trackers for the new question. Timer events of the previous
+	// question are stale from here on.
+	r.epoch++
 	r.answeredPlayers = make(map[uint]bool)
 	r.isQuestionAnsweredCorrectly = false
+	r.tally = newQuestionTally()
+	r.results = nil
 
 	currentQuestion := r.quiz.Questions[r.currentQuestionIndex]
-	questionDTO := dtos.QuizQuestionDTO{
-		ID:      currentQuestion.ID,
-		Content: json.RawMessage(currentQuestion.Content),
-		Options: json.RawMessage(currentQuestion.Options),
-		Timer:   currentQuestion.Timer,
-	}
 
 	log.Printf("Sending question %d with timer %d seconds", r.currentQuestionIndex+1, currentQuestion.Timer)
-	r.broadcastMessage("next_question", questionDTO, nil)
+	r.questionSentAt = time.Now()
+	r.questionOpen = true
+	r.questionDeadline = time.Time{}
 
 	// If the timer is > 0, start a countdown.
 	if currentQuestion.Timer > 0 {
-		if r.questionTimer != nil {
-			r.questionTimer.Stop()
+		r.startQuestionTimer(time.Duration(currentQuestion.Timer) * time.Second)
+	}
+	r.broadcastQuestion(currentQuestion)
+}
+
+// broadcastQuestion sends the current sync question to everyone, with the
+// options in each player's own order when the quiz shuffles them.
+func (r *Room) broadcastQuestion(question model.Question) {
+	if !r.quiz.ShuffleOptions {
+		r.broadcastMessage("next_question", nextQuestionPayload(question, r.questionSentAt, r.questionDeadline), nil)
+		return
+	}
+	for client := range r.Clients {
+		playerQuestion := question
+		if !r.isHostClient(client) {
+			playerQuestion = r.shuffle(client.UserID).Options(question)
+		}
+		r.sendMessageToClient(client, "next_question", nextQuestionPayload(playerQuestion, r.questionSentAt, r.questionDeadline))
+	}
+	for spectator := range r.spectators {
+		r.sendMessageToClient(spectator, "next_question", nextQuestionPayload(question, r.questionSentAt, r.questionDeadline))
+	}
+}
+
+// shuffle is the player's question and option order in this session.
+func (r *Room) shuffle(userID uint) service.Shuffle {
+	return service.NewShuffle(r.quiz, r.shuffleSeed, userID)
+}
+
+// playerQuestion is the question a parallel player gets at the given
+// position, in their own order.
+func (r *Room) playerQuestion(userID uint, position int) model.Question {
+	return r.shuffle(userID).Question(r.playerQuestions(userID), position)
+}
+
+// playerQuestions are the questions a parallel player plays. If the quiz has
+// pools, the player draws their own the first time they are asked for.
+func (r *Room) playerQuestions(userID uint) []model.Question {
+	if r.Mode != "parallel" || len(r.quiz.Pools) == 0 {
+		return r.quiz.Questions
+	}
+	if questions, ok := r.draws[userID]; ok {
+		return questions
+	}
+	questions := service.DrawQuestions(r.quiz, r.shuffleSeed, userID)
+	r.draws[userID] = questions
+	r.recordDraw(userID, questions)
+	return questions
+}
+
+// questionCount is how many questions every player gets.
+func (r *Room) questionCount() int {
+	if r.Mode == "parallel" {
+		return service.DrawSize(r.quiz)
+	}
+	return len(r.quiz.Questions)
+}
+
+// recordDraw stores the questions drawn for a player, or for everyone if
+// userID is 0, in the current quiz session, if there is one.
+func (r *Room) recordDraw(userID uint, questions []model.Question) {
+	if r.quizSessionID == 0 {
+		return
+	}
+	if err := r.quizService.RecordQuestionDraw(service.NewSessionDraw(r.quizSessionID, userID, questions)); err != nil {
+		log.Printf("Error recording question draw for session %d: %v", r.quizSessionID, err)
+	}
+}
+
+// gameTimer is one of the shared timers of a sync game.
+type gameTimer int
+
+const (
+	timerQuestion gameTimer = iota // The current question ran out of time
+	timerAdvance                   // Time to move on to the next step of the game
+)
+
+// timerEvent is delivered to the room loop when a shared timer fires. Timers
+// never touch room state themselves. The epoch drops events of an earlier
+// question or game, the token those of a timer that was stopped or replaced.
+type timerEvent struct {
+	timer gameTimer
+	epoch int
+	token int
+}
+
+func (r *Room) nextTimerToken() int {
+	r.timerSeq++
+	return r.timerSeq
+}
+
+// fireAfter starts a timer that delivers a timerEvent stamped with the
+// current epoch.
+func (r *Room) fireAfter(d time.Duration, timer gameTimer, token int) *time.Timer {
+	event := timerEvent{timer: timer, epoch: r.epoch, token: token}
+	return time.AfterFunc(d, func() {
+		deliver(r, r.timerFired, event)
+	})
+}
+
+func (r *Room) handleTimerEvent(event timerEvent) {
+	if event.epoch != r.epoch || r.State != StateInProgress {
+		return
+	}
+
+	switch event.timer {
+	case timerQuestion:
+		if event.token != r.questionTimerToken || !r.questionOpen {
+			return
 		}
-		r.questionTimer = time.AfterFunc(time.Duration(currentQuestion.Timer)*time.Second, r.timeUp)
+		r.questionTimerToken = 0
+		r.timeUp()
+	case timerAdvance:
+		if event.token != r.advanceToken {
+			return
+		}
+		r.advanceToken = 0
+		r.advanceFn()
 	}
 }
 
+// startQuestionTimer (re)starts the countdown for the current sync question.
+func (r *Room) startQuestionTimer(d time.Duration) {
+	r.stopQuestionTimer()
+	r.questionTimerToken = r.nextTimerToken()
+	r.questionDeadline = time.Now().Add(d)
+	r.questionTimer = r.fireAfter(d, timerQuestion, r.questionTimerToken)
+}
+
+// scheduleAdvance runs fn after delay unless the host pauses or ends the game first.
+func (r *Room) scheduleAdvance(delay time.Duration, fn func()) {
+	r.stopAdvance()
+	r.advanceToken = r.nextTimerToken()
+	r.advanceFn = fn
+	r.advanceAt = time.Now().Add(delay)
+	r.advanceTimer = r.fireAfter(delay, timerAdvance, r.advanceToken)
+}
+
+// stopQuestionTimer reports whether the question timer was still running.
+// A timer that already fired is stopped too: its event is ignored once it
+// reaches the loop.
+func (r *Room) stopQuestionTimer() bool {
+	if r.questionTimerToken == 0 {
+		return false
+	}
+	r.questionTimer.Stop()
+	r.questionTimerToken = 0
+	return true
+}
+
+// stopAdvance reports whether a move to the next step was pending.
+func (r *Room) stopAdvance() bool {
+	if r.advanceToken == 0 {
+		return false
+	}
+	r.advanceTimer.Stop()
+	r.advanceToken = 0
+	return true
+}
+
+// stopTimers stops every timer of the current game.
+func (r *Room) stopTimers() {
+	r.stopQuestionTimers()
+	r.stopPlayerTimers()
+	r.stopSessionTimer()
+}
+
+// stopQuestionTimers stops the shared sync question timer and any pending advance.
+func (r *Room) stopQuestionTimers() {
+	r.stopQuestionTimer()
+	r.stopAdvance()
+	r.pausedQuestion = false
+	r.pausedAdvance = false
+}
+
+// timeUp closes the current sync question when its timer runs out.
 func (r *Room) timeUp() {
-	log.Printf("Time is up for question %d in room %s", r.currentQuestionIndex+1, r.QuizID)
+	log.Printf("Time is up for question %d in room %s", r.currentQuestionIndex+1, r.ID)
+	r.questionOpen = false
 	r.broadcastMessage("time_up", nil, nil)
 
-	// Wait a bit before sending the next question
-	time.AfterFunc(2*time.Second, r.sendNextQuestion)
+	// Answers sent just before the deadline may still be on their way.
+	r.scheduleAdvance(r.config.AnswerGrace, r.revealResults)
 }
 
 func (r *Room) handleSubmitAnswer(client *Client, payload dtos.SubmitAnswerPayload) {
 	if r.State != StateInProgress {
+		r.rejectInbound(client)
+		return
+	}
+
+	if r.isHostClient(client) {
+		r.sendError(client, dtos.ErrorCodeForbidden, "The host cannot submit answers")
 		return
 	}
 
@@ -222,237 +589,439 @@ func (r *Room) handleSubmitAnswer(client *Client, payload dtos.SubmitAnswerPaylo
 }
 
 func (r *Room) handleSyncAnswer(client *Client, payload dtos.SubmitAnswerPayload) {
+	now := time.Now()
+	if r.currentQuestionIndex < 0 || r.currentQuestionIndex >= len(r.quiz.Questions) {
+		r.rejectAnswer(client, payload, 0, dtos.AnswerRejectedWrongQuestion, "No question is being asked")
+		return
+	}
+	currentQuestion := r.quiz.Questions[r.currentQuestionIndex]
+	if !r.acceptsAnswer(client, payload, currentQuestion, r.questionDeadline, now) {
+		return
+	}
+	if r.results != nil {
+		r.rejectAnswer(client, payload, currentQuestion.ID, dtos.AnswerRejectedDeadlinePassed, "The answer has already been revealed")
+		return
+	}
 	if r.answeredPlayers[client.UserID] {
+		r.rejectAnswer(client, payload, currentQuestion.ID, dtos.AnswerRejectedAlreadyAnswered, "You already answered this question")
 		return
 	}
 
 	r.answeredPlayers[client.UserID] = true
-	log.Printf("User %d submitted answer for question %d. Answered players: %d, Total clients: %d", client.UserID, payload.QuestionID, len(r.answeredPlayers), len(r.Clients))
-
-	currentQuestion := r.quiz.Questions[r.currentQuestionIndex]
+	log.Printf("User %d submitted answer for question %d. Answered players: %d, Total players: %d", client.UserID, payload.QuestionID, len(r.answeredPlayers), r.playerCount())
 
-	isCorrect := payload.Answer == currentQuestion.CorrectAnswer
+	responseTime := now.Sub(r.questionSentAt)
+	isCorrect := service.IsCorrectAnswer(currentQuestion, payload.Answer)
 	wasFirstCorrectAnswer := false
 
-	if isCorrect {
-		// Check if this is the first correct answer for this question
-		if !r.isQuestionAnsweredCorrectly {
-			r.isQuestionAnsweredCorrectly = true
-			wasFirstCorrectAnswer = true
-			r.scores[client.UserID].Score += 10 // Award points only to the first
-		}
+	// Check if this is the first correct answer for this question
+	if isCorrect && !r.isQuestionAnsweredCorrectly {
+		r.isQuestionAnsweredCorrectly = true
+		wasFirstCorrectAnswer = true
 	}
 
+	points := r.awardPoints(client.UserID, service.AnswerContext{
+		IsCorrect:      isCorrect,
+		IsFirstCorrect: wasFirstCorrectAnswer,
+		ResponseTime:   responseTime,
+		TimeLimit:      time.Duration(currentQuestion.Timer) * time.Second,
+	})
+
 	// Record the answer
-	if r.quizSessionID != 0 {
-		answerRecord := &model.QuizAnswer{
-			QuizSessionID: r.quizSessionID,
-			QuestionID:    currentQuestion.ID,
-			UserID:        client.UserID,
-			Answer:        payload.Answer,
-			IsCorrect:     isCorrect,
-			SubmittedAt:   time.Now(),
-		}
-		if err := r.quizService.RecordQuizAnswer(answerRecord); err != nil {
-			log.Printf("Error recording quiz answer for session %d: %v", r.quizSessionID, err)
-		}
-	}
+	r.tally.add(payload.Answer, isCorrect, responseTime)
+	r.trackProgress(client.UserID, isCorrect, responseTime)
+	r.recordAnswer(client.UserID, currentQuestion.ID, payload.Answer, isCorrect, points, responseTime)
 
 	resultPayload := dtos.AnswerResultPayload{
-		QuestionID:    currentQuestion.ID,
-		IsCorrect:     isCorrect,
-		PlayerID:      client.UserID,
-		PlayerName:    r.scores[client.UserID].UserName,
-		IsFirstAnswer: wasFirstCorrectAnswer,
+		QuestionID:     currentQuestion.ID,
+		IsCorrect:      isCorrect,
+		PlayerID:       client.UserID,
+		PlayerName:     r.scores[client.UserID].UserName,
+		IsFirstAnswer:  wasFirstCorrectAnswer,
+		Points:         points,
+		ResponseTimeMs: responseTime.Milliseconds(),
 	}
 	r.broadcastMessage("answer_result", resultPayload, nil)
 
 	// Send score update only if a score changed
-	if wasFirstCorrectAnswer {
-		var scoreList []dtos.PlayerScore
-		for _, s := range r.scores {
-			scoreList = append(scoreList, *s)
-		}
-		r.broadcastMessage("score_update", dtos.ScoreUpdatePayload{Scores: scoreList}, nil)
+	if points != 0 {
+		r.broadcastMessage("score_update", r.scoreUpdatePayload(), nil)
 	}
+	r.sendMonitor()
 
-	if len(r.answeredPlayers) == len(r.Clients) {
-		log.Println("All players have answered. Moving to next question.")
-		if r.questionTimer != nil {
-			r.questionTimer.Stop()
-		}
-		time.AfterFunc(2*time.Second, r.sendNextQuestion)
-	}
+	r.checkAllAnswered()
 }
 
 func (r *Room) handleParallelAnswer(client *Client, payload dtos.SubmitAnswerPayload) {
 	// Prevent processing if client has already finished
 	if r.finishedClients[client.UserID] {
 		log.Printf("Client %d submitted answer after finishing the quiz.", client.UserID)
+		r.rejectInbound(client)
 		return
 	}
 
 	currentQuestionIndex, ok := r.clientProgress[client.UserID]
 	if !ok {
 		log.Printf("Client %d not found in progress map for parallel quiz", client.UserID)
+		r.rejectInbound(client)
 		return
 	}
 
 	// Server trusts its own state about which question the client is on.
-	question := r.quiz.Questions[currentQuestionIndex]
-
-	// --- Scoring logic (simplified for parallel) ---
-	isCorrect := payload.Answer == question.CorrectAnswer
-	if isCorrect {
-		r.scores[client.UserID].Score += 10 // Simple scoring
+	now := time.Now()
+	question := r.playerQuestion(client.UserID, currentQuestionIndex)
+	if !r.acceptsAnswer(client, payload, question, r.playerDeadline(client.UserID), now) {
+		return
 	}
+	r.stopPlayerTimer(client.UserID)
 
-	// Record the answer
-	if r.quizSessionID != 0 {
-		answerRecord := &model.QuizAnswer{
-			QuizSessionID: r.quizSessionID,
-			QuestionID:    question.ID, // Use server's question ID
-			UserID:        client.UserID,
-			Answer:        payload.Answer,
-			IsCorrect:     isCorrect,
-			SubmittedAt:   time.Now(),
-		}
-		if err := r.quizService.RecordQuizAnswer(answerRecord); err != nil {
-			log.Printf("Error recording quiz answer for session %d: %v", r.quizSessionID, err)
-		}
-	}
+	responseTime := now.Sub(r.clientQuestionSentAt[client.UserID])
+	isCorrect := service.IsCorrectAnswer(question, payload.Answer)
+	points := r.awardPoints(client.UserID, service.AnswerContext{
+		IsCorrect:    isCorrect,
+		ResponseTime: responseTime,
+		TimeLimit:    time.Duration(question.Timer) * time.Second,
+	})
+
+	// Record the answer against the server's question ID
+	r.trackProgress(client.UserID, isCorrect, responseTime)
+	r.recordAnswer(client.UserID, question.ID, payload.Answer, isCorrect, points, responseTime)
 
 	// Send immediate feedback to the user
 	resultPayload := dtos.AnswerResultPayload{
-		QuestionID: question.ID, // Use server's question ID
-		IsCorrect:  isCorrect,
-		PlayerID:   client.UserID,
-		PlayerName: r.scores[client.UserID].UserName,
+		QuestionID:     question.ID, // Use server's question ID
+		IsCorrect:      isCorrect,
+		PlayerID:       client.UserID,
+		PlayerName:     r.scores[client.UserID].UserName,
+		Points:         points,
+		ResponseTimeMs: responseTime.Milliseconds(),
 	}
 	r.sendMessageToClient(client, "answer_result", resultPayload)
 
 	// Update progress and send next question
 	r.clientProgress[client.UserID]++
-	r.sendQuestionToClient(client, r.clientProgress[client.UserID])
+	r.sendQuestionToPlayer(client.UserID, r.clientProgress[client.UserID])
 
 	// Broadcast score update to everyone
-	var scoreList []dtos.PlayerScore
-	for _, s := range r.scores {
-		scoreList = append(scoreList, *s)
+	r.broadcastMessage("score_update", r.scoreUpdatePayload(), nil)
+	r.sendMonitor()
+}
+
+// sendFirstQuestions starts every parallel player on their first question.
+func (r *Room) sendFirstQuestions() {
+	for userID := range r.clientProgress {
+		r.sendQuestionToPlayer(userID, 0)
 	}
-	r.broadcastMessage("score_update", dtos.ScoreUpdatePayload{Scores: scoreList}, nil)
 }
 
-func (r *Room) sendQuestionToClient(client *Client, questionIndex int) {
-	if questionIndex >= len(r.quiz.Questions) {
+// sendQuestionToPlayer moves a parallel player on to the given question. The
+// player's timer runs even while they are reconnecting.
+func (r *Room) sendQuestionToPlayer(userID uint, questionIndex int) {
+	client := r.clientsByUserID[userID]
+
+	if questionIndex >= len(r.playerQuestions(userID)) {
 		// All questions answered by this client
-		r.finishedClients[client.UserID] = true
-		log.Printf("Client %d has finished the quiz.", client.UserID)
-		r.sendMessageToClient(client, "quiz_complete", nil)
+		r.finishedClients[userID] = true
+		log.Printf("Client %d has finished the quiz.", userID)
+		if client != nil {
+			r.sendMessageToClient(client, "quiz_complete", nil)
+		}
 
 		// Check if all clients are finished
-		if len(r.finishedClients) == len(r.Clients) {
+		if r.allPlayersFinished() {
 			log.Printf("All clients have finished the parallel quiz.")
-		r.endGame()
+			r.endGame()
 		}
 		return
 	}
 
-	question := r.quiz.Questions[questionIndex]
-	questionDTO := dtos.QuizQuestionDTO{
-		ID:      question.ID,
-		Content: json.RawMessage(question.Content),
-		Options: json.RawMessage(question.Options),
-		Timer:   question.Timer,
+	question := r.playerQuestion(userID, questionIndex)
+
+	sentAt := time.Now()
+	r.clientQuestionSentAt[userID] = sentAt
+	if question.Timer > 0 {
+		r.startPlayerTimer(userID, questionIndex, time.Duration(question.Timer)*time.Second)
 	}
 
-	log.Printf("Sending question %d to client %d", questionIndex+1, client.UserID)
-	r.sendMessageToClient(client, "next_question", questionDTO)
+	if client != nil {
+		log.Printf("Sending question %d to client %d", questionIndex+1, userID)
+		r.sendMessageToClient(client, "next_question", nextQuestionPayload(question, sentAt, r.playerDeadline(userID)))
+	}
 }
 
 func (r *Room) endGame() {
+	r.stopTimers()
 	r.State = StateFinished
-	log.Printf("Game %s finished. Current question index: %d, Total questions: %d", r.QuizID, r.currentQuestionIndex, len(r.quiz.Questions))
+	r.epoch++
+	r.finishedAt = time.Now()
+	log.Printf("Game in room %s finished. Current question index: %d, Total questions: %d", r.ID, r.currentQuestionIndex, len(r.quiz.Questions))
 
+	// Scores can be negative with negative marking, so start from the first player.
 	var winner dtos.PlayerScore
-	// Initialize winner with a score that ensures any actual player score will be higher
-	winner.Score = -1 // Assuming scores are non-negative
-
+	hasWinner := false
 	for _, score := range r.scores {
-		if score.Score > winner.Score {
+		if !hasWinner || score.Score > winner.Score {
 			winner = *score
+			hasWinner = true
 		}
 	}
 
-	var scoreList []dtos.PlayerScore
-	for _, s := range r.scores {
-		scoreList = append(scoreList, *s)
-	}
+	winner.TeamID = r.playerTeams[winner.UserID]
+
+	scoreList := r.scoreList()
+	teamStandings := r.teamStandings()
 
 	gameOverPayload := dtos.GameOverPayload{
 		Winner: winner,
 		Scores: scoreList,
+		Teams:  teamStandings,
+	}
+	if len(teamStandings) > 0 {
+		gameOverPayload.WinningTeam = &teamStandings[0]
 	}
 	r.broadcastMessage("game_over", gameOverPayload, nil)
-	log.Printf("Game over message broadcast for quiz %s. Winner: %s (Score: %d)", r.QuizID, winner.UserName, winner.Score)
+	r.sendMonitor()
+	log.Printf("Game over message broadcast for room %s. Winner: %s (Score: %d)", r.ID, winner.UserName, winner.Score)
 
-	// Record final scores and end time in the quiz session
+	// Record final scores, team results and end time in the quiz session
 	if r.quizSessionID != 0 {
-		if err := r.quizService.EndQuizSession(r.quizSessionID, scoreList); err != nil {
+		result := dtos.SessionResult{Scores: scoreList, Teams: teamStandings}
+		if r.hasTeams() {
+			result.TeamScoring = r.teamScoring
+		}
+		if err := r.quizService.EndQuizSession(r.quizSessionID, result); err != nil {
 			log.Printf("Error ending quiz session %d: %v", r.quizSessionID, err)
 		}
 	}
+	r.closeEventLog()
 }
 
 // --- Helper methods ---
 
+// awardPoints scores an answer with the session's strategy and keeps the
+// player's streak up to date.
+func (r *Room) awardPoints(userID uint, answer service.AnswerContext) int {
+	answer.Streak = r.streaks[userID]
+	if answer.IsCorrect {
+		r.streaks[userID]++
+	} else {
+		r.streaks[userID] = 0
+	}
+
+	points := r.scoring.Points(answer)
+	r.scores[userID].Score += points
+	return points
+}
+
+// recordAnswer stores an answer in the current quiz session, if there is one.
+func (r *Room) recordAnswer(userID uint, questionID uint, answer string, isCorrect bool, points int, responseTime time.Duration) {
+	if r.quizSessionID == 0 {
+		return
+	}
+	answerRecord := &model.QuizAnswer{
+		QuizSessionID:  r.quizSessionID,
+		QuestionID:     questionID,
+		Answer:         answer,
+		IsCorrect:      isCorrect,
+		Points:         points,
+		ResponseTimeMs: responseTime.Milliseconds(),
+		SubmittedAt:    time.Now(),
+	}
+	if guestID, ok := service.GuestIDFromPlayerID(userID); ok {
+		answerRecord.GuestID = &guestID
+	} else {
+		answerRecord.UserID = &userID
+	}
+	if err := r.quizService.RecordQuizAnswer(answerRecord); err != nil {
+		log.Printf("Error recording quiz answer for session %d: %v", r.quizSessionID, err)
+	}
+}
+
+func (r *Room) scoreList() []dtos.PlayerScore {
+	var scoreList []dtos.PlayerScore
+	for _, s := range r.scores {
+		score := *s
+		score.TeamID = r.playerTeams[s.UserID]
+		scoreList = append(scoreList, score)
+	}
+	return scoreList
+}
+
+// connectedStudents lists the players currently connected, without the host.
+func (r *Room) connectedStudents() []dtos.ConnectedStudentDTO {
+	var students []dtos.ConnectedStudentDTO
+	for client := range r.Clients {
+		if r.isHostClient(client) {
+			continue
+		}
+		profile := r.profile(client.UserID)
+		students = append(students, dtos.ConnectedStudentDTO{
+			UserID:    client.UserID,
+			UserName:  profile.Name,
+			Nickname:  profile.Nickname,
+			AvatarURL: profile.AvatarURL,
+			TeamID:    r.playerTeams[client.UserID],
+			IsGuest:   profile.IsGuest,
+			Presence:  client.presence,
+		})
+	}
+	return students
+}
+
+// setProfile stores the identity a client joined with. A player may reconnect
+// under a new nickname, so the latest connection wins.
+func (r *Room) setProfile(client *Client) {
+	profile := client.Profile
+	profile.UserID = client.UserID
+	r.profiles[client.UserID] = profile
+	if score, ok := r.scores[client.UserID]; ok {
+		score.UserName = profile.DisplayName()
+		score.AvatarURL = profile.AvatarURL
+	}
+}
+
+// profile returns how the player is shown, even if they never sent a profile.
+func (r *Room) profile(userID uint) dtos.PlayerProfile {
+	if profile, ok := r.profiles[userID]; ok {
+		return profile
+	}
+	return dtos.PlayerProfile{UserID: userID}
+}
+
+// newPlayerScore starts a player on zero under their display name.
+func (r *Room) newPlayerScore(userID uint) *dtos.PlayerScore {
+	profile := r.profile(userID)
+	return &dtos.PlayerScore{UserID: userID, UserName: profile.DisplayName(), AvatarURL: profile.AvatarURL, IsGuest: profile.IsGuest}
+}
+
+// playerCount is the number of connected players, without the host.
+func (r *Room) playerCount() int {
+	count := len(r.Clients)
+	if _, ok := r.clientsByUserID[r.hostID]; ok {
+		count--
+	}
+	return count
+}
+
 func (r *Room) handleClientRegister(client *Client) {
+	if r.kicked[client.UserID] {
+		log.Printf("Kicked user %d tried to rejoin room %s", client.UserID, r.ID)
+		r.sendError(client, dtos.ErrorCodeForbidden, "You have been removed from this room")
+		close(client.Send)
+		return
+	}
+
+	if client.Spectator {
+		r.handleSpectatorRegister(client)
+		return
+	}
+
+	resumed := false
+
 	// If a client with this UserID is already connected, disconnect the old one
+	// and let the new connection take over its slot.
 	if oldClient, ok := r.clientsByUserID[client.UserID]; ok {
-		log.Printf("User %d already connected to room %s. Disconnecting old client.", client.UserID, r.QuizID)
-		r.handleClientUnregister(oldClient)
+		log.Printf("User %d already connected to room %s. Disconnecting old client.", client.UserID, r.ID)
+		r.removeClient(oldClient)
+		resumed = true
+	}
+	if r.cancelGracePeriod(client.UserID) {
+		resumed = true
 	}
 
 	r.Clients[client] = true
 	r.clientsByUserID[client.UserID] = client // Add new client to map
-	log.Printf("Client %d registered to room %s", client.UserID, r.QuizID)
+	r.setProfile(client)
+	client.lastActivity = time.Now()
+	client.lastPong.Store(time.Now().UnixNano())
+	client.presence = PresenceConnected
+	log.Printf("Client %d registered to room %s", client.UserID, r.ID)
+
+	// The host controls the game but does not play it.
+	if r.isHostClient(client) {
+		r.sendStateSnapshot(client)
+		r.sendMonitor()
+		return
+	}
 
 	if _, ok := r.scores[client.UserID]; !ok {
-		userName := "Player " + fmt.Sprintf("%d", client.UserID)
-		r.scores[client.UserID] = &dtos.PlayerScore{UserID: client.UserID, UserName: userName, Score: 0}
+		r.scores[client.UserID] = r.newPlayerScore(client.UserID)
 	}
 
-	playerInfo := &dtos.PlayerInfoPayload{UserID: client.UserID, UserName: r.scores[client.UserID].UserName}
+	if resumed {
+		log.Printf("Client %d resumed its session in room %s", client.UserID, r.ID)
+		r.sendStateSnapshot(client)
+		r.sendMonitor()
+		return
+	}
+
+	playerInfo := &dtos.PlayerInfoPayload{UserID: client.UserID, UserName: r.scores[client.UserID].UserName, AvatarURL: r.scores[client.UserID].AvatarURL}
 	r.broadcastMessage("player_joined", playerInfo, client)
+
+	if r.joinSmallestTeam(client.UserID) {
+		r.broadcastTeams()
+	}
+	r.sendMonitor()
 }
 
+// handleClientUnregister is called when a connection drops. The player keeps
+// their slot for the reconnect grace period before the others are told they left.
 func (r *Room) handleClientUnregister(client *Client) {
-	if _, ok := r.Clients[client]; ok {
-		delete(r.Clients, client)
-		delete(r.clientsByUserID, client.UserID) // Remove from clientsByUserID map
-		close(client.Send)
-		log.Printf("Client %d unregistered from room %s", client.UserID, r.QuizID)
+	if r.removeClient(client) {
+		log.Printf("Client %d unregistered from room %s", client.UserID, r.ID)
+		if !r.isHostClient(client) && !client.Spectator {
+			r.broadcastPresence(client.UserID, PresenceDisconnected)
+			r.startGracePeriod(client.UserID)
+			r.sendMonitor()
+			r.checkAllAnswered()
+		}
+	}
+}
 
-		playerInfo := &dtos.PlayerInfoPayload{UserID: client.UserID, UserName: r.scores[client.UserID].UserName}
-		r.broadcastMessage("player_left", playerInfo, nil)
+// removeClient detaches a connection from the room without notifying anyone.
+func (r *Room) removeClient(client *Client) bool {
+	delete(r.backlogged, client)
+	if r.spectators[client] {
+		delete(r.spectators, client)
+		close(client.Send)
+		return true
+	}
+	if _, ok := r.Clients[client]; !ok {
+		return false
+	}
+	delete(r.Clients, client)
+	if r.clientsByUserID[client.UserID] == client {
+		delete(r.clientsByUserID, client.UserID) // Remove from clientsByUserID map
 	}
+	close(client.Send)
+	return true
 }
 
 func (r *Room) broadcastMessage(msgType string, payload interface{}, exclude *Client) {
 	payloadBytes, _ := json.Marshal(payload)
 	msg := dtos.WebsocketMessage{Type: msgType, Payload: payloadBytes}
 	msgBytes, _ := json.Marshal(msg)
+	r.logOutbound(nil, msgType, payloadBytes)
 
 	for client := range r.Clients {
 		if client != exclude {
-			select {
-			case client.Send <- msgBytes:
-			default:
-				close(client.Send)
-				delete(r.Clients, client)
-			}
+			r.send(client, msgType, msgBytes)
 		}
 	}
+	for spectator := range r.spectators {
+		r.send(spectator, msgType, msgBytes)
+	}
+}
+
+// sendError replies to a single client with an ErrorPayload, code being one
+// of the dtos.ErrorCode constants.
+func (r *Room) sendError(client *Client, code string, message string) {
+	r.rejectInbound(client)
+	if client == nil {
+		log.Printf("Room %s: %s", r.ID, message)
+		return
+	}
+	r.sendMessageToClient(client, "error", dtos.ErrorPayload{Code: code, Message: message})
 }
 
 func (r *Room) sendMessageToClient(client *Client, msgType string, payload interface{}) {
@@ -468,11 +1037,6 @@ func (r *Room) sendMessageToClient(client *Client, msgType string, payload inter
 		log.Printf("Error marshalling message for sendMessageToClient: %v", err)
 		return
 	}
-
-	select {
-	case client.Send <- msgBytes:
-	default:
-		// Assume the client is disconnected and handle it.
-		r.handleClientUnregister(client)
-	}
+	r.logOutbound(client, msgType, payloadBytes)
+	r.send(client, msgType, msgBytes)
 }
\ No newline at end of file
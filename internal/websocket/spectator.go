@@ -0,0 +1,31 @@
+package websocket
+
+import (
+	"exam/internal/dtos"
+	"log"
+)
+
+// Spectators watch a room without playing in it, e.g. a classroom projector.
+// They are kept apart from Clients, so they never get a score, never show up
+// as participants and are never waited for. They receive every broadcast:
+// questions, timers, results and leaderboards.
+
+func (r *Room) handleSpectatorRegister(client *Client) {
+	r.spectators[client] = true
+	log.Printf("Spectator %d joined room %s", client.UserID, r.ID)
+	r.sendStateSnapshot(client)
+}
+
+// handleSpectatorMessage lets spectators ask for a fresh snapshot and nothing else.
+func (r *Room) handleSpectatorMessage(msg *InboundMessage) {
+	switch msg.Type {
+	case "resume":
+		r.sendStateSnapshot(msg.Client)
+	case "submit_answer":
+		r.sendError(msg.Client, dtos.ErrorCodeForbidden, "Spectators cannot submit answers")
+	case "presence":
+		// Nobody tracks a spectator's presence.
+	default:
+		r.sendError(msg.Client, dtos.ErrorCodeForbidden, "Spectators cannot send "+msg.Type)
+	}
+}
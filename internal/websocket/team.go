@@ -0,0 +1,237 @@
+package websocket
+
+import (
+	"exam/internal/dtos"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// How a team's score is built from its members' scores.
+const (
+	TeamScoringSum     = "sum"
+	TeamScoringAverage = "average"
+	TeamScoringBest    = "best"
+)
+
+const maxTeams = 20
+
+// team is a group of players whose scores count together.
+type team struct {
+	ID   string
+	Name string
+}
+
+// hasTeams reports whether the room is playing in teams.
+func (r *Room) hasTeams() bool {
+	return len(r.teams) > 0
+}
+
+// handleTeamCommand runs the host's team set-up commands. Teams can only be
+// changed between games.
+func (r *Room) handleTeamCommand(msg *InboundMessage) {
+	if r.State == StateInProgress || r.State == StatePaused {
+		r.sendError(msg.Client, dtos.ErrorCodeInvalidState, "Teams cannot be changed while a game is in progress")
+		return
+	}
+
+	switch msg.Type {
+	case "create_teams":
+		r.createTeams(msg.Client, *msg.Payload.(*dtos.CreateTeamsPayload))
+	case "assign_team":
+		payload := msg.Payload.(*dtos.AssignTeamPayload)
+		r.assignTeam(msg.Client, payload.UserID, payload.TeamID)
+	case "auto_assign_teams":
+		r.autoAssignTeams(msg.Client)
+	case "clear_teams":
+		r.teams = nil
+		r.playerTeams = make(map[uint]string)
+		r.teamScoring = TeamScoringSum
+		log.Printf("Host cleared the teams in room %s", r.ID)
+		r.broadcastTeams()
+	}
+}
+
+func (r *Room) createTeams(client *Client, payload dtos.CreateTeamsPayload) {
+	names := payload.Names
+	if len(names) == 0 {
+		for i := 1; i <= payload.Count; i++ {
+			names = append(names, fmt.Sprintf("Team %d", i))
+		}
+	}
+	if len(names) < 2 || len(names) > maxTeams {
+		r.sendError(client, dtos.ErrorCodeInvalidPayload, fmt.Sprintf("Between 2 and %d teams are needed", maxTeams))
+		return
+	}
+
+	scoring := payload.Scoring
+	if scoring == "" {
+		scoring = TeamScoringSum
+	}
+	if scoring != TeamScoringSum && scoring != TeamScoringAverage && scoring != TeamScoringBest {
+		r.sendError(client, dtos.ErrorCodeInvalidPayload, "Team scoring must be sum, average or best")
+		return
+	}
+
+	teams := make([]*team, 0, len(names))
+	for i, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			r.sendError(client, dtos.ErrorCodeInvalidPayload, "Team names cannot be empty")
+			return
+		}
+		teams = append(teams, &team{ID: fmt.Sprintf("team-%d", i+1), Name: name})
+	}
+
+	r.teams = teams
+	r.teamScoring = scoring
+	r.playerTeams = make(map[uint]string)
+	log.Printf("Host created %d teams in room %s (scoring: %s)", len(teams), r.ID, scoring)
+	r.broadcastTeams()
+}
+
+func (r *Room) assignTeam(client *Client, userID uint, teamID string) {
+	if userID == r.hostID {
+		r.sendError(client, dtos.ErrorCodeForbidden, "The host cannot join a team")
+		return
+	}
+	if _, ok := r.scores[userID]; !ok {
+		r.sendError(client, dtos.ErrorCodeNotFound, "Player not found")
+		return
+	}
+	if r.findTeam(teamID) == nil {
+		r.sendError(client, dtos.ErrorCodeNotFound, "Team not found")
+		return
+	}
+
+	r.playerTeams[userID] = teamID
+	r.broadcastTeams()
+}
+
+// autoAssignTeams shuffles every player into evenly sized teams.
+func (r *Room) autoAssignTeams(client *Client) {
+	if !r.hasTeams() {
+		r.sendError(client, dtos.ErrorCodeInvalidState, "Create teams first")
+		return
+	}
+
+	players := make([]uint, 0, len(r.scores))
+	for userID := range r.scores {
+		players = append(players, userID)
+	}
+	rand.Shuffle(len(players), func(i, j int) { players[i], players[j] = players[j], players[i] })
+
+	r.playerTeams = make(map[uint]string)
+	for i, userID := range players {
+		r.playerTeams[userID] = r.teams[i%len(r.teams)].ID
+	}
+	log.Printf("Host auto-assigned %d players to teams in room %s", len(players), r.ID)
+	r.broadcastTeams()
+}
+
+// joinSmallestTeam puts a player without a team into the team with the fewest
+// members, so late joiners keep the teams balanced.
+func (r *Room) joinSmallestTeam(userID uint) bool {
+	if !r.hasTeams() {
+		return false
+	}
+	if _, ok := r.playerTeams[userID]; ok {
+		return false
+	}
+
+	sizes := make(map[string]int)
+	for _, teamID := range r.playerTeams {
+		sizes[teamID]++
+	}
+	smallest := r.teams[0]
+	for _, t := range r.teams[1:] {
+		if sizes[t.ID] < sizes[smallest.ID] {
+			smallest = t
+		}
+	}
+	r.playerTeams[userID] = smallest.ID
+	return true
+}
+
+func (r *Room) findTeam(teamID string) *team {
+	for _, t := range r.teams {
+		if t.ID == teamID {
+			return t
+		}
+	}
+	return nil
+}
+
+// teamStandings aggregates the members' scores of every team, best team first.
+func (r *Room) teamStandings() []dtos.TeamScore {
+	if !r.hasTeams() {
+		return nil
+	}
+
+	members := make(map[string][]uint)
+	for userID, teamID := range r.playerTeams {
+		members[teamID] = append(members[teamID], userID)
+	}
+
+	standings := make([]dtos.TeamScore, 0, len(r.teams))
+	for _, t := range r.teams {
+		ids := members[t.ID]
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		standings = append(standings, dtos.TeamScore{
+			TeamID:   t.ID,
+			TeamName: t.Name,
+			Score:    r.aggregateTeamScore(ids),
+			Members:  ids,
+		})
+	}
+	sort.SliceStable(standings, func(i, j int) bool { return standings[i].Score > standings[j].Score })
+	return standings
+}
+
+func (r *Room) aggregateTeamScore(members []uint) int {
+	var scores []int
+	for _, userID := range members {
+		if score, ok := r.scores[userID]; ok {
+			scores = append(scores, score.Score)
+		}
+	}
+	if len(scores) == 0 {
+		return 0
+	}
+
+	switch r.teamScoring {
+	case TeamScoringAverage:
+		total := 0
+		for _, s := range scores {
+			total += s
+		}
+		return int(math.Round(float64(total) / float64(len(scores))))
+	case TeamScoringBest:
+		best := scores[0]
+		for _, s := range scores[1:] {
+			if s > best {
+				best = s
+			}
+		}
+		return best
+	default:
+		total := 0
+		for _, s := range scores {
+			total += s
+		}
+		return total
+	}
+}
+
+// broadcastTeams tells everyone the current teams and their members.
+func (r *Room) broadcastTeams() {
+	r.broadcastMessage("teams_updated", dtos.TeamsPayload{Scoring: r.teamScoring, Teams: r.teamStandings()}, nil)
+}
+
+// scoreUpdatePayload is the leaderboard with team standings when playing in teams.
+func (r *Room) scoreUpdatePayload() dtos.ScoreUpdatePayload {
+	return dtos.ScoreUpdatePayload{Scores: r.scoreList(), Teams: r.teamStandings()}
+}
@@ -0,0 +1,101 @@
+package websocket
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Browsers cannot set the Authorization header on a websocket handshake, so
+// they trade their JWT for a ticket first and open the websocket with that:
+// either as ?ticket= or, to keep it out of URLs and logs, as the subprotocols
+// [TicketSubprotocol, ticket]. A ticket is good for one handshake within
+// Config.TicketTTL, on any node, and stands for the token it was issued for,
+// whose device is checked again when it is redeemed.
+
+// TicketSubprotocol marks the ticket among the subprotocols of a handshake.
+// It is the subprotocol the server accepts.
+const TicketSubprotocol = "quiz-ticket"
+
+const ticketQueryParam = "ticket"
+
+func ticketKey(ticket string) string { return "quiz:ticket:" + ticket }
+
+// IssueTicket returns a one-time ticket for the claims of a verified JWT, and
+// when it expires.
+func (h *Hub) IssueTicket(claims map[string]interface{}) (string, time.Time, error) {
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate ticket: %w", err)
+	}
+	ticket := base64.RawURLEncoding.EncodeToString(random)
+
+	if err := h.backplane.Set(ticketKey(ticket), string(data), h.config.TicketTTL); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to store ticket: %w", err)
+	}
+	return ticket, time.Now().Add(h.config.TicketTTL), nil
+}
+
+// RedeemTicket returns the claims a ticket was issued for, and uses it up.
+func (h *Hub) RedeemTicket(ticket string) (map[string]interface{}, bool) {
+	data, ok, err := h.backplane.Take(ticketKey(ticket))
+	if err != nil {
+		log.Printf("Error redeeming websocket ticket: %v", err)
+		return nil, false
+	}
+	if !ok {
+		return nil, false
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &claims); err != nil {
+		return nil, false
+	}
+	return claims, true
+}
+
+// TicketFromRequest returns the ticket a websocket handshake carries, or "".
+func (h *Hub) TicketFromRequest(r *http.Request) string {
+	if !websocket.IsWebSocketUpgrade(r) {
+		return ""
+	}
+	protocols := websocket.Subprotocols(r)
+	for i, protocol := range protocols {
+		if protocol == TicketSubprotocol && i+1 < len(protocols) {
+			return protocols[i+1]
+		}
+	}
+	return r.URL.Query().Get(ticketQueryParam)
+}
+
+// CheckOrigin reports whether a handshake may come from its Origin: one in
+// Config.AllowedOrigins, or the API's own host if none are configured. Clients
+// that are not browsers send no Origin and are let through.
+func (h *Hub) CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if len(h.config.AllowedOrigins) == 0 {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+	origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+	for _, allowed := range h.config.AllowedOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"exam/database"
 	"exam/internal/handler"
 	"exam/internal/i18n"
@@ -13,8 +15,11 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/casbin/casbin/v2"
 	"github.com/golang-migrate/migrate/v4"
@@ -27,6 +32,9 @@ import (
 	"golang.org/x/oauth2/google"
 )
 
+// shutdownTimeout bounds how long the API takes to shut down gracefully.
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	err := godotenv.Load()
 	if err != nil {
@@ -60,9 +68,8 @@ func runAPI() {
 
 	fmt.Println("Database migration and model sync complete")
 
-	// Start the websocket hub
-	hub := websocket.NewHub()
-	go hub.Run()
+	// Create the websocket hub; every room it opens runs in its own goroutine
+	hub := websocket.NewHub(websocket.LoadConfig())
 
 	e := echo.New()
 
@@ -101,9 +108,15 @@ func runAPI() {
 
 	// Initialize services
 	deviceService := service.NewDeviceService(deviceRepo)
-	authService := service.NewAuthService(userRepo, deviceRepo, googleOauthConfig.ClientID)
-	quizService := service.NewQuizService(quizRepo, hub)
+	authService := service.NewAuthService(userRepo, deviceRepo, uploadedFileRepo, googleOauthConfig.ClientID)
+	quizService := service.NewQuizService(quizRepo, userRepo, uploadedFileRepo, hub)
+	hub.SetQuizService(quizService)
+	// Pick up the games that were running when the API last stopped
+	if err := hub.RestoreSessions(); err != nil {
+		log.Printf("Failed to restore quiz sessions: %v", err)
+	}
 	fileService := service.NewFileService(uploadedFileRepo)
+	assignmentService := service.NewAssignmentService(quizRepo, userRepo)
 
 	// Initialize handlers
 	authHandler := handler.NewAuthHandler(authService, googleOauthConfig)
@@ -112,6 +125,7 @@ func runAPI() {
 	quizHandler := handler.NewQuizHandler(quizService)
 	websocketHandler := handler.NewWebsocketHandler(hub, quizService)
 	fileHandler := handler.NewFileHandler(fileService)
+	assignmentHandler := handler.NewAssignmentHandler(assignmentService)
 
 	// Register health check
 	e.GET("/health", func(c echo.Context) error {
@@ -127,18 +141,42 @@ func runAPI() {
 
 	// Register routes
 	routes.AuthRoutes(e, authHandler)
+	routes.GuestRoutes(e, quizHandler, websocketHandler)
 
 	v1 := e.Group("/api/v1")
 	v1.Use(middleware.JWTAuthMiddleware(deviceRepo))
 	v1.Use(middleware.CasbinAuthMiddleware(enforcer))
-	routes.APIRoutes(v1, authHandler, accountHandler, userHandler, quizHandler, websocketHandler, fileHandler)
+	routes.APIRoutes(v1, authHandler, accountHandler, userHandler, quizHandler, websocketHandler, fileHandler, assignmentHandler)
+
+	// Websocket handshakes may authenticate with a ticket instead of a JWT
+	routes.WebsocketRoutes(e, websocketHandler, middleware.WebsocketAuthMiddleware(deviceRepo, hub), middleware.CasbinAuthMiddleware(enforcer))
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
-	e.Logger.Fatal(e.Start(fmt.Sprintf(":%s", port)))
+	go func() {
+		if err := e.Start(fmt.Sprintf(":%s", port)); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			e.Logger.Fatal(err)
+		}
+	}()
+
+	// Shut down gracefully on SIGINT or SIGTERM: running games are checkpointed
+	// and their clients told to reconnect once the API is back.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	log.Println("Shutting down the API")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := hub.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to close all quiz rooms: %v", err)
+	}
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down the server: %v", err)
+	}
 }
 
 func runMigration() {